@@ -0,0 +1,64 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// backend gives access to the data of an LCP Server,
+// either through its REST api or directly through its database.
+type backend interface {
+	ListPublications(page, perPage int) ([]stor.Publication, error)
+	SearchPublications(format string) ([]stor.Publication, error)
+	GetPublication(id string) (*stor.Publication, error)
+	GetPublicationByAltID(altID string) (*stor.Publication, error)
+	ListLicenses(page, perPage int) ([]stor.LicenseInfo, error)
+	SearchLicenses(q licenseQuery) ([]stor.LicenseInfo, error)
+	GetLicense(id string) (*stor.LicenseInfo, error)
+	RevokeLicense(id string) (*lic.StatusDoc, error)
+	GenerateLicense(req *api.LicenseRequest) (*lic.License, error)
+	ListEvents(f stor.EventFilter, page, perPage int) ([]eventRecord, error)
+	Metrics() (*stor.DashboardData, error)
+}
+
+// licenseQuery holds the criteria of a license search; the first criterion set is used,
+// in the order of the fields, as done by the REST api.
+type licenseQuery struct {
+	User   string
+	Pub    string
+	Status string
+	Count  string // min:max device count
+	Month  string // YYYY-MM
+	Date   string // YYYY-MM-DD
+}
+
+var errNoCriterion = errors.New("missing search criterion")
+
+// contentTypes maps the formats accepted by a publication search to content types
+var contentTypes = map[string]string{
+	"epub":  "application/epub+zip",
+	"pdf":   "application/pdf",
+	"lcpdf": "application/pdf+lcp",
+	"lcpau": "application/audiobook+lcp",
+	"lcpdi": "application/divina+lcp",
+}
+
+// exportPageSize is the number of records fetched per request during an export
+const exportPageSize = 500
+
+// eventRecord is an event with the identifier of its license, as exported
+type eventRecord struct {
+	LicenseID  string    `json:"license_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Type       string    `json:"type"`
+	DeviceID   string    `json:"device_id"`
+	DeviceName string    `json:"device_name"`
+}
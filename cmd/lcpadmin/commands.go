@@ -0,0 +1,435 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+var errUsage = errors.New("invalid command")
+
+// runCommand executes a command on a backend.
+func runCommand(b backend, p *printer, args []string) error {
+	sub := ""
+	if len(args) > 1 {
+		sub = args[1]
+	}
+	switch args[0] {
+	case "publication", "publications":
+		switch sub {
+		case "list":
+			return listPublications(b, p, args[2:])
+		case "search":
+			return searchPublications(b, p, args[2:])
+		case "show":
+			return showPublication(b, p, args[2:])
+		}
+	case "license", "licenses":
+		switch sub {
+		case "list":
+			return listLicenses(b, p, args[2:])
+		case "search":
+			return searchLicenses(b, p, args[2:])
+		case "show":
+			return showLicense(b, p, args[2:])
+		case "revoke":
+			return revokeLicense(b, p, args[2:])
+		case "generate":
+			return generateLicense(b, p, args[2:])
+		case "events":
+			return licenseEvents(b, p, args[2:])
+		}
+	case "metrics":
+		return metrics(b, p)
+	case "export":
+		return export(b, args[1:])
+	}
+	return errUsage
+}
+
+// pageFlags adds the pagination flags to a flag set
+func pageFlags(fs *flag.FlagSet) (page, perPage *int) {
+	page = fs.Int("page", 1, "page number")
+	perPage = fs.Int("per-page", 20, "number of items per page")
+	return
+}
+
+// oneArg parses the flags of a command taking a single argument, and returns the argument.
+func oneArg(fs *flag.FlagSet, args []string) (string, error) {
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return "", errUsage
+	}
+	return fs.Arg(0), nil
+}
+
+func printPublications(p *printer, publications []stor.Publication) error {
+	rows := make([][]string, 0, len(publications))
+	for i := range publications {
+		rows = append(rows, publicationRow(&publications[i]))
+	}
+	return p.print(publications, publicationHeaders, rows)
+}
+
+func printLicenses(p *printer, licenses []stor.LicenseInfo) error {
+	rows := make([][]string, 0, len(licenses))
+	for i := range licenses {
+		rows = append(rows, licenseRow(&licenses[i]))
+	}
+	return p.print(licenses, licenseHeaders, rows)
+}
+
+func listPublications(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("publication list", flag.ExitOnError)
+	page, perPage := pageFlags(fs)
+	fs.Parse(args)
+
+	publications, err := b.ListPublications(*page, *perPage)
+	if err != nil {
+		return err
+	}
+	return printPublications(p, publications)
+}
+
+func searchPublications(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("publication search", flag.ExitOnError)
+	format := fs.String("format", "", "format of the publications: epub, pdf, lcpdf, lcpau or lcpdi")
+	fs.Parse(args)
+	if *format == "" {
+		return errUsage
+	}
+
+	publications, err := b.SearchPublications(*format)
+	if err != nil {
+		return err
+	}
+	return printPublications(p, publications)
+}
+
+func showPublication(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("publication show", flag.ExitOnError)
+	byAltID := fs.Bool("altid", false, "the argument is the alternative identifier of the publication")
+	id, err := oneArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var publication *stor.Publication
+	if *byAltID {
+		publication, err = b.GetPublicationByAltID(id)
+	} else {
+		publication, err = b.GetPublication(id)
+	}
+	if err != nil {
+		return err
+	}
+	return p.properties(publication, publicationProperties(publication))
+}
+
+func listLicenses(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("license list", flag.ExitOnError)
+	page, perPage := pageFlags(fs)
+	fs.Parse(args)
+
+	licenses, err := b.ListLicenses(*page, *perPage)
+	if err != nil {
+		return err
+	}
+	return printLicenses(p, licenses)
+}
+
+func searchLicenses(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("license search", flag.ExitOnError)
+	var q licenseQuery
+	fs.StringVar(&q.User, "user", "", "user identifier")
+	fs.StringVar(&q.Pub, "pub", "", "publication identifier")
+	fs.StringVar(&q.Status, "status", "", "license status")
+	fs.StringVar(&q.Count, "count", "", "device count range, as min:max")
+	fs.StringVar(&q.Month, "month", "", "month of issue, as YYYY-MM")
+	fs.StringVar(&q.Date, "date", "", "date of issue, as YYYY-MM-DD")
+	fs.Parse(args)
+
+	licenses, err := b.SearchLicenses(q)
+	if errors.Is(err, errNoCriterion) {
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return printLicenses(p, licenses)
+}
+
+func showLicense(b backend, p *printer, args []string) error {
+	id, err := oneArg(flag.NewFlagSet("license show", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	license, err := b.GetLicense(id)
+	if err != nil {
+		return err
+	}
+	return p.properties(license, licenseProperties(license))
+}
+
+func revokeLicense(b backend, p *printer, args []string) error {
+	id, err := oneArg(flag.NewFlagSet("license revoke", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+
+	statusDoc, err := b.RevokeLicense(id)
+	if err != nil {
+		return err
+	}
+	return p.properties(statusDoc, [][2]string{
+		{"License", statusDoc.ID},
+		{"Status", statusDoc.Status},
+		{"Updated", formatTime(&statusDoc.Updated.Status)},
+	})
+}
+
+func generateLicense(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("license generate", flag.ExitOnError)
+	req := &api.LicenseRequest{}
+	fs.StringVar(&req.PublicationID, "pub", "", "publication identifier")
+	fs.StringVar(&req.AltID, "altid", "", "alternative identifier of the publication")
+	fs.StringVar(&req.UserID, "user", "", "user identifier")
+	fs.StringVar(&req.UserName, "name", "", "user name")
+	fs.StringVar(&req.UserEmail, "email", "", "user email")
+	encrypted := fs.String("encrypted", "", "comma separated list of the user properties to encrypt, e.g. email,name")
+	fs.StringVar(&req.Profile, "profile", "", "encryption profile, the one of the server configuration by default")
+	fs.StringVar(&req.TextHint, "hint", "", "passphrase hint")
+	passphrase := fs.String("passphrase", "", "user passphrase, hashed before being sent")
+	fs.StringVar(&req.PassHash, "passhash", "", "hex encoded sha256 hash of the user passphrase")
+	start := fs.String("start", "", "start of the loan, as an RFC 3339 date")
+	end := fs.String("end", "", "end of the loan, as an RFC 3339 date")
+	days := fs.Int("days", 0, "duration of the loan in days, from the start or from now")
+	printRight := fs.Int("print", -1, "number of pages which can be printed; unlimited by default")
+	copyRight := fs.Int("copy", -1, "number of characters which can be copied; unlimited by default")
+	outFile := fs.String("o", "", "file in which the license is written, the standard output by default")
+	fs.Parse(args)
+
+	if *passphrase != "" {
+		hash := sha256.Sum256([]byte(*passphrase))
+		req.PassHash = hex.EncodeToString(hash[:])
+	}
+	if *encrypted != "" {
+		req.UserEncrypted = strings.Split(*encrypted, ",")
+	}
+	for _, d := range []struct {
+		value string
+		date  **time.Time
+	}{{*start, &req.Start}, {*end, &req.End}} {
+		if d.value != "" {
+			t, err := time.Parse(time.RFC3339, d.value)
+			if err != nil {
+				return fmt.Errorf("invalid date %s", d.value)
+			}
+			*d.date = &t
+		}
+	}
+	if *days > 0 && req.End == nil {
+		from := time.Now()
+		if req.Start != nil {
+			from = *req.Start
+		}
+		to := from.AddDate(0, 0, *days)
+		req.End = &to
+	}
+	if *printRight >= 0 {
+		v := int32(*printRight)
+		req.Print = &v
+	}
+	if *copyRight >= 0 {
+		v := int32(*copyRight)
+		req.Copy = &v
+	}
+
+	license, err := b.GenerateLicense(req)
+	if err != nil {
+		return err
+	}
+	if *outFile == "" {
+		return writeLicense(os.Stdout, license)
+	}
+	f, err := os.Create(*outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeLicense(f, license); err != nil {
+		return err
+	}
+	return p.properties(license, [][2]string{
+		{"License", license.UUID},
+		{"User", req.UserID},
+		{"Publication", req.PublicationID},
+		{"File", *outFile},
+	})
+}
+
+// writeLicense writes a license as json, without escaping the html characters of links
+func writeLicense(w io.Writer, license *lic.License) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(license)
+}
+
+func licenseEvents(b backend, p *printer, args []string) error {
+	fs := flag.NewFlagSet("license events", flag.ExitOnError)
+	eventType := fs.String("type", "", "event type: register, renew, return, revoke or cancel")
+	page, perPage := pageFlags(fs)
+	id, err := oneArg(fs, args)
+	if err != nil {
+		return err
+	}
+
+	events, err := b.ListEvents(stor.EventFilter{LicenseID: id, Type: *eventType}, *page, *perPage)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(events))
+	for i := range events {
+		rows = append(rows, eventRow(&events[i])[1:])
+	}
+	return p.print(events, eventHeaders[1:], rows)
+}
+
+func metrics(b backend, p *printer) error {
+	data, err := b.Metrics()
+	if err != nil {
+		return err
+	}
+	props := [][2]string{
+		{"Publications", strconv.Itoa(data.TotalPublications)},
+		{"Users", strconv.Itoa(data.TotalUsers)},
+		{"Licenses", strconv.Itoa(data.TotalLicenses)},
+		{"Licenses, last 12 months", strconv.Itoa(data.LicensesLast12Months)},
+		{"Licenses, last month", strconv.Itoa(data.LicensesLastMonth)},
+		{"Licenses, last week", strconv.Itoa(data.LicensesLastWeek)},
+		{"Licenses, last day", strconv.Itoa(data.LicensesLastDay)},
+		{"Oldest license", data.OldestLicenseDate},
+		{"Latest license", data.LatestLicenseDate},
+		{"Overshared licenses", strconv.Itoa(data.OversharedLicensesCount)},
+	}
+	for _, s := range data.LicenseStatuses {
+		props = append(props, [2]string{"Status " + s.Name, strconv.Itoa(s.Count)})
+	}
+	for _, t := range data.PublicationTypes {
+		props = append(props, [2]string{"Type " + t.Name, strconv.Itoa(t.Count)})
+	}
+	return p.properties(data, props)
+}
+
+// export writes every publication, license or event, page after page, as json lines or csv.
+func export(b backend, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "jsonl", "export format, jsonl or csv")
+	outFile := fs.String("o", "", "output file, the standard output by default")
+	kind, err := oneArg(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return fmt.Errorf("invalid export format %s", *format)
+	}
+
+	var w io.Writer = os.Stdout
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	// fetch returns the items of a page as json values and csv rows
+	var fetch func(page int) ([]interface{}, [][]string, error)
+	var headers []string
+	switch kind {
+	case "publications":
+		headers = publicationHeaders
+		fetch = func(page int) ([]interface{}, [][]string, error) {
+			publications, err := b.ListPublications(page, exportPageSize)
+			items, rows := make([]interface{}, len(publications)), make([][]string, len(publications))
+			for i := range publications {
+				items[i], rows[i] = &publications[i], publicationRow(&publications[i])
+			}
+			return items, rows, err
+		}
+	case "licenses":
+		headers = licenseHeaders
+		fetch = func(page int) ([]interface{}, [][]string, error) {
+			licenses, err := b.ListLicenses(page, exportPageSize)
+			items, rows := make([]interface{}, len(licenses)), make([][]string, len(licenses))
+			for i := range licenses {
+				items[i], rows[i] = &licenses[i], licenseRow(&licenses[i])
+			}
+			return items, rows, err
+		}
+	case "events":
+		headers = eventHeaders
+		fetch = func(page int) ([]interface{}, [][]string, error) {
+			events, err := b.ListEvents(stor.EventFilter{}, page, exportPageSize)
+			items, rows := make([]interface{}, len(events)), make([][]string, len(events))
+			for i := range events {
+				items[i], rows[i] = &events[i], eventRow(&events[i])
+			}
+			return items, rows, err
+		}
+	default:
+		return errUsage
+	}
+
+	enc := json.NewEncoder(w)
+	var cw *csv.Writer
+	if *format == "csv" {
+		cw = csvWriter(w, headers)
+	}
+	count := 0
+	for page := 1; ; page++ {
+		items, rows, err := fetch(page)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			if cw != nil {
+				cw.Write(rows[i])
+			} else if err := enc.Encode(items[i]); err != nil {
+				return err
+			}
+		}
+		count += len(items)
+		if len(items) < exportPageSize {
+			break
+		}
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d %s exported\n", count, kind)
+	return nil
+}
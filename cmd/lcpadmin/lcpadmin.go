@@ -0,0 +1,149 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// lcpadmin is a command-line tool for the operators of an LCP Server.
+// It works through the private REST api of the server, or directly on its database.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+// Config holds the global options of the tool
+type Config struct {
+	Server       string // url of the LCP Server
+	Username     string
+	Password     string
+	ClientCert   string
+	ClientKey    string
+	ServerCA     string
+	ServerConfig string // configuration file of the LCP Server, for a direct access to the database
+	Dsn          string
+	Output       string
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: lcpadmin [options] command [arguments]
+
+Commands:
+  publication list [-page] [-per-page]
+  publication search -format epub|pdf|lcpdf|lcpau|lcpdi
+  publication show [-altid] id
+  license list [-page] [-per-page]
+  license search -user|-pub|-status|-count min:max|-month YYYY-MM|-date YYYY-MM-DD
+  license show id
+  license revoke id
+  license generate -pub|-altid -user -hint -passphrase|-passhash [options]
+  license events [-type] [-page] [-per-page] id
+  metrics
+  export [-format jsonl|csv] [-o file] publications|licenses|events
+
+Options:`)
+	flag.PrintDefaults()
+}
+
+func main() {
+
+	var c Config
+	flag.StringVar(&c.Server, "server", os.Getenv("LCPADMIN_SERVER"), "url of the LCP Server, for an access through the REST api")
+	flag.StringVar(&c.Username, "username", os.Getenv("LCPADMIN_USERNAME"), "username of the private api")
+	flag.StringVar(&c.Password, "password", os.Getenv("LCPADMIN_PASSWORD"), "password of the private api")
+	flag.StringVar(&c.ClientCert, "clientcert", os.Getenv("LCPADMIN_CLIENT_CERT"), "client certificate used to authenticate to the LCP Server (mTLS)")
+	flag.StringVar(&c.ClientKey, "clientkey", os.Getenv("LCPADMIN_CLIENT_KEY"), "private key of the client certificate")
+	flag.StringVar(&c.ServerCA, "serverca", os.Getenv("LCPADMIN_SERVER_CA"), "CA certificate of the LCP Server, if not issued by a public CA")
+	flag.StringVar(&c.ServerConfig, "config", os.Getenv("LCPSERVER_CONFIG"), "configuration file of the LCP Server, for a direct access to the database")
+	flag.StringVar(&c.Dsn, "dsn", "", "data source name of the database, overriding the one of the configuration")
+	flag.StringVar(&c.Output, "output", OutputTable, "output mode, table or json")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if c.Output != OutputTable && c.Output != OutputJSON {
+		fmt.Fprintln(os.Stderr, "Invalid output mode "+c.Output)
+		os.Exit(2)
+	}
+
+	// keep the output clean
+	log.SetLevel(log.ErrorLevel)
+
+	b, err := newBackend(c)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	p := &printer{w: os.Stdout, mode: c.Output}
+	if err := runCommand(b, p, flag.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			usage()
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// newBackend selects the REST api if a server url is set, else the database of the server.
+func newBackend(c Config) (backend, error) {
+	if c.Server != "" {
+		b := newRestBackend(c)
+		if err := configureClientTLS(c, b.client); err != nil {
+			return nil, fmt.Errorf("TLS configuration failed: %w", err)
+		}
+		return b, nil
+	}
+	if c.ServerConfig == "" && c.Dsn == "" {
+		return nil, errors.New("set the url of the server (-server), or its configuration (-config) or database (-dsn)")
+	}
+	sc, err := conf.Init(c.ServerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("configuration failed: %w", err)
+	}
+	if c.Dsn != "" {
+		sc.Dsn = c.Dsn
+	}
+	return newStoreBackend(sc)
+}
+
+// configureClientTLS sets the client certificate and trusted CA used by the http client.
+func configureClientTLS(c Config, client *http.Client) error {
+	if c.ClientCert == "" && c.ServerCA == "" {
+		return nil
+	}
+	tc := &tls.Config{}
+	if c.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	if c.ServerCA != "" {
+		pem, err := os.ReadFile(c.ServerCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificate found in the server CA file")
+		}
+		tc.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tc
+	client.Transport = transport
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// Output modes
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// printer writes the results of a command as an aligned table, or as json for scripting.
+type printer struct {
+	w    io.Writer
+	mode string
+}
+
+// print writes a value as json, or as a table made of headers and rows.
+func (p *printer) print(v interface{}, headers []string, rows [][]string) error {
+	if p.mode == OutputJSON {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	if headers != nil {
+		fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// properties writes a single value as json, or as a two columns table of properties.
+func (p *printer) properties(v interface{}, props [][2]string) error {
+	rows := make([][]string, 0, len(props))
+	for _, prop := range props {
+		rows = append(rows, []string{prop[0] + ":", prop[1]})
+	}
+	return p.print(v, nil, rows)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Columns of the publication, license and event tables and csv exports
+
+var publicationHeaders = []string{"UUID", "ALT ID", "TITLE", "TYPE", "SIZE", "VERSION", "CREATED"}
+
+func publicationRow(p *stor.Publication) []string {
+	return []string{p.UUID, p.AltID, p.Title, p.ContentType, strconv.FormatUint(uint64(p.Size), 10),
+		strconv.Itoa(p.Version), formatTime(&p.CreatedAt)}
+}
+
+var licenseHeaders = []string{"UUID", "USER", "PUBLICATION", "STATUS", "DEVICES", "ISSUED", "END"}
+
+func licenseRow(l *stor.LicenseInfo) []string {
+	return []string{l.UUID, l.UserID, l.PublicationID, l.Status, strconv.Itoa(l.DeviceCount),
+		formatTime(&l.CreatedAt), formatTime(l.End)}
+}
+
+var eventHeaders = []string{"LICENSE", "TIMESTAMP", "TYPE", "DEVICE ID", "DEVICE NAME"}
+
+func eventRow(e *eventRecord) []string {
+	return []string{e.LicenseID, formatTime(&e.Timestamp), e.Type, e.DeviceID, e.DeviceName}
+}
+
+// publicationProperties lists the properties of a publication shown by the show command
+func publicationProperties(p *stor.Publication) [][2]string {
+	props := [][2]string{
+		{"UUID", p.UUID},
+		{"Alt ID", p.AltID},
+		{"Title", p.Title},
+		{"Authors", p.Authors},
+		{"Provider", p.Provider},
+		{"Content type", p.ContentType},
+		{"Href", p.Href},
+		{"Size", strconv.FormatUint(uint64(p.Size), 10)},
+		{"Checksum", p.Checksum},
+		{"Version", strconv.Itoa(p.Version)},
+		{"Created", formatTime(&p.CreatedAt)},
+		{"Content updated", formatTime(p.ContentUpdated)},
+	}
+	if p.TakedownPolicy != "" {
+		props = append(props, [2]string{"Takedown", p.TakedownPolicy + " at " + formatTime(p.TakedownAt) + ", " + p.TakedownReason})
+	}
+	return props
+}
+
+// licenseProperties lists the properties of a license shown by the show command
+func licenseProperties(l *stor.LicenseInfo) [][2]string {
+	return [][2]string{
+		{"UUID", l.UUID},
+		{"Provider", l.Provider},
+		{"User", l.UserID},
+		{"Publication", l.PublicationID},
+		{"Status", l.Status},
+		{"Status updated", formatTime(l.StatusUpdated)},
+		{"Devices", strconv.Itoa(l.DeviceCount)},
+		{"Issued", formatTime(&l.CreatedAt)},
+		{"Updated", formatTime(l.Updated)},
+		{"Start", formatTime(l.Start)},
+		{"End", formatTime(l.End)},
+		{"Max end", formatTime(l.MaxEnd)},
+		{"Print", limit(l.Print)},
+		{"Copy", limit(l.Copy)},
+	}
+}
+
+// limit formats a print or copy right; -1 is stored for no limit
+func limit(v int32) string {
+	if v < 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(int(v))
+}
+
+// csvWriter writes the rows of an export as csv, with a header line.
+func csvWriter(w io.Writer, headers []string) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.Write(headers)
+	return cw
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+func TestPrinter(t *testing.T) {
+
+	created := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	publications := []stor.Publication{
+		{UUID: "pub-1", AltID: "isbn-1", Title: "First", ContentType: "application/epub+zip", Size: 1024, Version: 1, CreatedAt: created},
+		{UUID: "pub-22", Title: "Second", ContentType: "application/pdf+lcp", Size: 20, Version: 3},
+	}
+
+	// table: a header line and aligned columns
+	var out bytes.Buffer
+	if err := printPublications(&printer{w: &out, mode: OutputTable}, publications); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "UUID    ALT ID  TITLE") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	if !strings.Contains(lines[1], "2026-10-18T10:00:00Z") || !strings.HasSuffix(lines[2], "  -") {
+		t.Errorf("unexpected dates:\n%s", out.String())
+	}
+	if strings.Index(lines[1], "First") != strings.Index(lines[0], "TITLE") {
+		t.Errorf("unaligned columns:\n%s", out.String())
+	}
+
+	// json: the values themselves
+	out.Reset()
+	if err := printPublications(&printer{w: &out, mode: OutputJSON}, publications); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []stor.Publication
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[1].UUID != "pub-22" {
+		t.Errorf("unexpected json %s, %v", out.String(), err)
+	}
+
+	// properties: two columns
+	out.Reset()
+	end := created.AddDate(0, 1, 0)
+	license := &stor.LicenseInfo{UUID: "lic-1", UserID: "user-1", Status: stor.STATUS_ACTIVE, End: &end, Print: -1, Copy: 2000}
+	if err := (&printer{w: &out, mode: OutputTable}).properties(license, licenseProperties(license)); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"UUID:            lic-1\n", "End:             2026-11-18T10:00:00Z\n", "Print:           unlimited\n", "Copy:            2000\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("missing %q in:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+
+	var out bytes.Buffer
+	cw := csvWriter(&out, eventHeaders)
+	e := eventRecord{LicenseID: "lic-1", Timestamp: time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC), Type: "register", DeviceID: "d1", DeviceName: "Reader, \"home\""}
+	cw.Write(eventRow(&e))
+	cw.Flush()
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0][0] != "LICENSE" || records[1][4] != "Reader, \"home\"" || records[1][1] != "2026-10-18T10:00:00Z" {
+		t.Errorf("unexpected records %q", records)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// restBackend calls the private REST api of an LCP Server.
+type restBackend struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newRestBackend(c Config) *restBackend {
+	baseURL := c.Server
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+	return &restBackend{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: c.Username,
+		password: c.Password,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// problem is the problem detail returned by the server on error
+type problem struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+	Status int    `json:"status"`
+}
+
+// call sends a request to the server and decodes the json response into out.
+func (b *restBackend) call(method, path string, query url.Values, payload, out interface{}) error {
+	u := b.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.username != "" {
+		req.SetBasicAuth(b.username, b.password)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var p problem
+		data, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(data, &p) == nil && p.Title != "" {
+			if p.Detail != "" {
+				return fmt.Errorf("%s %s: %s (%s)", method, path, p.Title, p.Detail)
+			}
+			return fmt.Errorf("%s %s: %s", method, path, p.Title)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func pageQuery(page, perPage int) url.Values {
+	return url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}
+}
+
+func (b *restBackend) ListPublications(page, perPage int) ([]stor.Publication, error) {
+	var publications []stor.Publication
+	return publications, b.call("GET", "/publications/", pageQuery(page, perPage), nil, &publications)
+}
+
+func (b *restBackend) SearchPublications(format string) ([]stor.Publication, error) {
+	var publications []stor.Publication
+	return publications, b.call("GET", "/publications/search", url.Values{"format": {format}}, nil, &publications)
+}
+
+func (b *restBackend) GetPublication(id string) (*stor.Publication, error) {
+	var publication stor.Publication
+	return &publication, b.call("GET", "/publications/"+url.PathEscape(id), nil, nil, &publication)
+}
+
+func (b *restBackend) GetPublicationByAltID(altID string) (*stor.Publication, error) {
+	var publication stor.Publication
+	return &publication, b.call("GET", "/publications/altid/"+url.PathEscape(altID), nil, nil, &publication)
+}
+
+func (b *restBackend) ListLicenses(page, perPage int) ([]stor.LicenseInfo, error) {
+	var licenses []stor.LicenseInfo
+	return licenses, b.call("GET", "/licenseinfo/", pageQuery(page, perPage), nil, &licenses)
+}
+
+func (b *restBackend) SearchLicenses(q licenseQuery) ([]stor.LicenseInfo, error) {
+	query := url.Values{}
+	for name, value := range map[string]string{"user": q.User, "pub": q.Pub, "status": q.Status, "count": q.Count, "month": q.Month, "date": q.Date} {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	if len(query) == 0 {
+		return nil, errNoCriterion
+	}
+	var licenses []stor.LicenseInfo
+	return licenses, b.call("GET", "/licenseinfo/search", query, nil, &licenses)
+}
+
+func (b *restBackend) GetLicense(id string) (*stor.LicenseInfo, error) {
+	var license stor.LicenseInfo
+	return &license, b.call("GET", "/licenseinfo/"+url.PathEscape(id), nil, nil, &license)
+}
+
+func (b *restBackend) RevokeLicense(id string) (*lic.StatusDoc, error) {
+	var statusDoc lic.StatusDoc
+	return &statusDoc, b.call("PUT", "/revoke/"+url.PathEscape(id), nil, nil, &statusDoc)
+}
+
+func (b *restBackend) GenerateLicense(req *api.LicenseRequest) (*lic.License, error) {
+	var license lic.License
+	return &license, b.call("POST", "/licenses/", nil, req, &license)
+}
+
+func (b *restBackend) ListEvents(f stor.EventFilter, page, perPage int) ([]eventRecord, error) {
+	query := pageQuery(page, perPage)
+	if f.LicenseID != "" {
+		query.Set("license", f.LicenseID)
+	}
+	if f.Type != "" {
+		query.Set("type", f.Type)
+	}
+	if f.DeviceID != "" {
+		query.Set("device", f.DeviceID)
+	}
+	if f.Since != nil {
+		query.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if f.Until != nil {
+		query.Set("until", f.Until.Format(time.RFC3339))
+	}
+	var events []eventRecord
+	return events, b.call("GET", "/events", query, nil, &events)
+}
+
+func (b *restBackend) Metrics() (*stor.DashboardData, error) {
+	var data stor.DashboardData
+	return &data, b.call("GET", "/dashboard", nil, nil, &data)
+}
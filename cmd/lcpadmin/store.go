@@ -0,0 +1,179 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// storeBackend works directly on the database of an LCP Server.
+// The configuration of the server is needed for revoking and generating licenses.
+type storeBackend struct {
+	config *conf.Config
+	store  stor.Store
+	cert   *tls.Certificate
+}
+
+func newStoreBackend(c *conf.Config) (*storeBackend, error) {
+	st, err := stor.Init(c.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &storeBackend{config: c, store: st}, nil
+}
+
+// certificate loads the provider certificate on first use, as it is only needed for generating licenses.
+func (b *storeBackend) certificate() (*tls.Certificate, error) {
+	if b.cert != nil {
+		return b.cert, nil
+	}
+	if b.config.Certificate.Cert == "" || b.config.Certificate.PrivateKey == "" {
+		return nil, errors.New("the configuration must set a certificate and a private key to generate licenses")
+	}
+	cert, err := tls.LoadX509KeyPair(b.config.Certificate.Cert, b.config.Certificate.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	b.cert = &cert
+	return b.cert, nil
+}
+
+func (b *storeBackend) ListPublications(page, perPage int) ([]stor.Publication, error) {
+	publications, err := b.store.Publication().List(page, perPage)
+	return *publications, err
+}
+
+func (b *storeBackend) SearchPublications(format string) ([]stor.Publication, error) {
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid format %s", format)
+	}
+	publications, err := b.store.Publication().FindByType(contentType)
+	return *publications, err
+}
+
+// a soft-deleted publication is considered not found, as by the REST api
+func (b *storeBackend) GetPublication(id string) (*stor.Publication, error) {
+	publication, err := b.store.Publication().Get(id)
+	if err != nil || publication.DeletedAt.Valid {
+		return nil, fmt.Errorf("publication %s not found", id)
+	}
+	return publication, nil
+}
+
+func (b *storeBackend) GetPublicationByAltID(altID string) (*stor.Publication, error) {
+	publication, err := b.store.Publication().GetByAltID(altID)
+	if err != nil || publication.DeletedAt.Valid {
+		return nil, fmt.Errorf("publication with alt id %s not found", altID)
+	}
+	return publication, nil
+}
+
+func (b *storeBackend) ListLicenses(page, perPage int) ([]stor.LicenseInfo, error) {
+	licenses, err := b.store.License().List(page, perPage)
+	return *licenses, err
+}
+
+func (b *storeBackend) SearchLicenses(q licenseQuery) ([]stor.LicenseInfo, error) {
+	var licenses *[]stor.LicenseInfo
+	var err error
+	switch {
+	case q.User != "":
+		licenses, err = b.store.License().FindByUser(q.User, stor.ExcludePubInfo)
+	case q.Pub != "":
+		licenses, err = b.store.License().FindByPublication(q.Pub)
+	case q.Status != "":
+		licenses, err = b.store.License().FindByStatus(q.Status)
+	case q.Count != "":
+		parts := strings.Split(q.Count, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid count %s, expected min:max", q.Count)
+		}
+		min, err1 := strconv.Atoi(parts[0])
+		max, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid count %s, expected min:max", q.Count)
+		}
+		licenses, err = b.store.License().FindByDeviceCount(min, max)
+	case q.Month != "":
+		licenses, err = b.store.License().FindByDate(q.Month, stor.ExcludePubInfo)
+	case q.Date != "":
+		licenses, err = b.store.License().FindByDate(q.Date, stor.ExcludePubInfo)
+	default:
+		return nil, errNoCriterion
+	}
+	if err != nil {
+		return nil, err
+	}
+	return *licenses, nil
+}
+
+func (b *storeBackend) GetLicense(id string) (*stor.LicenseInfo, error) {
+	license, err := b.store.License().Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("license %s not found", id)
+	}
+	return license, nil
+}
+
+func (b *storeBackend) RevokeLicense(id string) (*lic.StatusDoc, error) {
+	lc := lic.NewLicenseCtrl(conf.NewLive(b.config), b.store)
+	return lc.Revoke(id)
+}
+
+// GenerateLicense stores the info of a new license and returns the license, as the REST api does.
+func (b *storeBackend) GenerateLicense(req *api.LicenseRequest) (*lic.License, error) {
+	if err := req.Bind(nil); err != nil {
+		return nil, err
+	}
+	cert, err := b.certificate()
+	if err != nil {
+		return nil, err
+	}
+	var publication *stor.Publication
+	if req.PublicationID != "" {
+		publication, err = b.GetPublication(req.PublicationID)
+	} else if req.AltID != "" {
+		publication, err = b.GetPublicationByAltID(req.AltID)
+	} else {
+		err = errors.New("missing required publication identifier")
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.PublicationID = publication.UUID
+	return api.CreateLicense(b.config, b.store, cert, publication, req)
+}
+
+func (b *storeBackend) ListEvents(f stor.EventFilter, page, perPage int) ([]eventRecord, error) {
+	events, _, err := b.store.Event().Search(f, page, perPage)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]eventRecord, 0, len(*events))
+	for _, e := range *events {
+		records = append(records, eventRecord{
+			LicenseID:  e.LicenseID,
+			Timestamp:  e.Timestamp,
+			Type:       e.Type,
+			DeviceID:   e.DeviceID,
+			DeviceName: e.DeviceName,
+		})
+	}
+	return records, nil
+}
+
+func (b *storeBackend) Metrics() (*stor.DashboardData, error) {
+	return b.store.Dashboard().GetDashboard(b.config.Dashboard.ExcessiveSharingThreshold, b.config.Dashboard.LimitToLast12Months)
+}
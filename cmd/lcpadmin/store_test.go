@@ -0,0 +1,144 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/google/uuid"
+)
+
+// newTestBackend returns a store backend on a database in a temporary directory, holding a publication.
+func newTestBackend(t *testing.T) (*storeBackend, *stor.Publication) {
+	c := &conf.Config{
+		PublicBaseUrl: "http://localhost:8989",
+		Dsn:           "sqlite3://file:" + filepath.Join(t.TempDir(), "lcp.sqlite"),
+		Certificate: conf.Certificate{
+			Cert:       "../../pkg/test/cert/cert-edrlab-test.pem",
+			PrivateKey: "../../pkg/test/cert/privkey-edrlab-test.pem",
+		},
+		License: conf.License{
+			Provider: "http://edrlab.org",
+			Profile:  "http://readium.org/lcp/basic-profile",
+		},
+	}
+	b, err := newStoreBackend(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &stor.Publication{
+		UUID:          uuid.New().String(),
+		AltID:         "9781234567890",
+		Title:         "A Tale of Two Cities",
+		ContentType:   "application/epub+zip",
+		EncryptionKey: make([]byte, 32),
+		Href:          "https://storage.example.com/book.epub",
+		Size:          1024,
+		Checksum:      "c2hhMjU2",
+	}
+	if err := b.store.Publication().Create(pub); err != nil {
+		t.Fatal(err)
+	}
+	return b, pub
+}
+
+func TestStoreGenerateLicense(t *testing.T) {
+
+	b, pub := newTestBackend(t)
+
+	license, err := b.GenerateLicense(&api.LicenseRequest{
+		AltID:     pub.AltID,
+		UserID:    "user-1",
+		UserEmail: "user@example.com",
+		PassHash:  "faeb00ca518bea7cb11a7ef31fb6183b489b1b6eadb792bec64a03b3f6ff80a8",
+		TextHint:  "the title of the book",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if license.Provider != "http://edrlab.org" || license.User.ID != "user-1" || license.Signature == nil {
+		t.Errorf("unexpected license %+v", license)
+	}
+	// the license info is read back from the database, as done by the REST api
+	licInfo, err := b.GetLicense(license.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if licInfo.PublicationID != pub.UUID || licInfo.UserID != "user-1" || !license.Issued.Equal(licInfo.CreatedAt) {
+		t.Errorf("unexpected license info %+v", licInfo)
+	}
+
+	// an unknown publication, a missing passphrase
+	if _, err := b.GenerateLicense(&api.LicenseRequest{PublicationID: uuid.New().String(), UserID: "user-1",
+		PassHash: "faeb00ca518bea7cb11a7ef31fb6183b489b1b6eadb792bec64a03b3f6ff80a8", TextHint: "hint"}); err == nil {
+		t.Error("expected an error for an unknown publication")
+	}
+	if _, err := b.GenerateLicense(&api.LicenseRequest{PublicationID: pub.UUID, UserID: "user-1"}); err == nil {
+		t.Error("expected an error for a missing passphrase")
+	}
+}
+
+func TestStoreLicenses(t *testing.T) {
+
+	b, pub := newTestBackend(t)
+	for _, user := range []string{"user-1", "user-2"} {
+		if _, err := b.GenerateLicense(&api.LicenseRequest{PublicationID: pub.UUID, UserID: user,
+			PassHash: "faeb00ca518bea7cb11a7ef31fb6183b489b1b6eadb792bec64a03b3f6ff80a8", TextHint: "hint"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	licenses, err := b.ListLicenses(1, 20)
+	if err != nil || len(licenses) != 2 {
+		t.Fatalf("expected 2 licenses, got %d, %v", len(licenses), err)
+	}
+	licenses, err = b.SearchLicenses(licenseQuery{User: "user-2"})
+	if err != nil || len(licenses) != 1 || licenses[0].UserID != "user-2" {
+		t.Errorf("unexpected licenses of user-2 %+v, %v", licenses, err)
+	}
+	if _, err := b.SearchLicenses(licenseQuery{Count: "1"}); err == nil {
+		t.Error("expected an error for an invalid count")
+	}
+	if _, err := b.SearchLicenses(licenseQuery{}); err != errNoCriterion {
+		t.Errorf("expected a missing criterion error, got %v", err)
+	}
+
+	// a license which has not been registered by a device is cancelled
+	statusDoc, err := b.RevokeLicense(licenses[0].UUID)
+	if err != nil || statusDoc.Status != stor.STATUS_CANCELLED {
+		t.Errorf("unexpected status document %+v, %v", statusDoc, err)
+	}
+	events, err := b.ListEvents(stor.EventFilter{LicenseID: licenses[0].UUID}, 1, 20)
+	if err != nil || len(events) != 1 || events[0].Type != "cancel" {
+		t.Errorf("unexpected events %+v, %v", events, err)
+	}
+}
+
+func TestStorePublications(t *testing.T) {
+
+	b, pub := newTestBackend(t)
+
+	if p, err := b.GetPublicationByAltID(pub.AltID); err != nil || p.UUID != pub.UUID {
+		t.Errorf("unexpected publication %+v, %v", p, err)
+	}
+	publications, err := b.SearchPublications("epub")
+	if err != nil || len(publications) != 1 {
+		t.Errorf("expected an epub publication, got %d, %v", len(publications), err)
+	}
+	if _, err := b.SearchPublications("mobi"); err == nil {
+		t.Error("expected an error for an invalid format")
+	}
+	// a soft-deleted publication is not found
+	if err := b.store.Publication().Delete(pub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetPublication(pub.UUID); err == nil {
+		t.Error("a deleted publication must not be found")
+	}
+}
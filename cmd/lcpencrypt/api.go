@@ -0,0 +1,289 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// lcpencrypt http api, used to submit encryption jobs
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// defaultJobsLimit is the number of jobs listed if no limit is requested.
+const defaultJobsLimit = 50
+
+// jobAPI serves the http api of the utility.
+type jobAPI struct {
+	queue   *jobQueue
+	uploads string // directory of the uploaded files
+}
+
+// apiListenAddr returns the address of the http api. The api fetches source urls and calls callback urls:
+// without authentication, it only listens on the loopback interface, so that it is not an open relay.
+func apiListenAddr(c Config) (string, error) {
+
+	if c.APIAuth != "" {
+		return c.APIAddr, nil
+	}
+	host, port, err := net.SplitHostPort(c.APIAddr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		log.Warnf("No LCPENCRYPT_API_AUTH set, the http api only listens on localhost")
+		return net.JoinHostPort("localhost", port), nil
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", fmt.Errorf("the http api listens on %s, LCPENCRYPT_API_AUTH must be set", host)
+	}
+	return c.APIAddr, nil
+}
+
+// newAPIRouter returns the router of the http api.
+// Jobs are added to the queue, which also receives the files of the watched directory.
+func newAPIRouter(c Config, queue *jobQueue, uploads string) http.Handler {
+
+	a := &jobAPI{queue: queue, uploads: uploads}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	if c.APIAuth != "" {
+		user, password, _ := strings.Cut(c.APIAuth, ":")
+		r.Use(middleware.BasicAuth("lcpencrypt", map[string]string{user: password}))
+	}
+	r.Post("/jobs", a.createJob)     // POST /jobs
+	r.Get("/jobs", a.listJobs)       // GET /jobs{?status,limit}
+	r.Get("/jobs/{jobID}", a.getJob) // GET /jobs/123
+	r.Get("/status", a.getStatus)    // GET /status
+	return r
+}
+
+// createJob creates an encryption job from an uploaded file (multipart form) or a source url (json),
+// and adds it to the queue.
+func (a *jobAPI) createJob(w http.ResponseWriter, r *http.Request) {
+
+	dir, err := os.MkdirTemp(a.uploads, "job-")
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var opts JobOptions
+	var file string
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		file, err = readUpload(r, dir, &opts)
+	case "application/json":
+		if err = json.NewDecoder(r.Body).Decode(&opts); err == nil {
+			file, err = sourceFile(opts.SourceURL)
+		}
+	default:
+		err = errors.New("the request must be a multipart form with a file, or a json object with a source url")
+	}
+	if err == nil {
+		err = checkOptions(opts)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		writeProblem(w, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := a.queue.add(OriginAPI, file, dir, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		writeProblem(w, http.StatusInternalServerError, err)
+		return
+	}
+	log.Infof("Job %s created, file %s", job.ID, file)
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// getJob returns the status of a job.
+func (a *jobAPI) getJob(w http.ResponseWriter, r *http.Request) {
+
+	job, err := a.queue.get(chi.URLParam(r, "jobID"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeProblem(w, http.StatusNotFound, errors.New("unknown job"))
+		return
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// listJobs returns the most recent jobs, newest first, optionally with a given status.
+func (a *jobAPI) listJobs(w http.ResponseWriter, r *http.Request) {
+
+	limit := defaultJobsLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit < 1 {
+			writeProblem(w, http.StatusBadRequest, errors.New("invalid limit"))
+			return
+		}
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", JobPending, JobRunning, JobDone, JobFailed:
+	default:
+		writeProblem(w, http.StatusBadRequest, errors.New("invalid status"))
+		return
+	}
+	jobs, err := a.queue.list(status, limit)
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// getStatus returns the number of jobs per status.
+func (a *jobAPI) getStatus(w http.ResponseWriter, r *http.Request) {
+
+	counts, err := a.queue.counts()
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, counts)
+}
+
+// readUpload reads the fields of a multipart form and stores its file in the working directory.
+// It returns the name of the file.
+func readUpload(r *http.Request, dir string, opts *JobOptions) (string, error) {
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return "", err
+	}
+	var file string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if part.FormName() == "file" {
+			file = filepath.Base(part.FileName())
+			if file == "." || file == string(filepath.Separator) {
+				return "", errors.New("missing file name")
+			}
+			f, err := os.Create(filepath.Join(dir, file))
+			if err != nil {
+				return "", err
+			}
+			_, err = io.Copy(f, part)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, 4096))
+		if err != nil {
+			return "", err
+		}
+		v := string(value)
+		switch part.FormName() {
+		case "source_url":
+			opts.SourceURL = v
+		case "uuid":
+			opts.UUID = v
+		case "alt_id":
+			opts.AltID = v
+		case "provider":
+			opts.Provider = v
+		case "callback":
+			opts.Callback = v
+		case "cover":
+			cover, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", errors.New("invalid cover option")
+			}
+			opts.Cover = &cover
+		}
+	}
+	if file == "" && opts.SourceURL != "" {
+		return sourceFile(opts.SourceURL)
+	}
+	if file == "" {
+		return "", errors.New("missing file or source url")
+	}
+	if opts.SourceURL != "" {
+		return "", errors.New("a file and a source url cannot be both set")
+	}
+	return file, nil
+}
+
+// sourceFile returns the name of the file fetched from a source url.
+func sourceFile(sourceURL string) (string, error) {
+
+	if sourceURL == "" {
+		return "", errors.New("missing source url")
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", errors.New("invalid source url")
+	}
+	file := path.Base(u.Path)
+	if file == "." || file == "/" || filepath.Ext(file) == "" {
+		return "", fmt.Errorf("no file name with an extension in %s", sourceURL)
+	}
+	return file, nil
+}
+
+// checkOptions validates the options of a job.
+func checkOptions(opts JobOptions) error {
+
+	if opts.Callback != "" {
+		u, err := url.Parse(opts.Callback)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid callback url")
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeProblem writes an error as a problem detail.
+func writeProblem(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": err.Error(),
+	})
+}
@@ -0,0 +1,184 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestAPI returns the router of the http api, with a queue and an upload directory in a temporary directory.
+func newTestAPI(t *testing.T, c Config) (http.Handler, *jobQueue, string) {
+	q := newTestQueue(t)
+	uploads := t.TempDir()
+	return newAPIRouter(c, q, uploads), q, uploads
+}
+
+// multipartJob returns a job creation request with an uploaded file and form fields.
+func multipartJob(t *testing.T, file string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	if file != "" {
+		fw, _ := w.CreateFormFile("file", file)
+		fw.Write([]byte("epub content"))
+	}
+	w.Close()
+	req := httptest.NewRequest("POST", "/jobs", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCreateJob(t *testing.T) {
+
+	h, q, _ := newTestAPI(t, Config{})
+
+	// uploaded file
+	rr := serve(h, multipartJob(t, "book.epub", map[string]string{"uuid": "pub-1", "cover": "false", "callback": "https://cms.example.com/done"}))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body)
+	}
+	var job Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Location") != "/jobs/"+job.ID || job.Status != JobPending || job.Origin != OriginAPI {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if job.Options.UUID != "pub-1" || job.Options.Cover == nil || *job.Options.Cover {
+		t.Errorf("unexpected options %+v", job.Options)
+	}
+	saved, err := q.get(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(saved.Dir, "book.epub")); string(data) != "epub content" {
+		t.Errorf("unexpected uploaded file %q", data)
+	}
+
+	// source url
+	req := httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"source_url":"https://cdn.example.com/files/book2.epub","alt_id":"isbn"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = serve(h, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("unexpected status %d: %s", rr.Code, rr.Body)
+	}
+	json.Unmarshal(rr.Body.Bytes(), &job)
+	if job.File != "book2.epub" || job.Options.AltID != "isbn" {
+		t.Errorf("unexpected job %+v", job)
+	}
+
+	// invalid requests
+	for name, req := range map[string]*http.Request{
+		"no file":           multipartJob(t, "", nil),
+		"file and url":      multipartJob(t, "book.epub", map[string]string{"source_url": "https://cdn.example.com/book.epub"}),
+		"invalid cover":     multipartJob(t, "book.epub", map[string]string{"cover": "maybe"}),
+		"invalid callback":  multipartJob(t, "book.epub", map[string]string{"callback": "file:///etc/passwd"}),
+		"no file extension": httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"source_url":"https://cdn.example.com/files/"}`)),
+		"invalid scheme":    httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"source_url":"ftp://cdn.example.com/book.epub"}`)),
+		"no content type":   httptest.NewRequest("POST", "/jobs", strings.NewReader(`{}`)),
+	} {
+		if req.Header.Get("Content-Type") == "" && name != "no content type" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := serve(h, req)
+		if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: unexpected response %d %s", name, rr.Code, rr.Body)
+		}
+	}
+	if jobs, _ := q.list("", 10); len(jobs) != 2 {
+		t.Errorf("expected 2 jobs, got %d", len(jobs))
+	}
+}
+
+func TestGetJobs(t *testing.T) {
+
+	h, q, _ := newTestAPI(t, Config{})
+	first, _ := q.add(OriginWatch, "first.epub", "/in", JobOptions{})
+	second, _ := q.add(OriginAPI, "second.epub", "/up", JobOptions{})
+	second.Status = JobFailed
+	q.save(second)
+
+	rr := serve(h, httptest.NewRequest("GET", "/jobs/"+first.ID, nil))
+	var job Job
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &job) != nil || job.File != "first.epub" {
+		t.Errorf("unexpected response %d %s", rr.Code, rr.Body)
+	}
+	if rr := serve(h, httptest.NewRequest("GET", "/jobs/unknown", nil)); rr.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 status, got %d", rr.Code)
+	}
+
+	var jobs []Job
+	rr = serve(h, httptest.NewRequest("GET", "/jobs?status=failed", nil))
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &jobs) != nil || len(jobs) != 1 || jobs[0].ID != second.ID {
+		t.Errorf("unexpected failed jobs %d %s", rr.Code, rr.Body)
+	}
+	rr = serve(h, httptest.NewRequest("GET", "/jobs?limit=1", nil))
+	if json.Unmarshal(rr.Body.Bytes(), &jobs) != nil || len(jobs) != 1 {
+		t.Errorf("unexpected jobs %s", rr.Body)
+	}
+	for _, query := range []string{"status=lost", "limit=0", "limit=x"} {
+		if rr := serve(h, httptest.NewRequest("GET", "/jobs?"+query, nil)); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected a 400 status, got %d", query, rr.Code)
+		}
+	}
+
+	var counts map[string]int64
+	rr = serve(h, httptest.NewRequest("GET", "/status", nil))
+	if json.Unmarshal(rr.Body.Bytes(), &counts) != nil || counts[JobPending] != 1 || counts[JobFailed] != 1 || counts[JobDone] != 0 {
+		t.Errorf("unexpected counts %s", rr.Body)
+	}
+}
+
+func TestAPIAuth(t *testing.T) {
+
+	h, _, _ := newTestAPI(t, Config{APIAuth: "ingest:secret"})
+
+	if rr := serve(h, httptest.NewRequest("GET", "/status", nil)); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected a 401 status, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.SetBasicAuth("ingest", "secret")
+	if rr := serve(h, req); rr.Code != http.StatusOK {
+		t.Errorf("expected a 200 status, got %d", rr.Code)
+	}
+}
+
+func TestAPIListenAddr(t *testing.T) {
+
+	for _, c := range []struct {
+		addr, auth, expected string
+	}{
+		{":8090", "", "localhost:8090"},
+		{"127.0.0.1:8090", "", "127.0.0.1:8090"},
+		{"[::1]:8090", "", "[::1]:8090"},
+		{":8090", "ingest:secret", ":8090"},
+		{"10.0.0.5:8090", "ingest:secret", "10.0.0.5:8090"},
+	} {
+		addr, err := apiListenAddr(Config{APIAddr: c.addr, APIAuth: c.auth})
+		if err != nil || addr != c.expected {
+			t.Errorf("%s: expected %s, got %s, %v", c.addr, c.expected, addr, err)
+		}
+	}
+	// without authentication, the api cannot listen on a public interface
+	if _, err := apiListenAddr(Config{APIAddr: "0.0.0.0:8090"}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// lcpencrypt encryption jobs, from the watched directory or submitted via the http api
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/readium/readium-lcp-server/encrypt"
+)
+
+// Job status
+const (
+	JobPending = "pending" // waiting to be processed, or to be retried
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed" // failed permanently, the file is quarantined
+)
+
+// Job origins
+const (
+	OriginWatch = "watch" // file dropped in the watched directory
+	OriginAPI   = "api"   // file submitted via the http api
+)
+
+// JobOptions are the options of an encryption job.
+type JobOptions struct {
+	SourceURL string `json:"source_url,omitempty"` // file fetched by the job, if not uploaded
+	UUID      string `json:"uuid,omitempty"`
+	AltID     string `json:"alt_id,omitempty"`
+	Provider  string `json:"provider,omitempty"` // provider URI; the one of the configuration if not set
+	Cover     *bool  `json:"cover,omitempty"`    // cover extraction; the one of the configuration if not set
+	Callback  string `json:"callback,omitempty"` // url called with the job when it is finished
+}
+
+// Job is an encryption job, stored in the job queue.
+type Job struct {
+	ID          string               `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Origin      string               `json:"origin"`
+	Status      string               `json:"status" gorm:"index"`
+	File        string               `json:"file"`
+	UUID        string               `json:"uuid,omitempty"` // publication uuid, unless imposed or found in the License Server
+	Dir         string               `json:"-"`              // directory of the file
+	Options     JobOptions           `json:"options" gorm:"serializer:json"`
+	Attempts    int                  `json:"attempts"`
+	NextAttempt *time.Time           `json:"next_attempt,omitempty" gorm:"index"`
+	ErrorClass  string               `json:"error_class,omitempty"`
+	Error       string               `json:"error,omitempty"`
+	Publication *encrypt.Publication `json:"publication,omitempty" gorm:"serializer:json"`
+	Created     time.Time            `json:"created" gorm:"index"`
+	Started     *time.Time           `json:"started,omitempty"`
+	Finished    *time.Time           `json:"finished,omitempty"`
+}
+
+// runJob processes the file of a job, then retries it later, quarantines it or moves it
+// to the processed directory, and calls back the url set in the job options.
+// The fetching of a source file stops when the context is done, e.g. on shutdown.
+func runJob(ctx context.Context, c Config, q *jobQueue, job *Job) {
+
+	// job options override the configuration
+	c.InputPath = job.Dir
+	if job.Origin == OriginAPI {
+		c.UseFilenameAs = ""
+		c.UUID = job.Options.UUID
+		c.AltID = job.Options.AltID
+	}
+	if job.Options.Provider != "" {
+		c.ProviderUri = job.Options.Provider
+	}
+	if job.Options.Cover != nil {
+		c.ExtractCover = *job.Options.Cover
+	}
+
+	// the uuid is set before the first attempt, so that the retries store the encrypted file under the same name
+	if job.UUID == "" {
+		job.UUID = uuid.New().String()
+		if err := q.save(job); err != nil {
+			log.Errorf("Job %s, error saving the job: %v", job.ID, err)
+		}
+	}
+	c.JobUUID = job.UUID
+
+	// the original file is deleted, unless it is moved to the processed directory
+	handling := DeleteFile
+	if c.ProcessedPath != "" {
+		handling = KeepFile
+	}
+
+	var publication *encrypt.Publication
+	err := fetchSource(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		// the job was interrupted, it is not an attempt
+		job.Status = JobPending
+		job.Attempts--
+		if err := q.save(job); err != nil {
+			log.Errorf("Job %s, error saving the job: %v", job.ID, err)
+		}
+		return
+	}
+	if err == nil {
+		publication, err = processFile(c, job.File, handling)
+	}
+
+	now := time.Now()
+	job.NextAttempt = nil
+	if err == nil {
+		job.Status = JobDone
+		job.Error, job.ErrorClass = "", ""
+		job.Finished = &now
+		job.Publication = publication
+		if job.Publication != nil {
+			// the content key is only sent to the License Server
+			job.Publication.EncryptionKey = nil
+		}
+		log.Infof("Job %s done, file %s", job.ID, job.File)
+		if c.ProcessedPath != "" {
+			if err := moveFile(filepath.Join(job.Dir, job.File), c.ProcessedPath, job.ID); err != nil {
+				log.Errorf("Job %s, error moving file %s to the processed directory: %v", job.ID, job.File, err)
+			}
+		}
+	} else {
+		class := errorClass(err)
+		job.Error, job.ErrorClass = err.Error(), class
+		policy := retryPolicies[class]
+		if job.Attempts <= policy.retries {
+			next := now.Add(policy.backoff(job.Attempts))
+			job.Status = JobPending
+			job.NextAttempt = &next
+			log.Errorf("Job %s, %s error processing file %s, attempt %d, retry at %s: %v", job.ID, class, job.File, job.Attempts, next.Format(time.RFC3339), err)
+		} else {
+			job.Status = JobFailed
+			job.Finished = &now
+			log.Errorf("Job %s failed, %s error processing file %s after %d attempts: %v", job.ID, class, job.File, job.Attempts, err)
+			if c.FailedPath != "" {
+				if err := moveFile(filepath.Join(job.Dir, job.File), c.FailedPath, job.ID); err != nil && !os.IsNotExist(err) {
+					log.Errorf("Job %s, error moving file %s to the failed directory: %v", job.ID, job.File, err)
+				}
+			}
+		}
+	}
+	// uploaded files are removed once their job is finished
+	if job.Origin == OriginAPI && job.Status != JobPending {
+		os.RemoveAll(job.Dir)
+	}
+	if err := q.save(job); err != nil {
+		log.Errorf("Job %s, error saving the job: %v", job.ID, err)
+	}
+
+	if job.Options.Callback != "" && job.Status != JobPending {
+		if err := callback(job); err != nil {
+			log.Errorf("Job %s, error calling back %s: %v", job.ID, job.Options.Callback, err)
+		}
+	}
+}
+
+// Timeouts of the fetching of source files
+var (
+	fetchTimeout      = time.Hour   // maximum duration of a download
+	fetchStallTimeout = time.Minute // maximum duration without receiving data
+)
+
+// sourceClient fetches the source files of the jobs.
+var sourceClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// fetchSource downloads the source file of a job in its directory, if the file was not uploaded
+// or fetched by a previous attempt.
+func fetchSource(ctx context.Context, job *Job) error {
+
+	if job.Options.SourceURL == "" {
+		return nil
+	}
+	path := filepath.Join(job.Dir, job.File)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.Options.SourceURL, nil)
+	if err != nil {
+		return failure(FailSource, err)
+	}
+	resp, err := sourceClient.Do(req)
+	if err != nil {
+		return failure(FailSource, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return failure(FailSource, fmt.Errorf("fetching %s: %s", job.Options.SourceURL, resp.Status))
+	}
+	// the file is written under a temporary name, so that a partial download is not processed
+	f, err := os.Create(path + ".part")
+	if err != nil {
+		return failure(FailFile, err)
+	}
+	// the download is cancelled if no data is received for a while
+	stall := time.AfterFunc(fetchStallTimeout, cancel)
+	defer stall.Stop()
+	_, err = io.Copy(f, &stallReader{r: resp.Body, timer: stall})
+	f.Close()
+	if err != nil {
+		os.Remove(path + ".part")
+		return failure(FailSource, err)
+	}
+	if err := os.Rename(path+".part", path); err != nil {
+		return failure(FailFile, err)
+	}
+	return nil
+}
+
+// stallReader resets a timer each time data is read.
+type stallReader struct {
+	r     io.Reader
+	timer *time.Timer
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(fetchStallTimeout)
+	}
+	return n, err
+}
+
+// moveFile moves a file to a directory; if a file with the same name is already present,
+// the job id is added to the name.
+func moveFile(path, dir, jobID string) error {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	dst := filepath.Join(dir, name)
+	if _, err := os.Stat(dst); err == nil {
+		ext := filepath.Ext(name)
+		dst = filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+jobID[:8]+ext)
+	}
+	err := os.Rename(path, dst)
+	if err == nil || os.IsNotExist(err) {
+		return err
+	}
+	// the directories may be on different file systems
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// callback posts a finished job to the callback url of its options.
+func callback(job *Job) error {
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(job.Options.Callback, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeEPUB writes a minimal EPUB file.
+func writeEPUB(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	files := []struct{ name, content string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"OEBPS/content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:identifier id="id">test</dc:identifier><dc:title>Test</dc:title><dc:language>en</dc:language></metadata>
+<manifest><item id="c1" href="chapter1.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="c1"/></spine>
+</package>`},
+		{"OEBPS/chapter1.xhtml", `<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><head><title>1</title></head><body><p>Chapter 1</p></body></html>`},
+	}
+	for _, file := range files {
+		method := zip.Deflate
+		if file.name == "mimetype" {
+			method = zip.Store
+		}
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: file.name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(file.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testConfig returns the configuration of a server watching a temporary directory,
+// notifying a License Server which cannot be reached.
+func testConfig(t *testing.T) Config {
+	dir := t.TempDir()
+	c := Config{
+		InputPath:     filepath.Join(dir, "in"),
+		StoragePath:   filepath.Join(dir, "storage"),
+		StorageUrl:    "https://storage.example.com/",
+		LCPServerUrl:  "http://127.0.0.1:1",
+		ProcessedPath: filepath.Join(dir, "in", "processed"),
+		FailedPath:    filepath.Join(dir, "in", "failed"),
+		V2:            true,
+	}
+	for _, d := range []string{c.InputPath, c.StoragePath} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestMoveFile(t *testing.T) {
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "book.epub")
+	dst := filepath.Join(dir, "processed")
+	os.WriteFile(src, []byte("first"), 0644)
+	if err := moveFile(src, dst, "0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	// a file with the same name gets the beginning of the job id as a suffix
+	os.WriteFile(src, []byte("second"), 0644)
+	if err := moveFile(src, dst, "0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "book.epub")); string(data) != "first" {
+		t.Errorf("unexpected first file %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "book-01234567.epub")); string(data) != "second" {
+		t.Errorf("unexpected second file %q", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("the source file should have been moved")
+	}
+	if err := moveFile(src, dst, "0123456789abcdef"); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestRunJobRetry(t *testing.T) {
+
+	c := testConfig(t)
+	q := newTestQueue(t)
+	writeEPUB(t, filepath.Join(c.InputPath, "book.epub"))
+
+	job, err := q.add(OriginWatch, "book.epub", c.InputPath, JobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the License Server cannot be notified: the job is retried, and keeps its uuid
+	var uuid string
+	for attempt := 1; attempt <= 2; attempt++ {
+		job.Status, job.Attempts = JobRunning, attempt
+		runJob(context.Background(), c, q, job)
+		if job.Status != JobPending || job.ErrorClass != FailServer || job.NextAttempt == nil {
+			t.Fatalf("attempt %d: unexpected job %+v", attempt, job)
+		}
+		if uuid == "" {
+			uuid = job.UUID
+		} else if job.UUID != uuid {
+			t.Errorf("attempt %d: the uuid changed from %s to %s", attempt, uuid, job.UUID)
+		}
+	}
+	if saved, _ := q.get(job.ID); saved.UUID != uuid || saved.Status != JobPending {
+		t.Errorf("unexpected saved job %+v", saved)
+	}
+	// the retries replace the same encrypted file
+	files, _ := os.ReadDir(c.StoragePath)
+	if len(files) != 1 || files[0].Name() != uuid+".epub" {
+		t.Errorf("expected a single encrypted file %s.epub, got %v", uuid, files)
+	}
+	if _, err := os.Stat(filepath.Join(c.InputPath, "book.epub")); err != nil {
+		t.Error("the file of a pending job must stay in place")
+	}
+
+	// the retries are exhausted
+	job.Attempts = retryPolicies[FailServer].retries + 1
+	runJob(context.Background(), c, q, job)
+	if job.Status != JobFailed || job.Finished == nil {
+		t.Errorf("unexpected failed job %+v", job)
+	}
+	if _, err := os.Stat(filepath.Join(c.FailedPath, "book.epub")); err != nil {
+		t.Error("the file should have been moved to the failed directory")
+	}
+}
+
+func TestRunJobCorruptContent(t *testing.T) {
+
+	c := testConfig(t)
+	q := newTestQueue(t)
+	os.WriteFile(filepath.Join(c.InputPath, "corrupt.epub"), []byte("not a zip file"), 0644)
+
+	job, _ := q.add(OriginWatch, "corrupt.epub", c.InputPath, JobOptions{})
+	job.Attempts = 1
+	runJob(context.Background(), c, q, job)
+	if job.Status != JobFailed || job.ErrorClass != FailContent {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	// the file is quarantined, and not queued again
+	if _, err := os.Stat(filepath.Join(c.FailedPath, "corrupt.epub")); err != nil {
+		t.Error("the file should have been moved to the failed directory")
+	}
+	if q.isQueued(c.InputPath, "corrupt.epub") {
+		t.Error("a failed job must not be queued")
+	}
+}
+
+func TestFetchSource(t *testing.T) {
+
+	release := make(chan struct{})
+	defer close(release)
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/book.epub":
+			w.Write([]byte("epub content"))
+		case "/stalled.epub":
+			w.Write([]byte("beginning"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer source.Close()
+
+	dir := t.TempDir()
+	job := &Job{File: "book.epub", Dir: dir, Options: JobOptions{SourceURL: source.URL + "/book.epub"}}
+	if err := fetchSource(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "book.epub")); string(data) != "epub content" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	job = &Job{File: "missing.epub", Dir: dir, Options: JobOptions{SourceURL: source.URL + "/missing.epub"}}
+	if err := fetchSource(context.Background(), job); errorClass(err) != FailSource {
+		t.Errorf("expected a source error, got %v", err)
+	}
+
+	// a stalled download is cancelled, and leaves no file
+	defer func(d time.Duration) { fetchStallTimeout = d }(fetchStallTimeout)
+	fetchStallTimeout = 100 * time.Millisecond
+	job = &Job{File: "stalled.epub", Dir: dir, Options: JobOptions{SourceURL: source.URL + "/stalled.epub"}}
+	if err := fetchSource(context.Background(), job); errorClass(err) != FailSource {
+		t.Errorf("expected a source error, got %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "stalled.epub*")); len(files) != 0 {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	// an interrupted job is not an attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	q := newTestQueue(t)
+	job, _ = q.add(OriginAPI, "stalled.epub", dir, JobOptions{SourceURL: source.URL + "/stalled.epub"})
+	job.Status, job.Attempts = JobRunning, 1
+	runJob(ctx, testConfig(t), q, job)
+	if job.Status != JobPending || job.Attempts != 0 || job.NextAttempt != nil {
+		t.Errorf("unexpected interrupted job %+v", job)
+	}
+}
@@ -18,21 +18,38 @@ import (
 
 // LCP Encrypt configuration
 type Config struct {
-	InputPath    string `split_words:"true"`
-	ProviderUri  string `split_words:"true"`
+	InputPath     string `split_words:"true"`
+	ProviderUri   string `split_words:"true"`
 	UseFilenameAs string `split_words:"true"`
-	UUID         string
-	AltID        string
-	Verbose      bool
-	V2           bool
-	ExtractCover bool
-	PDFNoMeta    bool   `split_words:"true"`
-	StoragePath  string `split_words:"true"`
-	StorageUrl   string `split_words:"true"`
-	LCPServerUrl string `envconfig:"lcpserver_url"`
-	CMSUrl       string `split_words:"true" envconfig:"cms_url"`
+	UUID          string
+	AltID         string
+	Verbose       bool
+	V2            bool
+	ExtractCover  bool
+	PDFNoMeta     bool   `split_words:"true"`
+	StoragePath   string `split_words:"true"`
+	StorageUrl    string `split_words:"true"`
+	LCPServerUrl  string `envconfig:"lcpserver_url"`
+	CMSUrl        string `split_words:"true" envconfig:"cms_url"`
+	ClientCert    string `split_words:"true"` // client certificate used to authenticate to the LCP Server
+	ClientKey     string `split_words:"true"`
+	ServerCA      string `split_words:"true" envconfig:"server_ca"` // CA of the LCP Server certificate, if not a public CA
+	APIAddr       string `split_words:"true" envconfig:"api_addr"`  // address of the http api, in server mode
+	APIAuth       string `split_words:"true" envconfig:"api_auth"`  // user:password protecting the http api, optional
+	QueuePath     string `split_words:"true"`                       // sqlite file of the job queue, in server mode
+	ProcessedPath string `split_words:"true"`                       // directory of the processed files; "processed" in the watched directory if not set
+	FailedPath    string `split_words:"true"`                       // directory of the files which failed; "failed" in the watched directory if not set
+	JobUUID       string `ignored:"true"`                           // publication uuid used if none is imposed or found, kept across the attempts of a job
 }
 
+// watched indicates if the input directory is watched in server mode, i.e. unless only the http api is used.
+func (c Config) watched() bool {
+	return c.APIAddr == "" || c.InputPath != "."
+}
+
+// defaultQueuePath is the sqlite file of the job queue, if not configured.
+const defaultQueuePath = "lcpencrypt-jobs.sqlite"
+
 // create an enum with two values: keep_file and delete_file
 type FileHandling int
 
@@ -51,7 +68,7 @@ func init() {
 }
 
 func usage() {
-	fmt.Println("Usage: lcpencrypt [-v2] [-serve] [-input] [-usefnas] [-uuid] [-altid] [-verbose] [-pdfnometa] [-cover]")
+	fmt.Println("Usage: lcpencrypt [-v2] [-serve] [-api] [-status] [-input] [-usefnas] [-uuid] [-altid] [-verbose] [-pdfnometa] [-cover]")
 	flag.PrintDefaults()
 }
 
@@ -59,12 +76,20 @@ func main() {
 
 	// parse the command line
 	serve := flag.Bool("serve", false, "if set, start the utility as a server; the uuid flag is ignored in this mode")
+	apiAddr := flag.String("api", "", "address of the http api receiving encryption jobs in server mode, e.g. :8090")
+	status := flag.Bool("status", false, "if set, show the pending, failed and completed jobs of the job queue")
+	queuePath := flag.String("queue", "", "sqlite file of the job queue in server mode; "+defaultQueuePath+" if not set")
+	processedPath := flag.String("processed", "", "directory where processed files are moved in server mode; processed in the watched directory if not set")
+	failedPath := flag.String("failed", "", "directory where files which failed are moved in server mode; failed in the watched directory if not set")
 	input := flag.String("input", "", "source file locator (file path or url)")
 	provider := flag.String("provider", "", "provider URI of the publication(s)")
 	storagePath := flag.String("storage", "", "storage path")
 	storageUrl := flag.String("url", "", "storage URL")
 	lcpServerUrl := flag.String("lcpsv", "", "LCP Server URL")
-	cmsUrl := flag.String("cms", "", "CMS URL")		
+	cmsUrl := flag.String("cms", "", "CMS URL")
+	clientCert := flag.String("clientcert", "", "client certificate used to authenticate to the LCP Server (mTLS)")
+	clientKey := flag.String("clientkey", "", "private key of the client certificate")
+	serverCA := flag.String("serverca", "", "CA certificate of the LCP Server, if not issued by a public CA")
 	verbose := flag.Bool("verbose", false, "if set, display info messages; if not set, display only warnings and errors.")
 	v2 := flag.Bool("v2", true, "indicates a v2 License server")
 	cover := flag.Bool("cover", true, "indicates if a cover should be exported")
@@ -94,6 +119,13 @@ func main() {
 	c.StorageUrl = *storageUrl
 	c.LCPServerUrl = *lcpServerUrl
 	c.CMSUrl = *cmsUrl
+	c.ClientCert = *clientCert
+	c.ClientKey = *clientKey
+	c.ServerCA = *serverCA
+	c.APIAddr = *apiAddr
+	c.QueuePath = *queuePath
+	c.ProcessedPath = *processedPath
+	c.FailedPath = *failedPath
 	c.Verbose = *verbose
 	c.V2 = *v2
 	c.ExtractCover = *cover
@@ -114,6 +146,14 @@ func main() {
 	// LCPENCRYPT_STORAGE_URL
 	// LCPENCRYPT_LCPSERVER_URL
 	// LCPENCRYPT_CMS_URL
+	// LCPENCRYPT_CLIENT_CERT
+	// LCPENCRYPT_CLIENT_KEY
+	// LCPENCRYPT_SERVER_CA
+	// LCPENCRYPT_API_ADDR
+	// LCPENCRYPT_API_AUTH
+	// LCPENCRYPT_QUEUE_PATH
+	// LCPENCRYPT_PROCESSED_PATH
+	// LCPENCRYPT_FAILED_PATH
 	// process environment variables
 	err := envconfig.Process("lcpencrypt", &c)
 	if err != nil {
@@ -121,23 +161,53 @@ func main() {
 		os.Exit(1)
 	}
 
+	if c.QueuePath == "" {
+		c.QueuePath = defaultQueuePath
+	}
+	// in server mode, the watched files are moved out of the watched directory once processed or failed,
+	// so that they are not queued again
+	if *serve && c.watched() {
+		if c.ProcessedPath == "" {
+			c.ProcessedPath = filepath.Join(c.InputPath, "processed")
+		}
+		if c.FailedPath == "" {
+			c.FailedPath = filepath.Join(c.InputPath, "failed")
+		}
+	}
+
 	// the verbose flag acts on the info level
 	if c.Verbose {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	// set the client certificate used to call the LCP Server
+	err = configureClientTLS(c)
+	if err != nil {
+		log.Errorln("TLS configuration failed: " + err.Error())
+		os.Exit(1)
+	}
+
 	// get the file name from the input path
 	filename := filepath.Base(*input)
 
-	if *serve {
+	if *status {
+		// show the content of the job queue
+		if err := showStatus(c); err != nil {
+			log.Errorf("Error reading the job queue: %v", err)
+			os.Exit(1)
+		}
+	} else if *serve {
 		log.Infoln("Entering server mode")
 		log.Infoln("Watching directory: ", os.Getenv("LCPENCRYPT_INPUT_PATH"))
+		if c.APIAddr != "" {
+			log.Infoln("Http api address: ", c.APIAddr)
+		}
 		log.Infoln("Storage path: ", os.Getenv("LCPENCRYPT_STORAGE_PATH"))
 		// start the utility as a server
 		activateServer(c)
 	} else if filename != "." {
 		// run the utility as a command line tool, keeping the input file in place
-		err = processFile(c, filename, KeepFile)
+		_, err = processFile(c, filename, KeepFile)
 		if err != nil {
 			log.Errorf("Error processing file: %v", err)
 		}
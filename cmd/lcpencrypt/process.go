@@ -20,8 +20,8 @@ import (
 	"github.com/readium/readium-lcp-server/encrypt"
 )
 
-// processFile processes a single file
-func processFile(c Config, filename string, fileHandling FileHandling) error {
+// processFile processes a single file and returns the encrypted publication
+func processFile(c Config, filename string, fileHandling FileHandling) (*encrypt.Publication, error) {
 	log.Printf("Processing file: %s", filename)
 
 	// create a path from c.InputPath and filename
@@ -31,7 +31,7 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	var username, password string
 	err := getUsernamePassword(&c.LCPServerUrl, &username, &password)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// if the publication UUID or AltID is imposed, check if the content already exists in the License Server.
@@ -55,13 +55,17 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 		// contentKey and uuid are not initialized if the content does not exist in the License Server
 		contentkey, uuid, err = getContentKey(c.UUID, c.AltID, c.LCPServerUrl, username, password, c.V2)
 		if err != nil {
-			return err
+			return nil, failure(FailServer, err)
 		}
 		// set the publication UUID if returned by the server
 		if uuid != "" {
 			c.UUID = uuid
 		}
 	}
+	// a retried job keeps the uuid of its first attempt, so that the encrypted file of a previous attempt is replaced
+	if c.UUID == "" {
+		c.UUID = c.JobUUID
+	}
 
 	start := time.Now()
 
@@ -71,7 +75,11 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	log.Println("Starting encryption...")
 	publication, err := encrypt.ProcessEncryption(c.UUID, contentkey, inputFilePath, "", "", c.StoragePath, c.StorageUrl, "", c.ExtractCover, c.PDFNoMeta)
 	if err != nil {
-		return err
+		// a storage which cannot be reached is not a problem of the content
+		if isNetworkError(err) {
+			return nil, failure(FailStorage, err)
+		}
+		return nil, failure(FailContent, err)
 	}
 
 	// Set the publication AltID (if extracted from the filename or imposed)
@@ -80,7 +88,7 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	if c.LCPServerUrl == "" {
 		// If no LCP server URL is provided, we can't notify the server
 		log.Println("No LCP server URL provided, skipping notification.")
-		return nil
+		return publication, nil
 	}
 
 	elapsed := time.Since(start)
@@ -88,7 +96,7 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	// notify the license server
 	err = encrypt.NotifyLCPServer(*publication, contentkey != "", c.ProviderUri, c.LCPServerUrl, c.V2, username, password, c.Verbose)
 	if err != nil {
-		return err
+		return nil, failure(FailServer, err)
 	}
 
 	// notify a CMS (username and password are always in the URL)
@@ -96,10 +104,11 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	if err != nil {
 		fmt.Println("Error notifying the CMS:", err.Error())
 		// abort the notification of the license server
-		err = encrypt.AbortNotification(*publication, c.LCPServerUrl, c.V2, username, password)
-		if err != nil {
-			return err
+		if abortErr := encrypt.AbortNotification(*publication, c.LCPServerUrl, c.V2, username, password); abortErr != nil {
+			return nil, failure(FailServer, abortErr)
 		}
+		// the input file is kept, the publication is not available
+		return nil, failure(FailCMS, fmt.Errorf("CMS notification failed: %w", err))
 	}
 
 	fmt.Println("The encryption took", elapsed)
@@ -107,11 +116,11 @@ func processFile(c Config, filename string, fileHandling FileHandling) error {
 	if fileHandling == DeleteFile {
 		// delete the file
 		if err := os.Remove(inputFilePath); err != nil {
-			return err
+			return nil, err
 		}
 		log.Printf("Input file deleted: %s", filename)
 	}
-	return nil
+	return publication, nil
 }
 
 // getUsernamePassword looks for the username and password in the url
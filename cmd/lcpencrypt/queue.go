@@ -0,0 +1,253 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// lcpencrypt job queue, stored in a local sqlite file
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Failure classes, which determine the retry policy of a job
+const (
+	FailSource  = "source"  // the source file could not be fetched
+	FailContent = "content" // the file could not be encrypted, e.g. a corrupt EPUB
+	FailStorage = "storage" // the encrypted file could not be stored
+	FailServer  = "server"  // the notification of the LCP Server failed
+	FailCMS     = "cms"     // the notification of the CMS failed
+	FailFile    = "file"    // local file error
+)
+
+// processError is an error of a stage of the processing of a file.
+type processError struct {
+	class string
+	err   error
+}
+
+func (e *processError) Error() string {
+	return e.err.Error()
+}
+
+func (e *processError) Unwrap() error {
+	return e.err
+}
+
+// failure returns an error of a given class.
+func failure(class string, err error) error {
+	return &processError{class: class, err: err}
+}
+
+// errorClass returns the failure class of an error; unclassified errors are local file errors.
+func errorClass(err error) string {
+	var pe *processError
+	if errors.As(err, &pe) {
+		return pe.class
+	}
+	return FailFile
+}
+
+// isNetworkError indicates if an error comes from the network, e.g. an unreachable s3 storage.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var urlErr *url.Error
+	return errors.As(err, &netErr) || errors.As(err, &urlErr)
+}
+
+// retryPolicy defines the number of retries of a failed job and the delay before the first retry,
+// doubled at each attempt.
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+}
+
+// maxBackoff is the maximum delay between two attempts.
+const maxBackoff = time.Hour
+
+var retryPolicies = map[string]retryPolicy{
+	FailSource:  {retries: 3, delay: time.Minute},
+	FailContent: {retries: 0},
+	FailStorage: {retries: 5, delay: time.Minute},
+	FailServer:  {retries: 8, delay: 30 * time.Second},
+	FailCMS:     {retries: 5, delay: time.Minute},
+	FailFile:    {retries: 1, delay: time.Minute},
+}
+
+// backoff returns the delay before the retry following an attempt.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 10 {
+		return maxBackoff
+	}
+	d := p.delay << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
+
+// jobQueue stores the jobs in a sqlite file, so that they survive a restart.
+type jobQueue struct {
+	db     *gorm.DB
+	wakeup chan struct{} // signals a new job to the dispatcher
+}
+
+// openJobQueue opens or creates the job queue.
+func openJobQueue(path string) (*jobQueue, error) {
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	// a single connection avoids lock errors between goroutines
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&Job{}); err != nil {
+		return nil, err
+	}
+	return &jobQueue{db: db, wakeup: make(chan struct{}, 1)}, nil
+}
+
+// requeueInterrupted makes the jobs interrupted by a stop of the server pending again.
+func (q *jobQueue) requeueInterrupted() error {
+	return q.db.Model(&Job{}).Where("status = ?", JobRunning).Update("status", JobPending).Error
+}
+
+// add stores a new pending job and signals it to the dispatcher.
+func (q *jobQueue) add(origin, file, dir string, opts JobOptions) (*Job, error) {
+
+	job := &Job{
+		ID:      uuid.New().String(),
+		Origin:  origin,
+		Status:  JobPending,
+		File:    file,
+		Dir:     dir,
+		Options: opts,
+		Created: time.Now(),
+	}
+	if err := q.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+	select {
+	case q.wakeup <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// get returns a job, or gorm.ErrRecordNotFound.
+func (q *jobQueue) get(id string) (*Job, error) {
+	var job Job
+	return &job, q.db.Where("id = ?", id).First(&job).Error
+}
+
+// list returns the most recent jobs, newest first, optionally with a given status.
+func (q *jobQueue) list(status string, limit int) ([]Job, error) {
+	var jobs []Job
+	tx := q.db.Order("created desc").Limit(limit)
+	if status != "" {
+		tx = tx.Where("status = ?", status)
+	}
+	return jobs, tx.Find(&jobs).Error
+}
+
+// counts returns the number of jobs per status.
+func (q *jobQueue) counts() (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := q.db.Model(&Job{}).Select("status, count(*) as count").Group("status").Scan(&rows).Error
+	counts := map[string]int64{JobPending: 0, JobRunning: 0, JobDone: 0, JobFailed: 0}
+	for _, r := range rows {
+		counts[r.Status] = r.Count
+	}
+	return counts, err
+}
+
+// isQueued indicates if a file is already waiting to be processed or being processed.
+func (q *jobQueue) isQueued(dir, file string) bool {
+	var n int64
+	q.db.Model(&Job{}).Where("dir = ? AND file = ? AND status IN ?", dir, file, []string{JobPending, JobRunning}).Count(&n)
+	return n > 0
+}
+
+// claim marks the oldest due job as running and returns it, or nil if no job is due.
+func (q *jobQueue) claim(now time.Time) (*Job, error) {
+
+	var job Job
+	err := q.db.Where("status = ? AND (next_attempt IS NULL OR next_attempt <= ?)", JobPending, now).
+		Order("created").First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Status = JobRunning
+	job.Attempts++
+	job.Started = &now
+	return &job, q.save(&job)
+}
+
+// save updates a job.
+func (q *jobQueue) save(job *Job) error {
+	return q.db.Save(job).Error
+}
+
+// dispatch runs the due jobs of the queue, at most one per slot of the semaphore, until the context is done.
+// The jobs being run are waited for via the wait group.
+func dispatch(ctx context.Context, c Config, q *jobQueue, sem chan struct{}, wg *sync.WaitGroup) {
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		for {
+			// wait for a free slot
+			select {
+			case <-ctx.Done():
+				return
+			case sem <- struct{}{}:
+			}
+			job, err := q.claim(time.Now())
+			if err != nil {
+				log.Errorf("Error reading the job queue: %v", err)
+			}
+			if err != nil || job == nil {
+				<-sem
+				break
+			}
+			wg.Add(1)
+			go func(job *Job) {
+				defer wg.Done()
+				defer func() { <-sem }() // free up a slot in the semaphore
+				runJob(ctx, c, q, job)
+			}(job)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wakeup:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestQueue opens a job queue in a temporary directory.
+func newTestQueue(t *testing.T) *jobQueue {
+	q, err := openJobQueue(filepath.Join(t.TempDir(), "jobs.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestErrorClass(t *testing.T) {
+
+	err := fmt.Errorf("processing: %w", failure(FailServer, errors.New("connection refused")))
+	if c := errorClass(err); c != FailServer {
+		t.Errorf("expected a server error, got %s", c)
+	}
+	if c := errorClass(errors.New("permission denied")); c != FailFile {
+		t.Errorf("expected a file error, got %s", c)
+	}
+	if !isNetworkError(&url.Error{Op: "Put", URL: "https://s3.example.com", Err: errors.New("timeout")}) {
+		t.Error("expected a network error")
+	}
+	if !isNetworkError(fmt.Errorf("storing: %w", &net.OpError{Op: "dial", Err: errors.New("refused")})) {
+		t.Error("expected a wrapped network error")
+	}
+	if isNetworkError(errors.New("invalid epub")) {
+		t.Error("unexpected network error")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	p := retryPolicy{retries: 5, delay: time.Minute}
+	for attempt, expected := range map[int]time.Duration{
+		0:  time.Minute,
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		7:  maxBackoff,
+		40: maxBackoff,
+	} {
+		if d := p.backoff(attempt); d != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, d)
+		}
+	}
+	// corrupt content is never retried
+	if retryPolicies[FailContent].retries != 0 {
+		t.Error("content errors must not be retried")
+	}
+}
+
+func TestClaim(t *testing.T) {
+
+	q := newTestQueue(t)
+	now := time.Now()
+
+	first, err := q.add(OriginWatch, "first.epub", "/in", JobOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := q.add(OriginAPI, "second.epub", "/up", JobOptions{})
+	later := now.Add(time.Hour)
+	second.NextAttempt = &later
+	q.save(second)
+
+	job, err := q.claim(now)
+	if err != nil || job == nil || job.ID != first.ID {
+		t.Fatalf("expected the first job, got %+v, %v", job, err)
+	}
+	if job.Status != JobRunning || job.Attempts != 1 || job.Started == nil {
+		t.Errorf("unexpected claimed job %+v", job)
+	}
+	if !q.isQueued("/in", "first.epub") || q.isQueued("/in", "other.epub") {
+		t.Error("unexpected queued state")
+	}
+
+	// the second job is not due yet
+	if job, err := q.claim(now); err != nil || job != nil {
+		t.Fatalf("expected no due job, got %+v, %v", job, err)
+	}
+	if job, _ := q.claim(later); job == nil || job.ID != second.ID {
+		t.Fatalf("expected the second job, got %+v", job)
+	}
+
+	// jobs interrupted by a stop are pending again
+	if err := q.requeueInterrupted(); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := q.counts()
+	if err != nil || counts[JobPending] != 2 || counts[JobRunning] != 0 {
+		t.Errorf("unexpected counts %v, %v", counts, err)
+	}
+	if job, _ := q.claim(later); job == nil || job.ID != first.ID || job.Attempts != 2 {
+		t.Errorf("expected a second attempt of the first job, got %+v", job)
+	}
+
+	jobs, err := q.list(JobPending, 10)
+	if err != nil || len(jobs) != 1 || jobs[0].ID != second.ID {
+		t.Errorf("unexpected pending jobs %+v, %v", jobs, err)
+	}
+}
@@ -9,6 +9,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -29,27 +30,75 @@ func activateServer(c Config) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var wg sync.WaitGroup
+	// the job queue keeps the files to process, from the watched directory and the http api, across restarts
+	queue, err := openJobQueue(c.QueuePath)
+	if err == nil {
+		err = queue.requeueInterrupted()
+	}
+	if err != nil {
+		log.Fatalf("Error opening the job queue %s: %v", c.QueuePath, err)
+	}
 
-	// process files already present in the input directory
-	processExistingFiles(c)
+	var wg sync.WaitGroup
 
+	// semaphore, limits processing to 4 concurrent files
+	sem := make(chan struct{}, 4)
+	dispatched := make(chan struct{})
 	go func() {
-		// semaphore, limits processing to 4 concurrent files
-		sem := make(chan struct{}, 4)
-		watchFileChanges(ctx, c, &wg, sem)
+		dispatch(ctx, c, queue, sem, &wg)
+		close(dispatched)
 	}()
 
+	// the http api receives encryption jobs (optional)
+	var api *http.Server
+	if c.APIAddr != "" {
+		uploads := filepath.Join(filepath.Dir(c.QueuePath), "lcpencrypt-uploads")
+		if err := os.MkdirAll(uploads, 0755); err != nil {
+			log.Fatalf("Error creating the upload directory: %v", err)
+		}
+		addr, err := apiListenAddr(c)
+		if err != nil {
+			log.Fatalf("Invalid http api address: %v", err)
+		}
+		api = &http.Server{
+			Addr:    addr,
+			Handler: newAPIRouter(c, queue, uploads),
+		}
+		go func() {
+			if err := api.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Http api error: %v", err)
+			}
+		}()
+	}
+
+	// the input directory is watched, unless only the http api is used
+	if c.watched() {
+		// queue files already present in the input directory
+		processExistingFiles(c, queue)
+
+		go func() {
+			watchFileChanges(ctx, c, queue)
+		}()
+	}
+
 	<-stop
 	log.Println("Shutdown requested, initiating graceful shutdown...")
-	cancel()  // signal the watcher to stop
-	wg.Wait() // wait for ongoing processing to finish
+	cancel() // signal the watcher and the dispatcher to stop
+	if api != nil {
+		// stop receiving jobs
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelShutdown()
+		api.Shutdown(shutdownCtx)
+	}
+	<-dispatched
+	wg.Wait() // wait for ongoing processing to finish; pending jobs are kept in the queue
 	log.Println("Server halted.")
 }
 
-// processExistingFiles processes files already present in the input directory
+// processExistingFiles queues files already present in the input directory,
+// except those already queued before a restart.
 // TODO: Move provider URI and input path to a map in config.
-func processExistingFiles(c Config) {
+func processExistingFiles(c Config, queue *jobQueue) {
 	files, err := os.ReadDir(c.InputPath)
 	if err != nil {
 		log.Printf("Error reading directory: %v", err)
@@ -64,18 +113,28 @@ func processExistingFiles(c Config) {
 			log.Printf("Ignoring .DS_Store file")
 			continue
 		}
-		log.Printf("File found: %s", file.Name())
-		err = processFile(c, file.Name(), DeleteFile)
-		if err != nil {
-			log.Errorf("Error processing file %s: %v", file.Name(), err)
+		if queue.isQueued(c.InputPath, file.Name()) {
+			continue
 		}
+		log.Printf("File found: %s", file.Name())
+		queueFile(c, queue, file.Name())
+	}
+}
+
+// queueFile adds a job for a file of the input directory.
+func queueFile(c Config, queue *jobQueue, fileName string) {
+	job, err := queue.add(OriginWatch, fileName, c.InputPath, JobOptions{})
+	if err != nil {
+		log.Errorf("Error queuing file %s: %v", fileName, err)
+		return
 	}
+	log.Infof("Job %s created, file %s", job.ID, fileName)
 }
 
 // watchFileChanges monitors changes in the input directory
 // TODO: Move provider URI and input path to a map in config.
 
-func watchFileChanges(ctx context.Context, c Config, wg *sync.WaitGroup, sem chan struct{}) {
+func watchFileChanges(ctx context.Context, c Config, queue *jobQueue) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatalf("Error creating watcher: %v", err)
@@ -99,22 +158,19 @@ func watchFileChanges(ctx context.Context, c Config, wg *sync.WaitGroup, sem cha
 			// Only listen to Create events to avoid duplicate processing on Write
 			if event.Op&fsnotify.Create == fsnotify.Create {
 				log.Printf("File created: %s", event.Name)
-				sem <- struct{}{} // block if 4 processes are already running
-				wg.Add(1)
 				go func(filePath string) {
-					defer wg.Done()
-					defer func() { <-sem }() // free up a slot in the semaphore
-
+					// a directory, e.g. the processed or failed directory, is not a publication
+					if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+						return
+					}
 					// Wait for the file to be ready (not empty and stable)
 					if err := waitForFileReady(filePath); err != nil {
 						log.Errorf("Skipping file %s: %v", filePath, err)
 						return
 					}
-
 					fileName := filepath.Base(filePath)
-					err = processFile(c, fileName, DeleteFile)
-					if err != nil {
-						log.Errorf("Error processing file %s: %v", fileName, err)
+					if !queue.isQueued(c.InputPath, fileName) {
+						queueFile(c, queue, fileName)
 					}
 				}(event.Name)
 			}
@@ -0,0 +1,85 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// lcpencrypt status command, showing the content of the job queue
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// statusDoneLimit is the number of completed jobs shown by the status command.
+const statusDoneLimit = 20
+
+// showStatus prints the number of jobs per status, then the pending and failed jobs with their errors,
+// and the most recent completed jobs.
+func showStatus(c Config) error {
+
+	if _, err := os.Stat(c.QueuePath); err != nil {
+		return err
+	}
+	queue, err := openJobQueue(c.QueuePath)
+	if err != nil {
+		return err
+	}
+	counts, err := queue.counts()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Jobs: %d pending, %d running, %d done, %d failed\n",
+		counts[JobPending], counts[JobRunning], counts[JobDone], counts[JobFailed])
+
+	for _, status := range []string{JobRunning, JobPending, JobFailed, JobDone} {
+		limit := -1
+		if status == JobDone {
+			limit = statusDoneLimit
+		}
+		jobs, err := queue.list(status, limit)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s\n", status)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFILE\tORIGIN\tATTEMPTS\tDATE\tDETAIL")
+		for _, j := range jobs {
+			date, detail := formatTime(&j.Created), ""
+			switch j.Status {
+			case JobPending:
+				if j.NextAttempt != nil {
+					date = formatTime(j.NextAttempt)
+				}
+				if j.Error != "" {
+					detail = j.ErrorClass + ": " + j.Error
+				}
+			case JobRunning:
+				date = formatTime(j.Started)
+			case JobFailed:
+				date = formatTime(j.Finished)
+				detail = j.ErrorClass + ": " + j.Error
+			case JobDone:
+				date = formatTime(j.Finished)
+				if j.Publication != nil {
+					detail = j.Publication.UUID
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", j.ID, j.File, j.Origin, j.Attempts, date, detail)
+		}
+		w.Flush()
+	}
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
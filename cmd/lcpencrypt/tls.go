@@ -0,0 +1,46 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// configureClientTLS sets the client certificate and trusted CA used by every http request
+// of the utility, including the notifications sent to the LCP Server.
+func configureClientTLS(c Config) error {
+	if c.ClientCert == "" && c.ServerCA == "" {
+		return nil
+	}
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.New("unexpected default http transport")
+	}
+	tc := &tls.Config{}
+	if c.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	if c.ServerCA != "" {
+		pem, err := os.ReadFile(c.ServerCA)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificate found in the server CA file")
+		}
+		tc.RootCAs = pool
+	}
+	transport.TLSClientConfig = tc
+	return nil
+}
@@ -7,11 +7,11 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -35,8 +35,9 @@ func validateCredentials(username, password string, config *conf.Config) bool {
 }
 
 // Login creates a login handler using the provided configuration
-func Login(config *conf.Config) http.HandlerFunc {
+func Login(live *conf.Live) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		config := live.Config()
 		var creds Credentials
 		err := json.NewDecoder(r.Body).Decode(&creds)
 		if err != nil {
@@ -46,12 +47,12 @@ func Login(config *conf.Config) http.HandlerFunc {
 
 		// Check credentials using configured dashboard accounts
 		if !validateCredentials(creds.Username, creds.Password, config) {
-			log.Printf("🚫 Connection attempt failed for user: %s", creds.Username)
+			logging.FromRequest(r).WithField("user", creds.Username).Warn("🚫 Connection attempt failed")
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		log.Println("🔐 User logged in:", creds.Username)
+		logging.FromRequest(r).WithField("user", creds.Username).Info("🔐 User logged in")
 
 		// Create JWT token using the configured secret key
 		expirationTime := time.Now().Add(1 * time.Hour)
@@ -98,9 +99,10 @@ func Login(config *conf.Config) http.HandlerFunc {
 }
 
 // AuthMiddleware creates JWT authentication middleware using the provided configuration
-func AuthMiddleware(config *conf.Config) func(http.Handler) http.Handler {
+func AuthMiddleware(live *conf.Live) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			config := live.Config()
 			var tokenStr string
 
 			// Try to get a token from the Authorization header first (Bearer token)
@@ -131,7 +133,7 @@ func AuthMiddleware(config *conf.Config) func(http.Handler) http.Handler {
 			})
 
 			if err != nil {
-				log.Println("JWT parse error:", err)
+				logging.FromRequest(r).WithError(err).Warn("JWT parse error")
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusUnauthorized)
 
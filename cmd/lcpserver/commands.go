@@ -0,0 +1,90 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+)
+
+// runCommand executes an lcpserver sub-command and returns the exit code of the process.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "config":
+		if len(args) > 1 && args[1] == "check" {
+			return configCheck(args[2:])
+		}
+	case "import-v1":
+		return importV1(args[1:])
+	case "help", "-h", "-help", "--help":
+		commandUsage()
+		return 0
+	}
+	commandUsage()
+	return 2
+}
+
+func commandUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  lcpserver                 start the server")
+	fmt.Println("  lcpserver config check    validate the configuration")
+	fmt.Println("  lcpserver import-v1       import the databases of a readium-lcp-server v1")
+}
+
+// configCheck validates the configuration and reports every problem found.
+func configCheck(args []string) int {
+	fs := flag.NewFlagSet("config check", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("LCPSERVER_CONFIG"), "path to the configuration file")
+	asJSON := fs.Bool("json", false, "output the problems as json")
+	fs.Parse(args)
+
+	// keep the output clean
+	log.SetLevel(log.ErrorLevel)
+
+	c, err := conf.Init(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration failed: "+err.Error())
+		return 1
+	}
+	problems := c.Validate()
+
+	if *asJSON {
+		if problems == nil {
+			problems = conf.Problems{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(problems)
+	} else {
+		for _, p := range problems {
+			fmt.Println(p.String())
+		}
+		if len(problems) == 0 {
+			fmt.Println("The configuration is valid.")
+		}
+	}
+	if problems.HasErrors() {
+		return 1
+	}
+	return 0
+}
+
+// logProblems logs configuration problems at startup.
+func logProblems(problems conf.Problems) {
+	for _, p := range problems {
+		entry := log.WithField("field", p.Field)
+		if p.Severity == conf.SeverityError {
+			entry.Error("Configuration error: " + p.Message)
+		} else {
+			entry.Warn("Configuration warning: " + p.Message)
+		}
+	}
+}
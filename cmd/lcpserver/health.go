@@ -0,0 +1,141 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/sign"
+)
+
+// Health check status values
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// CheckResult is the result of a single readiness check.
+type CheckResult struct {
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	DurationMs   int64      `json:"duration_ms"`
+	DaysToExpiry *int       `json:"days_to_expiry,omitempty"`
+	NotAfter     *time.Time `json:"not_after,omitempty"`
+}
+
+// ReadinessReport is the response payload of the readiness endpoint.
+type ReadinessReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// livez reports that the process is alive. It does not check any dependency,
+// so that an orchestrator doesn't restart the server because of a database failure.
+func (s *Server) livez(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": checkOK})
+}
+
+// readyz reports whether the server is able to process requests.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+
+	report := ReadinessReport{
+		Status: "ready",
+		Checks: make(map[string]CheckResult),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	report.Checks["database"] = runCheck(func() error { return s.Store.Ping(ctx) })
+	report.Checks["migrations"] = runCheck(s.Store.CheckMigrations)
+	report.Checks["signature"] = runCheck(s.checkSignature)
+	report.Checks["certificate"] = s.checkCertificate()
+
+	if s.draining.Load() {
+		report.Checks["shutdown"] = CheckResult{Status: checkFail, Error: "shutdown in progress"}
+	}
+
+	code := http.StatusOK
+	for _, check := range report.Checks {
+		if check.Status == checkFail {
+			report.Status = "not_ready"
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+	writeHealth(w, code, report)
+}
+
+// runCheck times a check and converts its outcome to a check result.
+func runCheck(check func() error) CheckResult {
+	start := time.Now()
+	err := check()
+	res := CheckResult{Status: checkOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = checkFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// checkSignature signs then verifies a test structure with the provider certificate.
+func (s *Server) checkSignature() error {
+	if s.Cert == nil || len(s.Cert.Certificate) == 0 {
+		return errors.New("no certificate loaded")
+	}
+	signer, err := sign.NewSigner(s.Cert)
+	if err != nil {
+		return err
+	}
+	probe := map[string]string{"probe": time.Now().Format(time.RFC3339Nano)}
+	sig, err := signer.Sign(probe)
+	if err != nil {
+		return err
+	}
+	checker, err := sign.NewSignChecker(sig.Certificate, sig.Algorithm)
+	if err != nil {
+		return err
+	}
+	return checker.Check(probe, sig.Value)
+}
+
+// checkCertificate computes the number of days before the provider certificate expires.
+// A warning is reported when the expiry date is near; an expired certificate fails the check.
+func (s *Server) checkCertificate() CheckResult {
+	if s.Cert == nil || len(s.Cert.Certificate) == 0 {
+		return CheckResult{Status: checkFail, Error: "no certificate loaded"}
+	}
+	leaf := s.Cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(s.Cert.Certificate[0]); err != nil {
+			return CheckResult{Status: checkFail, Error: err.Error()}
+		}
+	}
+	days := int(time.Until(leaf.NotAfter).Hours() / 24)
+	res := CheckResult{Status: checkOK, DaysToExpiry: &days, NotAfter: &leaf.NotAfter}
+	switch {
+	case time.Now().After(leaf.NotAfter):
+		res.Status = checkFail
+		res.Error = "the certificate has expired"
+	case days < s.Config().Health.CertExpiryWarningDays:
+		res.Status = checkWarn
+		res.Error = "the certificate expires soon"
+	}
+	return res
+}
+
+func writeHealth(w http.ResponseWriter, code int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
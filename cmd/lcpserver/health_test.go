@@ -0,0 +1,139 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// newTestCert returns a self-signed certificate expiring at a given date.
+func newTestCert(t *testing.T, notAfter time.Time) *tls.Certificate {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test provider"},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// newTestServer returns a server on a database in a temporary directory, with a certificate expiring in a year.
+func newTestServer(t *testing.T) *Server {
+	c := &conf.Config{Health: conf.Health{CertExpiryWarningDays: 30}}
+	st, err := stor.Init("sqlite3://file:" + filepath.Join(t.TempDir(), "lcp.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Live: conf.NewLive(c), Store: st, Cert: newTestCert(t, time.Now().AddDate(1, 0, 0))}
+}
+
+func readiness(t *testing.T, s *Server) (int, ReadinessReport) {
+	rr := httptest.NewRecorder()
+	s.readyz(rr, httptest.NewRequest("GET", "/readyz", nil))
+	var report ReadinessReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Cache-Control") != "no-store" {
+		t.Error("a health report must not be cached")
+	}
+	return rr.Code, report
+}
+
+func TestLivez(t *testing.T) {
+
+	s := &Server{}
+	rr := httptest.NewRecorder()
+	s.livez(rr, httptest.NewRequest("GET", "/livez", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "{\"status\":\"ok\"}\n" {
+		t.Errorf("unexpected response %d %s", rr.Code, rr.Body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+
+	s := newTestServer(t)
+	code, report := readiness(t, s)
+	if code != http.StatusOK || report.Status != "ready" {
+		t.Fatalf("unexpected report %d %+v", code, report)
+	}
+	for _, name := range []string{"database", "migrations", "signature", "certificate"} {
+		if report.Checks[name].Status != checkOK {
+			t.Errorf("unexpected %s check %+v", name, report.Checks[name])
+		}
+	}
+
+	// a shutdown is in progress
+	s.draining.Store(true)
+	code, report = readiness(t, s)
+	if code != http.StatusServiceUnavailable || report.Status != "not_ready" || report.Checks["shutdown"].Status != checkFail {
+		t.Errorf("unexpected report while draining %d %+v", code, report)
+	}
+	s.draining.Store(false)
+
+	// no certificate
+	s.Cert = nil
+	code, report = readiness(t, s)
+	if code != http.StatusServiceUnavailable || report.Checks["signature"].Status != checkFail || report.Checks["certificate"].Status != checkFail {
+		t.Errorf("unexpected report without certificate %d %+v", code, report)
+	}
+}
+
+func TestCheckCertificate(t *testing.T) {
+
+	s := newTestServer(t)
+	for _, c := range []struct {
+		notAfter time.Time
+		status   string
+		days     int
+	}{
+		{time.Now().Add(100*24*time.Hour + time.Hour), checkOK, 100},
+		{time.Now().Add(10*24*time.Hour + time.Hour), checkWarn, 10},
+		{time.Now().Add(-time.Hour), checkFail, 0},
+	} {
+		s.Cert = newTestCert(t, c.notAfter)
+		res := s.checkCertificate()
+		if res.Status != c.status || res.DaysToExpiry == nil || *res.DaysToExpiry != c.days || !res.NotAfter.Equal(c.notAfter.Truncate(time.Second)) {
+			t.Errorf("expiry %s: unexpected result %+v", c.notAfter, res)
+		}
+	}
+
+	// an expired certificate makes the server not ready, an expiring one is a warning
+	if code, report := readiness(t, s); code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected report with an expired certificate %d %+v", code, report)
+	}
+	s.Cert = newTestCert(t, time.Now().AddDate(0, 0, 10))
+	if code, report := readiness(t, s); code != http.StatusOK || report.Checks["certificate"].Status != checkWarn {
+		t.Errorf("unexpected report with an expiring certificate %d %+v", code, report)
+	}
+
+	// the certificate is unparsable
+	s.Cert = &tls.Certificate{Certificate: [][]byte{[]byte("not a certificate")}}
+	if res := s.checkCertificate(); res.Status != checkFail {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
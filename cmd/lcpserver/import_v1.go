@@ -0,0 +1,86 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/legacy"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// importV1 imports the LCP and LSD databases of a readium-lcp-server v1 into the database of the server,
+// then prints a reconciliation report. An interrupted import is resumed by running the command again.
+func importV1(args []string) int {
+	fs := flag.NewFlagSet("import-v1", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("LCPSERVER_CONFIG"), "path to the configuration file")
+	lcpDsn := fs.String("lcp", "", "dsn of the v1 LCP database (content and license tables)")
+	lsdDsn := fs.String("lsd", "", "dsn of the v1 LSD database (license_status and event tables), the LCP database by default")
+	contentURL := fs.String("content-url", "", "base url of the v1 storage, for contents whose location is a file name")
+	batch := fs.Int("batch", legacy.DefaultBatchSize, "number of rows imported per transaction")
+	restart := fs.Bool("restart", false, "ignore the checkpoints of a previous import")
+	reportFile := fs.String("report", "", "path of the json report, the standard output by default")
+	fs.Parse(args)
+
+	if *lcpDsn == "" {
+		fmt.Fprintln(os.Stderr, "Missing the dsn of the v1 LCP database (-lcp)")
+		fs.Usage()
+		return 2
+	}
+	if *lsdDsn == "" {
+		*lsdDsn = *lcpDsn
+	}
+
+	c, err := conf.Init(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Configuration failed: "+err.Error())
+		return 1
+	}
+	lcp, err := stor.Connect(*lcpDsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "LCP database: "+err.Error())
+		return 1
+	}
+	lsd, err := stor.Connect(*lsdDsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "LSD database: "+err.Error())
+		return 1
+	}
+	target, err := stor.Open(c.Dsn)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Database: "+err.Error())
+		return 1
+	}
+
+	im := legacy.NewImporter(lcp, lsd, target, legacy.Options{
+		ContentURL: *contentURL,
+		BatchSize:  *batch,
+		Restart:    *restart,
+	})
+	report, runErr := im.Run()
+
+	out := os.Stdout
+	if *reportFile != "" {
+		if out, err = os.Create(*reportFile); err != nil {
+			fmt.Fprintln(os.Stderr, "Report: "+err.Error())
+			return 1
+		}
+		defer out.Close()
+	}
+	if report != nil {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	}
+	if runErr != nil {
+		fmt.Fprintln(os.Stderr, "Import failed, run the command again to resume: "+runErr.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/ratelimit"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// newRateLimitStore returns the store of rate limiting buckets selected by the configuration.
+func newRateLimitStore(c *conf.Config, st stor.Store) ratelimit.Store {
+	if c.RateLimit.Store == conf.RateLimitDatabase {
+		return ratelimit.NewSharedStore(st)
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// rateLimit limits the requests per client ip, per license and per device on the status document routes.
+// The limits are read from the live configuration on each request.
+func rateLimit(live *conf.Live, store ratelimit.Store) func(http.Handler) http.Handler {
+	suspicious := &suspicionLog{last: make(map[string]time.Time)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := live.Config().RateLimit
+			if !c.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip := clientIP(r, c.TrustProxy, c.Proxies())
+
+			checks := []struct {
+				dimension string
+				value     string
+				limit     conf.Limit
+			}{
+				{"ip", ip, c.PerIP},
+				{"license", chi.URLParam(r, "licenseID"), c.PerLicense},
+				{"device", r.URL.Query().Get("id"), c.PerDevice},
+			}
+			for _, check := range checks {
+				limit := ratelimit.Limit{PerMinute: check.limit.Rate(), Burst: check.limit.Burst}
+				if check.value == "" || !limit.Enabled() {
+					continue
+				}
+				ok, retryAfter, err := store.Take(r.Context(), check.dimension+":"+check.value, limit)
+				if err != nil {
+					// don't block legitimate users because of a storage failure
+					logging.FromRequest(r).Errorf("Rate limiting failed: %v", err)
+					continue
+				}
+				if ok {
+					continue
+				}
+				if suspicious.shouldLog(check.dimension + ":" + check.value) {
+					logging.FromRequest(r).WithFields(log.Fields{
+						"suspicious": true,
+						"dimension":  check.dimension,
+						"key":        check.value,
+						"client_ip":  ip,
+						"license_id": chi.URLParam(r, "licenseID"),
+						"device_id":  r.URL.Query().Get("id"),
+					}).Warn("Rate limit exceeded")
+				}
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				render.Render(w, r, api.ErrTooManyRequests(fmt.Errorf("too many requests per %s, retry in %d seconds", check.dimension, seconds)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the ip address of the client. If the server is behind a trusted reverse proxy,
+// the address set by the proxy in the X-Forwarded-For or X-Real-IP header is used.
+// The client may send its own X-Forwarded-For header, which the proxies complete: the header is read
+// from the right, skipping the trusted proxies, and the first other address is the one of the client.
+// Without a list of trusted proxies, only the peer address is trusted, i.e. a single proxy.
+func clientIP(r *http.Request, trustProxy bool, proxies []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !trustProxy || (len(proxies) > 0 && !trusted(peer, proxies)) {
+		return peer
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(hops[i])
+			if ip == "" || (i > 0 && trusted(ip, proxies)) {
+				continue
+			}
+			return ip
+		}
+	}
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return strings.TrimSpace(xri)
+	}
+	return peer
+}
+
+// trusted indicates if an ip address belongs to the trusted proxies.
+func trusted(ip string, proxies []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range proxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// suspicionLog avoids flooding the logs during an attack:
+// a limited key is logged at most once per minute.
+type suspicionLog struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (s *suspicionLog) shouldLog(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if t, ok := s.last[key]; ok && now.Sub(t) < time.Minute {
+		return false
+	}
+	if len(s.last) > 10000 {
+		s.last = make(map[string]time.Time)
+	}
+	s.last[key] = now
+	return true
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	cases := []struct {
+		name       string
+		remote     string
+		xff        string
+		trustProxy bool
+		proxies    []*net.IPNet
+		expected   string
+	}{
+		{"no proxy", "203.0.113.7:4000", "198.51.100.1", false, nil, "203.0.113.7"},
+		{"single proxy", "10.0.0.2:4000", "198.51.100.1", true, nil, "198.51.100.1"},
+		{"forged header, single proxy", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1", true, nil, "198.51.100.1"},
+		{"proxy chain", "10.0.0.2:4000", "1.2.3.4, 198.51.100.1, 10.0.0.9", true, []*net.IPNet{proxies}, "198.51.100.1"},
+		{"untrusted peer", "203.0.113.7:4000", "198.51.100.1", true, []*net.IPNet{proxies}, "203.0.113.7"},
+		{"no header", "10.0.0.2:4000", "", true, nil, "10.0.0.2"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.xff != "" {
+			r.Header.Set("X-Forwarded-For", c.xff)
+		}
+		if ip := clientIP(r, c.trustProxy, c.proxies); ip != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, ip)
+		}
+	}
+}
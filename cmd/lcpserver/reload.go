@@ -0,0 +1,131 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
+)
+
+// reloadDebounce groups the file events generated by a single save of the configuration file
+const reloadDebounce = 500 * time.Millisecond
+
+// watchConfig reloads the configuration when a SIGHUP is received
+// or when the configuration file is modified.
+func (s *Server) watchConfig() {
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("SIGHUP received, reloading the configuration")
+			s.reloadConfig()
+		}
+	}()
+
+	if s.ConfigFile == "" {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Errorf("Error creating the configuration watcher: %v", err)
+		return
+	}
+	// watch the directory rather than the file, as editors and kubernetes
+	// replace the file instead of writing into it
+	dir := filepath.Dir(s.ConfigFile)
+	if err = watcher.Add(dir); err != nil {
+		log.Errorf("Error watching the configuration directory %s: %v", dir, err)
+		watcher.Close()
+		return
+	}
+	go s.watchConfigFile(watcher)
+}
+
+// watchConfigFile processes the events of the configuration watcher.
+func (s *Server) watchConfigFile(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	base := filepath.Base(s.ConfigFile)
+	var mu sync.Mutex
+	var timer *time.Timer
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			// kubernetes config maps are updated by swapping a ..data symlink
+			name := filepath.Base(event.Name)
+			if name != base && name != "..data" {
+				continue
+			}
+			mu.Lock()
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDebounce, func() {
+				log.Printf("Configuration file %s changed, reloading the configuration", s.ConfigFile)
+				s.reloadConfig()
+			})
+			mu.Unlock()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching the configuration: %v", err)
+		}
+	}
+}
+
+// reloadMutex serializes reloads triggered by signals and file events
+var reloadMutex sync.Mutex
+
+// reloadConfig reads the configuration file and environment variables again,
+// validates the new configuration and swaps it in. An invalid configuration is rejected
+// and the current one is kept. Settings which require a restart keep their current value.
+func (s *Server) reloadConfig() bool {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	c, err := conf.Init(s.ConfigFile)
+	if err != nil {
+		log.Errorf("Configuration reload failed, the current configuration is kept: %v", err)
+		return false
+	}
+	problems := c.Validate()
+	logProblems(problems)
+	if problems.HasErrors() {
+		log.Error("Invalid configuration, the current configuration is kept")
+		return false
+	}
+
+	prev := s.Config()
+	for _, field := range prev.StaticChanges(c) {
+		log.WithField("field", field).Warn("Configuration change ignored, it requires a restart; the previous value is kept")
+	}
+	c.KeepStatic(prev)
+
+	if err := logging.Init(c.LogLevel, c.LogFormat); err != nil {
+		log.Errorf("Logger setup failed: %v", err)
+	}
+	s.Swap(c)
+	log.Println("Configuration reloaded")
+	return true
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+// writeConfig writes a configuration file serving publications from a directory.
+func writeConfig(t *testing.T, path, storageDir string, validity int) {
+	content := `public_base_url: "http://localhost:8989"
+dsn: "sqlite3://file::memory:?cache=shared"
+log_format: "text"
+access:
+  username: "user"
+  password: "password"
+certificate:
+  cert: "../../pkg/test/cert/cert-edrlab-test.pem"
+  private_key: "../../pkg/test/cert/privkey-edrlab-test.pem"
+license:
+  provider: "http://edrlab.org"
+  profile: "http://readium.org/lcp/basic-profile"
+  hint_link: "https://www.edrlab.org/lcp-help/{license_id}"
+status:
+  fresh_license_link: "http://localhost:8989/licenses/{license_id}"
+storage:
+  mode: "fs"
+  directory: "` + storageDir + `"
+  secret: "a-long-secret-used-to-sign-download-links"
+  validity: ` + strconv.Itoa(validity) + `
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadStorage(t *testing.T) {
+
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	for _, d := range []string{first, second} {
+		os.Mkdir(d, 0755)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	writeConfig(t, path, first, 60)
+	c, err := conf.Init(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Live: conf.NewLive(c), ConfigFile: path}
+
+	var buf bytes.Buffer
+	out, level, formatter := log.StandardLogger().Out, log.GetLevel(), log.StandardLogger().Formatter
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetOutput(out)
+		log.SetLevel(level)
+		log.SetFormatter(formatter)
+	}()
+
+	// the storage backend is built at startup: its directory is kept, the validity of links is live
+	writeConfig(t, path, second, 30)
+	if !s.reloadConfig() {
+		t.Fatalf("the reload failed: %s", buf.String())
+	}
+	if s.Config().Storage.Directory != first || s.Config().Storage.Validity != 30 {
+		t.Errorf("unexpected storage configuration %+v", s.Config().Storage)
+	}
+	if !strings.Contains(buf.String(), "field=storage.directory") || !strings.Contains(buf.String(), "requires a restart") {
+		t.Errorf("expected a warning about the storage directory, got %s", buf.String())
+	}
+}
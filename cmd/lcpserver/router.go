@@ -17,12 +17,40 @@ import (
 	"github.com/go-chi/render"
 
 	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
 )
 
-func (s *Server) setRoutes() *chi.Mux {
+// setRoutes sets the public router and, if a dedicated private listener is configured, the private router.
+func (s *Server) setRoutes() {
 
 	// Set api controller dependencies
-	a := api.NewAPICtrl(s.Config, s.Store, s.Cert)
+	a := api.NewAPICtrl(s.Live, s.Store, s.Cert)
+	a.Storage = s.Storage
+	s.API = a
+
+	// Settings used to build the routes, which cannot be changed without a restart
+	cfg := s.Config()
+
+	if !cfg.Listeners.Split() {
+		// a single listener serves every route
+		s.Router = s.newRouter(cfg, func(r chi.Router) {
+			s.publicRoutes(r, a, cfg)
+			privateRoutes(r, a, cfg)
+		})
+		return
+	}
+	s.Router = s.newRouter(cfg, func(r chi.Router) {
+		s.publicRoutes(r, a, cfg)
+	})
+	s.PrivateRouter = s.newRouter(cfg, func(r chi.Router) {
+		privateRoutes(r, a, cfg)
+	})
+}
+
+// newRouter returns a router with the common middleware stack, health routes and
+// the routes added by the routes function.
+func (s *Server) newRouter(cfg *conf.Config, routes func(r chi.Router)) *chi.Mux {
 
 	// Define the router
 	r := chi.NewRouter()
@@ -30,32 +58,49 @@ func (s *Server) setRoutes() *chi.Mux {
 	// Recovery middleware
 	r.Use(middleware.Recoverer)
 
-	// Heartbeat (excluded from logs)
+	// Request identifier, generated or propagated
+	r.Use(logging.RequestIDMiddleware)
+
+	// Heartbeat and probes (excluded from logs)
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("The LCP Server is running!"))
 	})
+	r.Get("/livez", s.livez)   // GET /livez
+	r.Get("/readyz", s.readyz) // GET /readyz
 
 	// Group for all other routes
 	r.Group(func(r chi.Router) {
-		// Logger middleware
-		r.Use(middleware.Logger)
+		// Structured logger middleware
+		r.Use(logging.Middleware)
 
 		r.NotFound(notFoundProblemDetail)
 
 		// CORS Configuration
 		r.Use(cors.Handler(cors.Options{
-			AllowedOrigins:   []string{"http://localhost:8090", "http://localhost:8091"}, // URLs of the React frontend
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			ExposedHeaders:   []string{"Link"},
-			AllowCredentials: true,
+			AllowedOrigins:   cfg.CORS.AllowedOrigins, // URLs of the frontends
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", logging.RequestIDHeader},
+			ExposedHeaders:   []string{"Link", logging.RequestIDHeader},
+			AllowCredentials: *cfg.CORS.AllowCredentials,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
 		}))
 
+		routes(r)
+	})
+
+	return r
+}
+
+// publicRoutes sets the routes called by reading applications: status documents and static resources.
+func (s *Server) publicRoutes(r chi.Router, a *api.APICtrl, cfg *conf.Config) {
+
+	r.Group(func(r chi.Router) {
+		r.Use(maxBodyBytes(cfg.Listeners.Public.MaxBodyBytes))
+
 		// Static resources management (optional)
-		if s.Config.Resources != "" {
+		if cfg.Resources != "" {
 			r.Group(func(r chi.Router) {
-				resourceDir := s.Config.Resources
+				resourceDir := cfg.Resources
 				path := "/resources/*"
 
 				r.Get(path, func(w http.ResponseWriter, r *http.Request) {
@@ -69,35 +114,70 @@ func (s *Server) setRoutes() *chi.Mux {
 
 		// Status document management
 		r.Group(func(r chi.Router) {
+			r.Use(a.Localize)
+			r.Use(rateLimit(s.Live, newRateLimitStore(cfg, s.Store)))
 			r.Use(render.SetContentType(render.ContentTypeJSON))
-			r.Get("/status/{licenseID}", a.StatusDoc)   // GET /status/123
-			r.Post("/register/{licenseID}", a.Register) // POST /register/123
-			r.Put("/renew/{licenseID}", a.Renew)        // PUT /renew/123
-			r.Put("/return/{licenseID}", a.Return)      // PUT /return/123
+			r.Get("/status/{licenseID}", a.StatusDoc)                          // GET /status/123
+			r.With(paginate).Get("/status/{licenseID}/events", a.StatusEvents) // GET /status/123/events
+			r.Post("/register/{licenseID}", a.Register)                        // POST /register/123
+			r.Put("/renew/{licenseID}", a.Renew)                               // PUT /renew/123
+			r.Put("/return/{licenseID}", a.Return)                             // PUT /return/123
+			r.Get("/fresh/{licenseID}", a.SignedFreshLicense)                  // GET /fresh/123{?expires,sig}
 		})
 
-		// Private Routes
-		// Require Authentication
-		credentials := make(map[string]string)
-		credentials[s.Config.Access.Username] = s.Config.Access.Password
+		// Download of encrypted publications (optional), not rate limited as readers send many range requests
+		r.Group(func(r chi.Router) {
+			r.Get("/download/publications/{publicationID}", a.DownloadPublication) // GET /download/publications/123{?expires,sig}
+			r.Get("/download/licenses/{licenseID}", a.DownloadLicensedPublication) // GET /download/licenses/123{?expires,sig}
+		})
+	})
+}
+
+// v1Routes sets the routes of the readium-lcp-server v1 REST api, mapped onto the current api.
+func v1Routes(a *api.APICtrl) func(r chi.Router) {
+	return func(r chi.Router) {
+		r.Route("/contents/{publicationID}", func(r chi.Router) {
+			r.Get("/info", a.V1ContentInfo)          // GET /v1/contents/123/info
+			r.Put("/", a.V1StoreContent)             // PUT /v1/contents/123
+			r.Delete("/", a.DeletePublication)       // DELETE /v1/contents/123
+			r.Post("/license", a.V1GenerateLicense)  // POST /v1/contents/123/license
+			r.Post("/licenses", a.V1GenerateLicense) // POST /v1/contents/123/licenses, deprecated in v1
+		})
+		r.Get("/licenses/{licenseID}", a.V1GetLicense)  // GET /v1/licenses/123
+		r.Post("/licenses/{licenseID}", a.V1GetLicense) // POST /v1/licenses/123
+	}
+}
+
+// privateRoutes sets the routes called by the provider's back-office and by the dashboard.
+func privateRoutes(r chi.Router, a *api.APICtrl, cfg *conf.Config) {
+
+	r.Group(func(r chi.Router) {
+		r.Use(maxBodyBytes(cfg.Listeners.Private.MaxBodyBytes))
 
+		// Private Routes
+		// Require Authentication, basic auth or client certificate
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.BasicAuth("restricted", credentials))
+			r.Use(privateAuth(cfg))
 			r.Use(render.SetContentType(render.ContentTypeJSON))
 
 			// Publications, CRUD
 			r.Route("/publications", func(r chi.Router) {
-				r.With(paginate).Get("/", a.ListPublications)         // GET /publications/
-				r.With(paginate).Get("/search", a.SearchPublications) // GET /publications/search{?format}
-				r.Post("/", a.CreatePublication)                      // POST /publications
+				r.With(paginate).Get("/", a.ListPublications)             // GET /publications/
+				r.With(paginate).Get("/search", a.SearchPublications)     // GET /publications/search{?format}
+				r.Post("/", a.CreatePublication)                          // POST /publications
+				r.Post("/refresh", a.RefreshPublications)                 // POST /publications/refresh
+				r.With(paginate).Get("/trash", a.ListDeletedPublications) // GET /publications/trash
 
 				r.Route("/{publicationID}", func(r chi.Router) {
-					r.Get("/", a.GetPublication)       // GET /publications/123
-					r.Put("/", a.UpdatePublication)    // PUT /publications/123
-					r.Delete("/", a.DeletePublication) // DELETE /publications/123
+					r.Get("/", a.GetPublication)                       // GET /publications/123
+					r.Put("/", a.UpdatePublication)                    // PUT /publications/123
+					r.Delete("/", a.DeletePublication)                 // DELETE /publications/123
+					r.Post("/takedown", a.TakedownPublication)         // POST /publications/123/takedown
+					r.Post("/restore", a.RestorePublication)           // POST /publications/123/restore
+					r.Get("/download-link", a.PublicationDownloadLink) // GET /publications/123/download-link
 				})
 				// get publication by AltID
-				r.Get("/altid/{altID}", a.GetPublicationByAltID) // GET /publications/altid/alt123	
+				r.Get("/altid/{altID}", a.GetPublicationByAltID) // GET /publications/altid/alt123
 			})
 
 			// LicenseInfo, CRUD
@@ -115,44 +195,72 @@ func (s *Server) setRoutes() *chi.Mux {
 
 			// License events
 			r.Route("/license-events/{licenseID}", func(r chi.Router) {
-				r.Get("/", a.ListLicenseEvents) // GET /license-events/123
+				r.With(paginateIfRequested).Get("/", a.ListLicenseEvents) // GET /license-events/123{?page,per_page,type,device,since,until}
 			})
+			r.With(paginate).Get("/events", a.ListEvents) // GET /events{?license,type,device,since,until}
 
 			// License generation
 			r.Route("/licenses", func(r chi.Router) {
-				r.Post("/", a.GenerateLicense) // POST /licenses
+				r.Post("/", a.GenerateLicense)       // POST /licenses
+				r.Post("/batch", a.GenerateLicenses) // POST /licenses/batch{?async}
 
 				r.Route("/{licenseID}", func(r chi.Router) {
-					r.Post("/", a.FreshLicense) // POST /licenses/123
+					r.Post("/", a.FreshLicense)                    // POST /licenses/123
+					r.Get("/download-link", a.LicenseDownloadLink) // GET /licenses/123/download-link
 				})
 			})
 
+			// Background jobs
+			r.Route("/jobs/{jobID}", func(r chi.Router) {
+				r.Get("/", a.GetJob)               // GET /jobs/123
+				r.Get("/results", a.GetJobResults) // GET /jobs/123/results
+			})
+
 			// License revocation
 			r.Put("/revoke/{licenseID}", a.Revoke) // PUT /revoke/123
+			r.Post("/revoke", a.BulkRevoke)        // POST /revoke
+			r.Post("/cancel", a.BulkCancel)        // POST /cancel
+
+			// Audit of administrative operations
+			r.With(paginate).Get("/audit", a.ListAudits) // GET /audit
+
+			// Data retention
+			r.Post("/retention", a.ApplyRetention) // POST /retention
+
+			// Data of a user, export and erasure
+			r.Route("/users/{userID}", func(r chi.Router) {
+				r.Get("/data", a.ExportUserData)  // GET /users/123/data
+				r.Post("/erase", a.EraseUserData) // POST /users/123/erase
+			})
+
+			// Dashboard metrics, for operators
+			r.Get("/dashboard", a.GetDashboardData) // GET /dashboard
+
+			// Compatibility with the REST api of readium-lcp-server v1 (optional)
+			if cfg.V1Compat.Enabled {
+				r.Route(cfg.V1Compat.Prefix, v1Routes(a))
+			}
 		})
 
 		// Dashboard data
-		r.Post("/dashdata/login", Login(s.Config)) // POST /dashdata/login
+		r.Post("/dashdata/login", Login(a.Live)) // POST /dashdata/login
 		// Require JWT Authentication
 		r.Group(func(r chi.Router) {
-			r.Use(AuthMiddleware(s.Config))
+			r.Use(AuthMiddleware(a.Live))
 			r.Use(render.SetContentType(render.ContentTypeJSON))
 			r.Route("/dashdata", func(r chi.Router) {
 				r.Get("/data", a.GetDashboardData)            // GET /dashdata/data
 				r.Get("/overshared", a.GetOversharedLicenses) // GET /dashdata/overshared
 				r.Put("/revoke/{licenseID}", a.Revoke)        // PUT /dashdata/revoke/license123
 				// these dashboard routes allow alt authentication before accessing crud functions
-				r.With(paginate).Get("/publications", a.ListPublications) 		// GET /dashdata/publications
-				r.Delete("/publications/{publicationID}", a.DeletePublication) // DELETE /dashdata/publication/publication123
-				r.With(paginate).Get("/user-licenses/{userID}", a.ListUserLicenses) 			// GET /dashdata/user-licenses/user123
-				r.Get("/license-events/{licenseID}", a.ListLicenseEvents) 			// GET /dashdata/license-events/license123
-				r.Get("/report-licenses", a.ReportGeneratedLicenses) 			// GET /dashdata/report-licenses
+				r.With(paginate).Get("/publications", a.ListPublications)           // GET /dashdata/publications
+				r.Delete("/publications/{publicationID}", a.DeletePublication)      // DELETE /dashdata/publication/publication123
+				r.With(paginate).Get("/user-licenses/{userID}", a.ListUserLicenses) // GET /dashdata/user-licenses/user123
+				r.Get("/license-events/{licenseID}", a.ListLicenseEvents)           // GET /dashdata/license-events/license123
+				r.Get("/report-licenses", a.ReportGeneratedLicenses)                // GET /dashdata/report-licenses
 			})
 		})
-
 	})
-
-	return r
 }
 
 // paginate middleware
@@ -182,9 +290,38 @@ func paginate(next http.Handler) http.Handler {
 	})
 }
 
+// paginateIfRequested paginates the response only if a pagination parameter is set,
+// for routes which returned every item before being paginated.
+func paginateIfRequested(next http.Handler) http.Handler {
+	paginated := paginate(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Has("page") || q.Has("per_page") {
+			paginated.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxBodyBytes limits the size of request bodies
+func maxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if n > 0 && r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // notFoundProblemDetail formats not found errors as problem details, for the sake of consistency.
 func notFoundProblemDetail(w http.ResponseWriter, r *http.Request) {
 	response := map[string]string{"type": "about:blank", "title": "Endpoint not found."}
+	if instance := api.ProblemInstance(r); instance != "" {
+		response["instance"] = instance
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusNotFound)
@@ -0,0 +1,43 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+)
+
+func TestPaginate(t *testing.T) {
+
+	// the handler writes the pagination values found in the context, 0 if not set
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := r.Context().Value(api.PageKey).(int)
+		perPage, _ := r.Context().Value(api.PerPageKey).(int)
+		fmt.Fprintf(w, "%d/%d", page, perPage)
+	})
+	for _, c := range []struct {
+		middleware func(http.Handler) http.Handler
+		query      string
+		expected   string
+	}{
+		{paginate, "", "1/20"},
+		{paginate, "?page=3&per_page=50", "3/50"},
+		{paginate, "?page=0&per_page=x", "1/20"},
+		{paginateIfRequested, "", "0/0"},
+		{paginateIfRequested, "?type=register", "0/0"},
+		{paginateIfRequested, "?page=2", "2/20"},
+		{paginateIfRequested, "?per_page=100", "1/100"},
+	} {
+		rr := httptest.NewRecorder()
+		c.middleware(h).ServeHTTP(rr, httptest.NewRequest("GET", "/events"+c.query, nil))
+		if rr.Body.String() != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.query, c.expected, rr.Body)
+		}
+	}
+}
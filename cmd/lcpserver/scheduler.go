@@ -0,0 +1,33 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"time"
+)
+
+// schedulerInterval is the interval between two runs of the periodic tasks
+const schedulerInterval = time.Minute
+
+// runScheduler runs the periodic tasks in the background, e.g. the scheduled takedowns of publications
+// and the data retention rules. If several instances share the database, each of them runs the tasks;
+// the tasks are idempotent.
+func (s *Server) runScheduler() {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		var lastRetention time.Time
+		for now := range ticker.C {
+			s.API.ExecuteScheduledTakedowns(now)
+
+			// the retention rules may be enabled or changed by a reload of the configuration
+			dr := s.API.Config().DataRetention
+			if dr.Enabled && now.Sub(lastRetention) >= time.Duration(dr.Interval)*time.Hour {
+				s.API.ExecuteRetention(now)
+				lastRetention = now
+			}
+		}
+	}()
+}
@@ -8,10 +8,12 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	stdlog "log"
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -19,78 +21,157 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/edrlab/lcp-server/pkg/api"
 	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/edrlab/lcp-server/pkg/storage"
 )
 
 // Server context
 type Server struct {
-	*conf.Config
+	*conf.Live
+	ConfigFile string
 	stor.Store
-	Cert   *tls.Certificate
-	Router *chi.Mux
+	Cert          *tls.Certificate
+	Storage       storage.Storage // encrypted publications served via signed links, nil if not configured
+	API           *api.APICtrl
+	Router        *chi.Mux    // public routes, and private routes if a single listener is configured
+	PrivateRouter *chi.Mux    // private and dashboard routes, if a private listener is configured
+	draining      atomic.Bool // set when a shutdown is requested, see readyz
 }
 
 func main() {
 
 	s := Server{}
 
+	// Default logger settings, used until the configuration is known
+	logging.Init("", "")
+
+	// Sub-commands
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Initialize the configuration from a config file or/and environment variables
-	c, err := conf.Init(os.Getenv("LCPSERVER_CONFIG"))
+	s.ConfigFile = os.Getenv("LCPSERVER_CONFIG")
+	c, err := conf.Init(s.ConfigFile)
 	if err != nil {
 		log.Println("Configuration failed: " + err.Error())
 		os.Exit(1)
 	}
-	s.Config = c
+	s.Live = conf.NewLive(c)
+
+	// Validate the configuration
+	problems := c.Validate()
+	logProblems(problems)
+	if problems.HasErrors() {
+		log.Println("Invalid configuration, use 'lcpserver config check' for details")
+		os.Exit(1)
+	}
+
+	// Set the log level and format, before the database logger is created
+	if err := logging.Init(c.LogLevel, c.LogFormat); err != nil {
+		log.Println("Logger setup failed: " + err.Error())
+		os.Exit(1)
+	}
 
 	s.initialize()
 
-	// Set the log level and format
-	if s.Config.LogLevel != "" {
-		level, err := log.ParseLevel(s.Config.LogLevel)
+	// Create the Servers, one per listener
+	var tc *tls.Config
+	if c.TLS.Enabled() {
+		tc, err = tlsConfig(c.TLS)
 		if err != nil {
-			log.Println("Invalid log level specified, defaulting to debug")
-			level = log.DebugLevel
+			log.Println("TLS setup failed: " + err.Error())
+			os.Exit(1)
 		}
-		log.SetLevel(level)
-		log.SetFormatter(&log.TextFormatter{})
 	}
-
-	// Create the Server
-	server := &http.Server{
-		Addr:    ":" + strconv.Itoa(c.Port),
-		Handler: s.Router,
+	var servers []*http.Server
+	if c.Listeners.Split() {
+		servers = append(servers, newHTTPServer(c.Listeners.Public, s.Router, tc), newHTTPServer(c.Listeners.Private, s.PrivateRouter, tc))
+	} else {
+		servers = append(servers, newHTTPServer(c.Listeners.Single(), s.Router, tc))
 	}
 
 	// Set the system signals
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	// Launch the server
-	go func() {
-		log.Println("Server starting on port " + strconv.Itoa(c.Port))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("ListenAndServe error: %v", err)
+	// Reload the configuration on SIGHUP or when the configuration file changes
+	s.watchConfig()
+
+	// Run the periodic tasks, e.g. scheduled takedowns
+	s.runScheduler()
+
+	// Launch the servers
+	for i, server := range servers {
+		name := "public"
+		if i > 0 {
+			name = "private"
+		} else if !c.Listeners.Split() {
+			name = "all"
 		}
-	}()
+		go func() {
+			var err error
+			if server.TLSConfig != nil {
+				log.Printf("Server starting on %s (https, %s routes)", server.Addr, name)
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				log.Printf("Server starting on %s (%s routes)", server.Addr, name)
+				err = server.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				log.Fatalf("ListenAndServe error: %v", err)
+			}
+		}()
+	}
 
 	// Graceful shutdown
 	<-stop
 	log.Println("Shutdown requested, initiating graceful shutdown...")
+	// report not ready, and give the orchestrator some time to stop routing traffic to this instance
+	s.draining.Store(true)
+	if delay := s.Config().Health.Delay(); delay > 0 {
+		log.Printf("Reporting not ready for %d seconds before draining connections", delay)
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Error during shutdown: %v", err)
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Errorf("Error during shutdown of %s: %v", server.Addr, err)
+			}
+		}()
 	}
+	wg.Wait()
 	log.Println("Server halted.")
 }
 
+// newHTTPServer creates an http server for a listener
+func newHTTPServer(l conf.Listener, handler http.Handler, tc *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:         l.Addr,
+		Handler:      handler,
+		TLSConfig:    tc,
+		ReadTimeout:  time.Duration(l.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(l.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(l.IdleTimeout) * time.Second,
+		// route http server errors, e.g. tls handshake errors, to the structured logger
+		ErrorLog: stdlog.New(log.StandardLogger().WriterLevel(log.WarnLevel), "", 0),
+	}
+}
+
 // Initialize sets the database, X509 certificate and routes
 func (s *Server) initialize() {
 	var err error
 
 	// Init database
-	s.Store, err = stor.Init(s.Config.Dsn)
+	s.Store, err = stor.Init(s.Config().Dsn)
 	if err != nil {
 		log.Println("Database setup failed: " + err.Error())
 		os.Exit(1)
@@ -98,12 +179,12 @@ func (s *Server) initialize() {
 
 	// Init X509 certificate
 	var certFile, privKeyFile string
-	if certFile = s.Config.Certificate.Cert; certFile == "" {
+	if certFile = s.Config().Certificate.Cert; certFile == "" {
 		log.Println("Provider certificate missing")
 		os.Exit(1)
 
 	}
-	if privKeyFile = s.Config.Certificate.PrivateKey; privKeyFile == "" {
+	if privKeyFile = s.Config().Certificate.PrivateKey; privKeyFile == "" {
 		log.Println("Private key missing")
 		os.Exit(1)
 	}
@@ -115,6 +196,13 @@ func (s *Server) initialize() {
 	}
 	s.Cert = &cert
 
+	// Init the storage of encrypted publications (optional)
+	s.Storage, err = storage.New(s.Config().Storage)
+	if err != nil {
+		log.Println("Storage setup failed: " + err.Error())
+		os.Exit(1)
+	}
+
 	// Init routes
-	s.Router = s.setRoutes()
+	s.setRoutes()
 }
@@ -0,0 +1,112 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+
+	"github.com/edrlab/lcp-server/pkg/api"
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
+)
+
+// tlsConfig returns the tls configuration of the server.
+// Client certificates are requested but not required during the handshake,
+// as reading applications calling the public routes don't have any;
+// they are checked by the private routes.
+func tlsConfig(c conf.TLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading the https certificate: %w", err)
+	}
+	tc := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.MinVersion != "" {
+		if tc.MinVersion, err = conf.ParseTLSVersion(c.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if c.MaxVersion != "" {
+		if tc.MaxVersion, err = conf.ParseTLSVersion(c.MaxVersion); err != nil {
+			return nil, err
+		}
+	}
+	if c.ClientAuth == conf.ClientAuthOptional || c.ClientAuth == conf.ClientAuthRequire {
+		pem, err := os.ReadFile(c.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading the client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificate found in the client CA file")
+		}
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tc, nil
+}
+
+// clientIdentity returns the caller identity mapped to the subject of the verified client certificate.
+// The full distinguished name of the subject is looked up first, then its common name.
+func clientIdentity(r *http.Request, identities map[string]string) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	subject := r.TLS.VerifiedChains[0][0].Subject
+	if id, ok := identities[subject.String()]; ok {
+		return id, true
+	}
+	if id, ok := identities[subject.CommonName]; ok && subject.CommonName != "" {
+		return id, true
+	}
+	return "", false
+}
+
+// privateAuth authenticates the callers of the private api,
+// with a client certificate and/or basic authentication depending on the configuration.
+// The caller identity is added to the request logger.
+func privateAuth(c *conf.Config) func(http.Handler) http.Handler {
+	credentials := map[string]string{c.Access.Username: c.Access.Password}
+	basicAuth := middleware.BasicAuth("restricted", credentials)
+	mode := c.TLS.ClientAuth
+	identities := c.TLS.Identities
+
+	return func(next http.Handler) http.Handler {
+		withBasicAuth := basicAuth(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mode != conf.ClientAuthOptional && mode != conf.ClientAuthRequire {
+				withBasicAuth.ServeHTTP(w, r)
+				return
+			}
+			if id, ok := clientIdentity(r, identities); ok {
+				logger := logging.FromRequest(r).WithField("caller", id)
+				next.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), logger)))
+				return
+			}
+			if mode == conf.ClientAuthOptional {
+				withBasicAuth.ServeHTTP(w, r)
+				return
+			}
+			// a client certificate is required
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				render.Render(w, r, api.ErrUnauthorized(errors.New("a client certificate is required")))
+				return
+			}
+			subject := r.TLS.PeerCertificates[0].Subject.String()
+			logging.FromRequest(r).WithField("subject", subject).Warn("Client certificate not mapped to a caller identity")
+			render.Render(w, r, api.ErrForbidden(errors.New("the client certificate is not mapped to a caller identity")))
+		})
+	}
+}
@@ -7,23 +7,47 @@ package api
 
 import (
 	"crypto/tls"
+	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/jobs"
 	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/edrlab/lcp-server/pkg/storage"
 )
 
 // APICtrl contains the context required by http handlers.
+// The configuration is accessed via Config(), as it may be reloaded at runtime.
 type APICtrl struct {
-	*conf.Config
+	*conf.Live
 	stor.Store
 	Cert *tls.Certificate
+	Jobs *jobs.Tracker // background jobs
+	// storage of the encrypted publications served by the server, nil if not configured
+	Storage storage.Storage
+	// licenses embedded in the publications being downloaded
+	downloads licenseCache
+	// localizer built from the current configuration, see localizer()
+	loc atomic.Pointer[configLocalizer]
+}
+
+// store returns the store bound to the context of a request, so that sql logs carry the request identifier.
+func (a *APICtrl) store(r *http.Request) stor.Store {
+	return a.Store.WithContext(r.Context())
 }
 
 // NewAPICtrl returns a new API controller
-func NewAPICtrl(cf *conf.Config, st stor.Store, cr *tls.Certificate) *APICtrl {
-	return &APICtrl{
-		Config: cf,
-		Store:  st,
-		Cert:   cr,
+func NewAPICtrl(cf *conf.Live, st stor.Store, cr *tls.Certificate) *APICtrl {
+	a := &APICtrl{
+		Live:  cf,
+		Store: st,
+		Cert:  cr,
 	}
+	a.Jobs = jobs.NewTracker(func() time.Duration {
+		return time.Duration(a.Config().Jobs.Retention) * time.Minute
+	}, func() int {
+		return a.Config().Jobs.MaxResults
+	})
+	return a
 }
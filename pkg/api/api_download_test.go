@@ -0,0 +1,213 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// storePublication creates a publication whose encrypted file is in the storage directory.
+func storePublication(t *testing.T) (*PublicationTest, []byte) {
+
+	pub := newPublication()
+	pub.Href = "https://example.com/files/" + pub.UUID + ".epub"
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"mimetype", "META-INF/container.xml", "OEBPS/chapter1.xhtml"} {
+		f, _ := w.Create(name)
+		f.Write(bytes.Repeat([]byte(name), 100))
+	}
+	w.Close()
+	if err := os.WriteFile(filepath.Join(storageDir, pub.UUID+".epub"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(pub)
+	req, _ := http.NewRequest("POST", "/publications/", bytes.NewReader(data))
+	if !checkResponseCode(t, http.StatusCreated, executeRequest(req)) {
+		t.FailNow()
+	}
+	return pub, buf.Bytes()
+}
+
+// getDownloadLink returns the request uri of a signed download link issued by a private route.
+func getDownloadLink(t *testing.T, path string) string {
+
+	req, _ := http.NewRequest("GET", path, nil)
+	response := executeRequest(req)
+	if !checkResponseCode(t, http.StatusOK, response) {
+		t.FailNow()
+	}
+	var link DownloadLink
+	if err := json.Unmarshal(response.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link.Href)
+	if err != nil || u.Query().Get("sig") == "" {
+		t.Fatalf("Unexpected download link %s", link.Href)
+	}
+	return u.RequestURI()
+}
+
+func TestDownloadPublication(t *testing.T) {
+
+	s.Config.Storage = conf.Storage{Mode: conf.StorageFS, Directory: storageDir, Secret: "a secret used for testing purposes only", Validity: 60}
+	defer func() { s.Config.Storage = conf.Storage{} }()
+
+	pub, file := storePublication(t)
+	defer deletePublication(t, pub.UUID)
+
+	link := getDownloadLink(t, "/publications/"+pub.UUID+"/download-link")
+
+	req, _ := http.NewRequest("GET", link, nil)
+	response := executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) && !bytes.Equal(response.Body.Bytes(), file) {
+		t.Error("Unexpected content of the publication")
+	}
+
+	// range request
+	req, _ = http.NewRequest("GET", link, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusPartialContent, response) && !bytes.Equal(response.Body.Bytes(), file[10:20]) {
+		t.Error("Unexpected content of the range")
+	}
+
+	// a link to another publication is refused
+	other := newPublication()
+	u, _ := url.Parse(link)
+	req, _ = http.NewRequest("GET", "/download/publications/"+other.UUID+"?"+u.RawQuery, nil)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req))
+}
+
+func TestDownloadLicensedPublication(t *testing.T) {
+
+	s.Config.FreshLicense = conf.FreshLicense{Enabled: true, Secret: "another secret used for testing purposes only", Validity: 60}
+	s.Config.Storage = conf.Storage{Mode: conf.StorageFS, Directory: storageDir, Secret: "a secret used for testing purposes only", Validity: 60}
+	defer func() {
+		s.Config.FreshLicense = conf.FreshLicense{}
+		s.Config.Storage = conf.Storage{}
+	}()
+
+	pub, _ := storePublication(t)
+	defer deletePublication(t, pub.UUID)
+
+	// generate a license, the user info is captured
+	payload := newLicenseRequest(pub.UUID)
+	data, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/licenses", bytes.NewReader(data))
+	response := executeRequest(req)
+	if !checkResponseCode(t, http.StatusCreated, response) {
+		return
+	}
+	var inLic lic.License
+	if err := json.Unmarshal(response.Body.Bytes(), &inLic); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteLicense(t, inLic.UUID)
+
+	link := getDownloadLink(t, "/licenses/"+inLic.UUID+"/download-link")
+
+	req, _ = http.NewRequest("GET", link, nil)
+	response = executeRequest(req)
+	if !checkResponseCode(t, http.StatusOK, response) {
+		return
+	}
+	if ct := response.Header().Get("Content-Type"); ct != "application/epub+zip" {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+	file := response.Body.Bytes()
+	r, err := zip.NewReader(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.Open("META-INF/license.lcpl")
+	if err != nil {
+		t.Fatal("Missing license in the publication")
+	}
+	licData, _ := io.ReadAll(f)
+	var outLic lic.License
+	if err := json.Unmarshal(licData, &outLic); err != nil {
+		t.Fatal(err)
+	}
+	if outLic.UUID != inLic.UUID || outLic.User.ID != payload.UserID {
+		t.Error("Failed to embed a fresh license with the same user info")
+	}
+
+	// successive range requests get the same license
+	req, _ = http.NewRequest("GET", link, nil)
+	req.Header.Set("Range", "bytes=-200")
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusPartialContent, response) && !bytes.Equal(response.Body.Bytes(), file[len(file)-200:]) {
+		t.Error("Unexpected content of the range")
+	}
+
+	// a link to a publication is not valid for a license
+	u, _ := url.Parse(link)
+	req, _ = http.NewRequest("GET", "/download/publications/"+pub.UUID+"?"+u.RawQuery, nil)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req))
+
+	// a license no longer in use is not downloaded, even via a link already used
+	licInfo, err := s.Store.License().Get(inLic.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	for _, update := range []func(l *stor.LicenseInfo){
+		func(l *stor.LicenseInfo) { l.Status = stor.STATUS_REVOKED },
+		func(l *stor.LicenseInfo) { l.Status = stor.STATUS_RETURNED },
+		func(l *stor.LicenseInfo) { l.Status, l.End = stor.STATUS_ACTIVE, &past },
+	} {
+		update(licInfo)
+		if err := s.Store.License().Update(licInfo); err != nil {
+			t.Fatal(err)
+		}
+		req, _ = http.NewRequest("GET", link, nil)
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req))
+	}
+}
+
+func TestDownloadWriteTimeout(t *testing.T) {
+
+	s.Config.Storage = conf.Storage{Mode: conf.StorageFS, Directory: storageDir, Secret: "a secret used for testing purposes only", Validity: 60}
+	defer func() { s.Config.Storage = conf.Storage{} }()
+
+	pub, _ := storePublication(t)
+	defer deletePublication(t, pub.UUID)
+
+	// a file larger than the socket buffers, read by a slow client
+	file := bytes.Repeat([]byte("large audiobook "), 1<<20)
+	if err := os.WriteFile(filepath.Join(storageDir, pub.UUID+".epub"), file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := getDownloadLink(t, "/publications/"+pub.UUID+"/download-link")
+
+	server := httptest.NewUnstartedServer(s.Router)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	time.Sleep(300 * time.Millisecond)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || !bytes.Equal(data, file) {
+		t.Errorf("Download interrupted after %d bytes: %v", len(data), err)
+	}
+}
@@ -3,10 +3,15 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/edrlab/lcp-server/pkg/conf"
 	"github.com/edrlab/lcp-server/pkg/lic"
 	"github.com/google/uuid"
 	"syreclabs.com/go/faker"
@@ -87,6 +92,124 @@ func TestGenerateLicense(t *testing.T) {
 		deleteLicense(t, outLic.UUID)
 	}
 }
+func TestGenerateLicenseExtensions(t *testing.T) {
+
+	// create a publication with default rights and extensions
+	inPub, _ := createPublication(t)
+	pub, err := s.Store.Publication().Get(inPub.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tts := "https://example.com/lcp/rights#tts"
+	ext := "https://example.com/lcp#provider-data"
+	pub.LicenseRights = map[string]interface{}{tts: false}
+	pub.LicenseExtensions = map[string]interface{}{ext: map[string]interface{}{"shelf": "kids"}}
+	if err := s.Store.Publication().Update(pub); err != nil {
+		t.Fatal(err)
+	}
+
+	// a standard property can't be overridden
+	payload := newLicenseRequest(inPub.UUID)
+	payload.Rights = map[string]interface{}{"print": 10}
+	data, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/licenses", bytes.NewReader(data))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	// the rights of the request override the defaults of the publication
+	payload.Rights = map[string]interface{}{tts: true}
+	data, _ = json.Marshal(payload)
+	req, _ = http.NewRequest("POST", "/licenses", bytes.NewReader(data))
+	response := executeRequest(req)
+
+	if checkResponseCode(t, http.StatusCreated, response) {
+		var outLic lic.License
+		if err := json.Unmarshal(response.Body.Bytes(), &outLic); err != nil {
+			t.Fatal(err)
+		}
+		if outLic.Rights.Extensions[tts] != true {
+			t.Fatalf("expected the tts right to be set, got %v", outLic.Rights.Extensions[tts])
+		}
+		if _, ok := outLic.Extensions[ext]; !ok {
+			t.Fatal("expected the license extension of the publication")
+		}
+		licInfo, err := s.Store.License().Get(outLic.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if licInfo.Rights[tts] != true {
+			t.Fatal("expected custom rights to be stored with the license info")
+		}
+		deleteLicense(t, outLic.UUID)
+	}
+}
+
+func TestSignedFreshLicense(t *testing.T) {
+
+	s.Config.FreshLicense = conf.FreshLicense{Enabled: true, Secret: "a secret used for testing purposes only", Validity: 60}
+	defer func() { s.Config.FreshLicense = conf.FreshLicense{} }()
+
+	// generate a license, the user info is captured
+	inPub, _ := createPublication(t)
+	payload := newLicenseRequest(inPub.UUID)
+	data, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/licenses", bytes.NewReader(data))
+	response := executeRequest(req)
+	if !checkResponseCode(t, http.StatusCreated, response) {
+		return
+	}
+	var inLic lic.License
+	if err := json.Unmarshal(response.Body.Bytes(), &inLic); err != nil {
+		t.Fatal(err)
+	}
+	defer deleteLicense(t, inLic.UUID)
+
+	// the status document points to the fresh license route of the server
+	req, _ = http.NewRequest("GET", "/status/"+inLic.UUID, nil)
+	response = executeRequest(req)
+	if !checkResponseCode(t, http.StatusOK, response) {
+		return
+	}
+	var statusDoc lic.StatusDoc
+	if err := json.Unmarshal(response.Body.Bytes(), &statusDoc); err != nil {
+		t.Fatal(err)
+	}
+	var href string
+	for _, l := range statusDoc.Links {
+		if l.Rel == "license" {
+			href = l.Href
+		}
+	}
+	link, err := url.Parse(href)
+	if err != nil || link.Path != "/fresh/"+inLic.UUID || link.Query().Get("sig") == "" {
+		t.Fatalf("Unexpected fresh license link %s", href)
+	}
+
+	req, _ = http.NewRequest("GET", link.RequestURI(), nil)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		if ct := response.Header().Get("Content-Type"); ct != lic.ContentType_LCP_JSON {
+			t.Errorf("Unexpected content type %s", ct)
+		}
+		var outLic lic.License
+		if err := json.Unmarshal(response.Body.Bytes(), &outLic); err != nil {
+			t.Fatal(err)
+		}
+		if outLic.UUID != inLic.UUID || outLic.User.ID != payload.UserID || outLic.Encryption.UserKey.TextHint != payload.TextHint {
+			t.Error("Failed to get a fresh license with the same user info")
+		}
+	}
+
+	// a tampered or expired link is refused
+	q := link.Query()
+	q.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour*24).Unix(), 10))
+	req, _ = http.NewRequest("GET", link.Path+"?"+q.Encode(), nil)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req))
+
+	expired, _ := url.Parse(lic.SignedFreshLink(s.Config.PublicBaseUrl, s.Config.FreshLicense.Secret, inLic.UUID, time.Now().Add(-time.Minute)))
+	req, _ = http.NewRequest("GET", expired.RequestURI(), nil)
+	checkResponseCode(t, http.StatusForbidden, executeRequest(req))
+}
+
 func TestGetFreshLicense(t *testing.T) {
 
 	// create a license
@@ -125,3 +248,140 @@ func TestGetFreshLicense(t *testing.T) {
 	// delete the license
 	deleteLicense(t, inLic.UUID)
 }
+
+func TestGenerateLicenses(t *testing.T) {
+
+	// create a publication
+	inPub, _ := createPublication(t)
+	defer deletePublication(t, inPub.UUID)
+
+	// the generated licenses are deleted at the end of the test
+	var generated []string
+	defer func() {
+		for _, id := range generated {
+			req, _ := http.NewRequest("DELETE", "/licenseinfo/"+id, nil)
+			executeRequest(req)
+		}
+	}()
+
+	// decodeResults returns the results of a batch, indexed by position
+	decodeResults := func(body []byte) map[int]BatchResult {
+		results := make(map[int]BatchResult)
+		for _, line := range bytes.Split(bytes.TrimSpace(body), []byte("\n")) {
+			var res BatchResult
+			if err := json.Unmarshal(line, &res); err != nil {
+				t.Fatalf("invalid result line %s: %v", line, err)
+			}
+			if res.License != nil {
+				generated = append(generated, res.License.UUID)
+			}
+			results[res.Index] = res
+		}
+		return results
+	}
+
+	// a JSON array, with an unknown publication
+	batch := []*LicenseRequest{newLicenseRequest(inPub.UUID), newLicenseRequest(uuid.New().String()), newLicenseRequest(inPub.UUID)}
+	data, _ := json.Marshal(batch)
+	req, _ := http.NewRequest("POST", "/licenses/batch", bytes.NewReader(data))
+	response := executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		if ct := response.Header().Get("Content-Type"); ct != ContentType_NDJSON {
+			t.Errorf("unexpected content type %s", ct)
+		}
+		results := decodeResults(response.Body.Bytes())
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(results))
+		}
+		for _, i := range []int{0, 2} {
+			if results[i].Status != http.StatusCreated || results[i].License == nil || results[i].License.User.ID != batch[i].UserID {
+				t.Errorf("unexpected result %d: %+v", i, results[i])
+			}
+		}
+		if results[1].Status != http.StatusBadRequest || results[1].Problem == nil {
+			t.Errorf("expected a problem on result 1, got %+v", results[1])
+		}
+	}
+
+	// a NDJSON stream, ending with a malformed line
+	var ndjson bytes.Buffer
+	enc := json.NewEncoder(&ndjson)
+	enc.Encode(newLicenseRequest(inPub.UUID))
+	enc.Encode(newLicenseRequest(inPub.UUID))
+	ndjson.WriteString("{\"user_id\": \n")
+	req, _ = http.NewRequest("POST", "/licenses/batch", &ndjson)
+	req.Header.Set("Content-Type", ContentType_NDJSON)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		results := decodeResults(response.Body.Bytes())
+		if len(results) != 3 || results[0].Status != http.StatusCreated || results[1].Status != http.StatusCreated || results[2].Status != http.StatusBadRequest {
+			t.Errorf("unexpected results %+v", results)
+		}
+	}
+
+	// an asynchronous batch
+	data, _ = json.Marshal([]*LicenseRequest{newLicenseRequest(inPub.UUID), newLicenseRequest(inPub.UUID)})
+	req, _ = http.NewRequest("POST", "/licenses/batch?async=true", bytes.NewReader(data))
+	response = executeRequest(req)
+	if !checkResponseCode(t, http.StatusAccepted, response) {
+		return
+	}
+	location := response.Header().Get("Location")
+	var job JobResponse
+	for i := 0; i < 100; i++ {
+		req, _ = http.NewRequest("GET", location, nil)
+		response = executeRequest(req)
+		if !checkResponseCode(t, http.StatusOK, response) {
+			return
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == "done" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Status != "done" || job.Total != 2 || job.Succeeded != 2 {
+		t.Fatalf("unexpected job state %+v", job.Info)
+	}
+	req, _ = http.NewRequest("GET", location+"/results", nil)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		if results := decodeResults(response.Body.Bytes()); len(results) != 2 {
+			t.Errorf("expected 2 results, got %d", len(results))
+		}
+	}
+
+	// an empty batch is invalid in async mode
+	req, _ = http.NewRequest("POST", "/licenses/batch?async=true", bytes.NewReader([]byte("[]")))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	// a stream slower than the timeouts of the listener
+	server := httptest.NewUnstartedServer(s.Router)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+	pr, pw := io.Pipe()
+	go func() {
+		enc := json.NewEncoder(pw)
+		enc.Encode(newLicenseRequest(inPub.UUID))
+		time.Sleep(300 * time.Millisecond)
+		enc.Encode(newLicenseRequest(inPub.UUID))
+		pw.Close()
+	}()
+	resp, err := http.Post(server.URL+"/licenses/batch", ContentType_NDJSON, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("batch interrupted: %v", err)
+	}
+	results := decodeResults(body)
+	if len(results) != 2 || results[0].Status != http.StatusCreated || results[1].Status != http.StatusCreated {
+		t.Errorf("unexpected results %+v", results)
+	}
+}
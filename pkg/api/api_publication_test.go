@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/google/uuid"
 )
 
@@ -135,6 +140,84 @@ func TestUpdatePublication(t *testing.T) {
 	deletePublication(t, inPub.UUID)
 }
 
+func TestUpdatePublicationContent(t *testing.T) {
+
+	// create a license, and the related publication
+	inLic, _ := createLicense(t)
+	if inLic.Updated != nil {
+		t.Fatal("A new license should not be updated")
+	}
+
+	// get the publication
+	req, _ := http.NewRequest("GET", "/publications/"+inLic.PublicationID, nil)
+	response := executeRequest(req)
+	var pub PublicationTest
+	if err := json.Unmarshal(response.Body.Bytes(), &pub); err != nil {
+		t.Fatal(err)
+	}
+	if pub.Version != 1 {
+		t.Errorf("Expected version 1, got %d", pub.Version)
+	}
+
+	// change the content of the publication
+	pub.Href = "https://edrlab.org/f/updated.epub"
+	data, _ := json.Marshal(pub)
+	req, _ = http.NewRequest("PUT", "/publications/"+pub.UUID, bytes.NewReader(data))
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var outPub PublicationTest
+		if err := json.Unmarshal(response.Body.Bytes(), &outPub); err != nil {
+			t.Fatal(err)
+		}
+		if outPub.Version != 2 {
+			t.Errorf("Expected version 2, got %d", outPub.Version)
+		}
+	}
+
+	// the license is updated
+	req, _ = http.NewRequest("GET", "/licenseinfo/"+inLic.UUID, nil)
+	response = executeRequest(req)
+	var outLic LicenseTest
+	if err := json.Unmarshal(response.Body.Bytes(), &outLic); err != nil {
+		t.Fatal(err)
+	}
+	if outLic.Updated == nil {
+		t.Error("Expected an update date on the license")
+	}
+
+	// refresh the publication and an unknown one
+	data, _ = json.Marshal(map[string][]string{"publications": {pub.UUID, uuid.New().String()}})
+	req, _ = http.NewRequest("POST", "/publications/refresh", bytes.NewReader(data))
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var results []struct {
+			UUID     string `json:"uuid"`
+			Version  int    `json:"version"`
+			Licenses int64  `json:"licenses_updated"`
+			Error    string `json:"error"`
+		}
+		if err := json.Unmarshal(response.Body.Bytes(), &results); err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(results))
+		}
+		if results[0].Version != 3 || results[0].Licenses != 1 || results[0].Error != "" {
+			t.Errorf("Unexpected result %+v", results[0])
+		}
+		if results[1].Error == "" {
+			t.Error("Expected an error on an unknown publication")
+		}
+	}
+
+	// an empty refresh request is invalid
+	req, _ = http.NewRequest("POST", "/publications/refresh", strings.NewReader(`{"publications":[]}`))
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, response)
+
+	deleteLicense(t, inLic.UUID)
+}
+
 func TestDeletePublication(t *testing.T) {
 
 	// create a publication
@@ -148,6 +231,111 @@ func TestDeletePublication(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, response)
 }
 
+func TestTakedownPublication(t *testing.T) {
+
+	// create a license, and the related publication
+	inLic, _ := createLicense(t)
+	defer deleteLicense(t, inLic.UUID)
+	pubPath := "/publications/" + inLic.PublicationID
+
+	takedown := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", pubPath+"/takedown", strings.NewReader(payload))
+		return executeRequest(req)
+	}
+	freshLicense := func() *httptest.ResponseRecorder {
+		data, _ := json.Marshal(newLicenseRequest(inLic.PublicationID))
+		req, _ := http.NewRequest("POST", "/licenses/"+inLic.UUID, bytes.NewReader(data))
+		return executeRequest(req)
+	}
+	getPublication := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", pubPath, nil)
+		return executeRequest(req)
+	}
+
+	// a valid policy and a reason are mandatory
+	checkResponseCode(t, http.StatusBadRequest, takedown(`{"policy":"drop","reason":"dmca"}`))
+	checkResponseCode(t, http.StatusBadRequest, takedown(`{"policy":"block"}`))
+
+	// the impact is reported by a dry run
+	response := takedown(`{"policy":"revoke","reason":"dmca","dry_run":true}`)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var res TakedownResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if !res.DryRun || res.ReadyLicenses != 1 || res.LicensesRevoked != 1 || !res.FreshLicensesBlocked || res.Publication != nil {
+			t.Errorf("unexpected impact %+v", res)
+		}
+	}
+	checkResponseCode(t, http.StatusOK, getPublication())
+
+	// schedule a takedown which blocks fresh licenses
+	at := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	response = takedown(`{"policy":"block","reason":"dmca","at":"` + at + `"}`)
+	checkResponseCode(t, http.StatusAccepted, response)
+	checkResponseCode(t, http.StatusOK, getPublication())
+	checkResponseCode(t, http.StatusOK, freshLicense())
+
+	// execute the scheduled takedown
+	NewAPICtrl(conf.NewLive(s.Config), s.Store, s.Cert).ExecuteScheduledTakedowns(time.Now().Add(2 * time.Hour))
+	checkResponseCode(t, http.StatusNotFound, getPublication())
+	checkResponseCode(t, http.StatusForbidden, freshLicense())
+
+	// the publication is in the trash
+	req, _ := http.NewRequest("GET", "/publications/trash", nil)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var list []PublicationTest
+		if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, pub := range list {
+			found = found || pub.UUID == inLic.PublicationID
+		}
+		if !found || response.Header().Get("X-Total-Count") == "" {
+			t.Error("Expected the publication in the trash")
+		}
+	}
+
+	// restore the publication
+	req, _ = http.NewRequest("POST", pubPath+"/restore", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	checkResponseCode(t, http.StatusOK, getPublication())
+	checkResponseCode(t, http.StatusOK, freshLicense())
+	req, _ = http.NewRequest("POST", pubPath+"/restore", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	// take the publication down now, cancelling its license
+	response = takedown(`{"policy":"revoke","reason":"dmca"}`)
+	if !checkResponseCode(t, http.StatusAccepted, response) {
+		return
+	}
+	var job JobResponse
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", response.Header().Get("Location"), nil)
+		if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == "done" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Succeeded != 1 {
+		t.Fatalf("unexpected job state %+v", job.Info)
+	}
+	req, _ = http.NewRequest("GET", "/status/"+inLic.UUID, nil)
+	var statusDoc lic.StatusDoc
+	if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &statusDoc); err != nil {
+		t.Fatal(err)
+	}
+	if statusDoc.Status != stor.STATUS_CANCELLED {
+		t.Errorf("expected a cancelled license, got %s", statusDoc.Status)
+	}
+	checkResponseCode(t, http.StatusForbidden, freshLicense())
+}
+
 func TestListPublications(t *testing.T) {
 
 	var inPubs []*PublicationTest
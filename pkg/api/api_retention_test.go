@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/google/uuid"
+)
+
+func TestRetention(t *testing.T) {
+
+	// a license used by a device, then revoked long ago
+	inLic, _ := createLicense(t)
+	defer deleteLicense(t, inLic.UUID)
+	req, _ := http.NewRequest("POST", "/register/"+inLic.UUID+"?id=1&name=device1", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	revoked := time.Now().AddDate(0, 0, -100)
+	inLic.Status = stor.STATUS_REVOKED
+	inLic.StatusUpdated = &revoked
+	data, _ := json.Marshal(inLic)
+	req, _ = http.NewRequest("PUT", "/licenseinfo/"+inLic.UUID, bytes.NewReader(data))
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+
+	retention := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/retention", strings.NewReader(payload))
+		req.SetBasicAuth("admin", "secret")
+		return executeRequest(req)
+	}
+
+	// no rule configured
+	checkResponseCode(t, http.StatusBadRequest, retention(`{"dry_run":true}`))
+
+	s.Config.DataRetention = conf.DataRetention{Secret: "retention-secret", LicenseDays: 30, EventDays: 30, Events: conf.RetentionAnonymize}
+	defer func() { s.Config.DataRetention = conf.DataRetention{} }()
+
+	// dry run
+	response := retention(`{"dry_run":true}`)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var report RetentionReport
+		if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if !report.DryRun || report.Users != 1 || report.Licenses != 1 || report.Events != 1 {
+			t.Errorf("unexpected dry run report %+v", report)
+		}
+	}
+
+	// apply the rules
+	response = retention(`{"reason":"privacy policy"}`)
+	if !checkResponseCode(t, http.StatusAccepted, response) {
+		return
+	}
+	var job JobResponse
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", response.Header().Get("Location"), nil)
+		if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == "done" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Kind != JobRetention || job.Succeeded != 1 {
+		t.Fatalf("unexpected job state %+v", job.Info)
+	}
+
+	// the user is pseudonymized, the device is anonymized
+	req, _ = http.NewRequest("GET", "/licenseinfo/"+inLic.UUID, nil)
+	var outLic LicenseTest
+	if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &outLic); err != nil {
+		t.Fatal(err)
+	}
+	if outLic.UserID != pseudonym("retention-secret", inLic.UserID) {
+		t.Errorf("expected a pseudonymized user, got %s", outLic.UserID)
+	}
+	req, _ = http.NewRequest("GET", "/license-events/"+inLic.UUID, nil)
+	var events []stor.Event
+	if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !strings.HasPrefix(events[0].DeviceID, stor.PseudonymPrefix) || events[0].DeviceName != "" {
+		t.Errorf("expected an anonymized event, got %+v", events)
+	}
+
+	// the rules are not applied twice
+	response = retention(`{"dry_run":true}`)
+	var report RetentionReport
+	json.Unmarshal(response.Body.Bytes(), &report)
+	if report.Users != 0 || report.Events != 0 {
+		t.Errorf("unexpected report after retention %+v", report)
+	}
+}
+
+func TestRetentionUserCount(t *testing.T) {
+
+	// a user with a license revoked long ago, and a license in use
+	user := "reader " + uuid.New().String() + "@example.com"
+	revoked := time.Now().AddDate(0, 0, -100)
+	var licenses []*LicenseTest
+	for i := 0; i < 2; i++ {
+		lic, _ := createLicense(t)
+		licenses = append(licenses, lic)
+		defer deleteLicense(t, lic.UUID)
+		lic.UserID = user
+		if i == 0 {
+			lic.Status = stor.STATUS_REVOKED
+			lic.StatusUpdated = &revoked
+		}
+		data, _ := json.Marshal(lic)
+		req, _ := http.NewRequest("PUT", "/licenseinfo/"+lic.UUID, bytes.NewReader(data))
+		checkResponseCode(t, http.StatusOK, executeRequest(req))
+	}
+
+	totalUsers := func() int {
+		data, err := s.Store.Dashboard().GetDashboard(3, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data.TotalUsers
+	}
+	before := totalUsers()
+
+	a := NewAPICtrl(conf.NewLive(s.Config), s.Store, s.Cert)
+	rules := conf.DataRetention{Secret: "retention-secret", LicenseDays: 30}
+	if _, err := a.retention(rules, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	// the user keeps their identifier in every license, and is counted once
+	if after := totalUsers(); after != before {
+		t.Errorf("the number of users changed from %d to %d", before, after)
+	}
+	for _, l := range licenses {
+		if info, err := s.Store.License().Get(l.UUID); err != nil || info.UserID != user {
+			t.Errorf("license %s: unexpected user %s, %v", l.UUID, info.UserID, err)
+		}
+	}
+}
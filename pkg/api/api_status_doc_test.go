@@ -1,15 +1,23 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/edrlab/lcp-server/pkg/conf"
 	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/google/uuid"
 )
 
 // ---
@@ -186,3 +194,321 @@ func TestRevoke(t *testing.T) {
 	// delete the license
 	deleteLicense(t, inLic.UUID)
 }
+
+func TestConditionalStatusDoc(t *testing.T) {
+
+	// create a license
+	inLic, _ := createLicense(t)
+	path := "/status/" + inLic.UUID
+
+	req, _ := http.NewRequest("GET", path, nil)
+	response := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response)
+	etag := response.Header().Get("ETag")
+	lastModified := response.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatal("expected ETag and Last-Modified headers")
+	}
+
+	// an up-to-date copy
+	req, _ = http.NewRequest("GET", path, nil)
+	req.Header.Set("If-None-Match", etag)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotModified, response)
+	if response.Body.Len() != 0 {
+		t.Error("expected an empty body")
+	}
+
+	req, _ = http.NewRequest("GET", path, nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusNotModified, response)
+
+	// a registration changes the validator
+	req, _ = http.NewRequest("POST", "/register/"+inLic.UUID+"?id=1&name=device1", nil)
+	executeRequest(req)
+
+	req, _ = http.NewRequest("GET", path, nil)
+	req.Header.Set("If-None-Match", etag)
+	response = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, response)
+	if response.Header().Get("ETag") == etag {
+		t.Error("expected a new ETag after a registration")
+	}
+
+	// delete the license
+	deleteLicense(t, inLic.UUID)
+}
+
+func TestLocalizedStatusDoc(t *testing.T) {
+
+	// create a license
+	inLic, _ := createLicense(t)
+
+	req, _ := http.NewRequest("GET", "/status/"+inLic.UUID, nil)
+	req.Header.Set("Accept-Language", "fr-FR, en;q=0.8")
+	response := executeRequest(req)
+
+	if checkResponseCode(t, http.StatusOK, response) {
+		if cl := response.Header().Get("Content-Language"); cl != "fr" {
+			t.Errorf("expected Content-Language fr, got %q", cl)
+		}
+		var statusDoc lic.StatusDoc
+		if err := json.Unmarshal((response.Body.Bytes()), &statusDoc); err != nil {
+			t.Fatal(err)
+		}
+		if statusDoc.Message != "La licence est prête" {
+			t.Errorf("unexpected message %q", statusDoc.Message)
+		}
+	}
+
+	// problem details titles are localized as well
+	req, _ = http.NewRequest("PUT", "/renew/"+inLic.UUID+"?id=1&name=device1&end=invalid", nil)
+	req.Header.Set("Accept-Language", "de")
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusBadRequest, response) {
+		var problem ErrResponse
+		if err := json.Unmarshal((response.Body.Bytes()), &problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem.Title != "Ungültige Anfrage" {
+			t.Errorf("unexpected title %q", problem.Title)
+		}
+	}
+
+	// delete the license
+	deleteLicense(t, inLic.UUID)
+}
+
+func TestManyEvents(t *testing.T) {
+
+	// a license of a user with more events than the security limit
+	inLic, _ := createLicense(t)
+	defer deleteLicense(t, inLic.UUID)
+	user := "reader " + uuid.New().String() + "@example.com"
+	inLic.UserID = user
+	data, _ := json.Marshal(inLic)
+	req, _ := http.NewRequest("PUT", "/licenseinfo/"+inLic.UUID, bytes.NewReader(data))
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	const n = 1001
+	for i := 0; i < n; i++ {
+		e := &stor.Event{Timestamp: time.Now(), Type: stor.EVENT_RENEW, DeviceID: "device", LicenseID: inLic.UUID}
+		if err := s.Store.Event().Create(e); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Store.Event().Delete(e)
+	}
+
+	// the unpaginated list is truncated, and links to the next page
+	r := chi.NewRouter()
+	r.Get("/license-events/{licenseID}", NewAPICtrl(conf.NewLive(s.Config), s.Store, s.Cert).ListLicenseEvents)
+	req, _ = http.NewRequest("GET", "/license-events/"+inLic.UUID, nil)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, req)
+	var events []stor.Event
+	if err := json.Unmarshal(response.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1000 || response.Header().Get("X-Total-Count") != strconv.Itoa(n) {
+		t.Errorf("unexpected list of %d events, total %s", len(events), response.Header().Get("X-Total-Count"))
+	}
+	if link := response.Header().Get("Link"); !strings.Contains(link, "page=2") || !strings.Contains(link, `rel="next"`) {
+		t.Errorf("unexpected link %q", link)
+	}
+
+	// the export of the data of the user has every event
+	req, _ = http.NewRequest("GET", "/users/"+url.PathEscape(user)+"/data", nil)
+	response = executeRequest(req)
+	var export UserDataExport
+	if err := json.Unmarshal(response.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Licenses) != 1 || len(export.Licenses[0].Events) != n {
+		t.Errorf("expected %d exported events", n)
+	}
+}
+
+func TestEventHistory(t *testing.T) {
+
+	// create a license, register two devices
+	inLic, _ := createLicense(t)
+	for _, id := range []string{"1", "2"} {
+		req, _ := http.NewRequest("POST", "/register/"+inLic.UUID+"?id="+id+"&name=device"+id, nil)
+		executeRequest(req)
+	}
+
+	countEvents := func(path string) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		response := executeRequest(req)
+		if !checkResponseCode(t, http.StatusOK, response) {
+			return -1
+		}
+		var events []stor.Event
+		if err := json.Unmarshal(response.Body.Bytes(), &events); err != nil {
+			t.Fatal(err)
+		}
+		if total := response.Header().Get("X-Total-Count"); total != strconv.Itoa(len(events)) {
+			t.Errorf("%s: unexpected total count %s", path, total)
+		}
+		return len(events)
+	}
+
+	if n := countEvents("/license-events/" + inLic.UUID); n != 2 {
+		t.Errorf("expected 2 events, got %d", n)
+	}
+	if n := countEvents("/license-events/" + inLic.UUID + "?device=2"); n != 1 {
+		t.Errorf("expected 1 event for device 2, got %d", n)
+	}
+	if n := countEvents("/events?type=register&license=" + inLic.UUID); n != 2 {
+		t.Errorf("expected 2 register events, got %d", n)
+	}
+	since := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	if n := countEvents("/events?license=" + inLic.UUID + "&since=" + since); n != 0 {
+		t.Errorf("expected no future event, got %d", n)
+	}
+	if n := countEvents("/status/" + inLic.UUID + "/events"); n != 2 {
+		t.Errorf("expected 2 events in the public history, got %d", n)
+	}
+
+	// invalid filters
+	req, _ := http.NewRequest("GET", "/events?type=lost", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+	req, _ = http.NewRequest("GET", "/events?since=yesterday", nil)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	getStatusDoc := func() (*lic.StatusDoc, bool) {
+		req, _ := http.NewRequest("GET", "/status/"+inLic.UUID, nil)
+		response := executeRequest(req)
+		if !checkResponseCode(t, http.StatusOK, response) {
+			return nil, false
+		}
+		var statusDoc lic.StatusDoc
+		if err := json.Unmarshal(response.Body.Bytes(), &statusDoc); err != nil {
+			t.Fatal(err)
+		}
+		linked := false
+		for _, link := range statusDoc.Links {
+			linked = linked || link.Rel == "events"
+		}
+		return &statusDoc, linked
+	}
+
+	// the status document includes every event
+	if statusDoc, linked := getStatusDoc(); statusDoc != nil && (len(statusDoc.Events) != 2 || linked) {
+		t.Errorf("expected every event and no link to the history, got %+v", statusDoc.Events)
+	}
+
+	// the status document only includes the most recent event, and links to the history
+	s.Config.Status.MaxEvents = 1
+	if statusDoc, linked := getStatusDoc(); statusDoc != nil {
+		if len(statusDoc.Events) != 1 || statusDoc.Events[0].DeviceID != "2" {
+			t.Errorf("expected the last event only, got %+v", statusDoc.Events)
+		}
+		if !linked {
+			t.Error("expected a link to the event history")
+		}
+	}
+	s.Config.Status.MaxEvents = 0
+
+	// the status document links to the history when the limit of events is reached
+	for i := 0; i < 1000; i++ {
+		e := &stor.Event{Timestamp: time.Now(), Type: stor.EVENT_RENEW, DeviceID: "2", DeviceName: "device2", LicenseID: inLic.UUID}
+		if err := s.Store.Event().Create(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if statusDoc, linked := getStatusDoc(); statusDoc != nil && (len(statusDoc.Events) != 1000 || !linked) {
+		t.Errorf("expected 1000 events and a link to the history, got %d events", len(statusDoc.Events))
+	}
+
+	// delete the license
+	deleteLicense(t, inLic.UUID)
+}
+
+func TestBulkRevoke(t *testing.T) {
+
+	// create three licenses of a user: an active one, a ready one, and one for another user
+	user := uuid.New().String()
+	var licenses []*LicenseTest
+	for i := 0; i < 3; i++ {
+		lic, _ := createLicense(t)
+		licenses = append(licenses, lic)
+		defer deleteLicense(t, lic.UUID)
+		if i == 2 {
+			continue
+		}
+		lic.UserID = user
+		data, _ := json.Marshal(lic)
+		req, _ := http.NewRequest("PUT", "/licenseinfo/"+lic.UUID, bytes.NewReader(data))
+		checkResponseCode(t, http.StatusOK, executeRequest(req))
+	}
+	req, _ := http.NewRequest("POST", "/register/"+licenses[0].UUID+"?id=1&name=device1", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+
+	bulk := func(path string, payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(payload))
+		req.SetBasicAuth("admin", "secret")
+		return executeRequest(req)
+	}
+
+	// a reason and a selection are mandatory
+	checkResponseCode(t, http.StatusBadRequest, bulk("/revoke", `{"user_id":"`+user+`"}`))
+	checkResponseCode(t, http.StatusBadRequest, bulk("/revoke", `{"reason":"fraud"}`))
+
+	// dry runs
+	for path, expected := range map[string]int{"/revoke": 2, "/cancel": 1} {
+		response := bulk(path, `{"user_id":"`+user+`","reason":"fraud","dry_run":true}`)
+		if checkResponseCode(t, http.StatusOK, response) {
+			var res BulkDryRunResponse
+			if err := json.Unmarshal(response.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Count != expected || len(res.Licenses) != expected {
+				t.Errorf("%s: expected %d licenses, got %+v", path, expected, res)
+			}
+		}
+	}
+
+	// revoke the licenses of the user
+	response := bulk("/revoke", `{"user_id":"`+user+`","reason":"fraud"}`)
+	if !checkResponseCode(t, http.StatusAccepted, response) {
+		return
+	}
+	var job JobResponse
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", response.Header().Get("Location"), nil)
+		if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == "done" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if job.Kind != "revoke" || job.Total != 2 || job.Succeeded != 2 {
+		t.Fatalf("unexpected job state %+v", job.Info)
+	}
+
+	// the active license is revoked, the ready one is cancelled, the other one is untouched
+	for i, expected := range []string{stor.STATUS_REVOKED, stor.STATUS_CANCELLED, stor.STATUS_READY} {
+		req, _ := http.NewRequest("GET", "/status/"+licenses[i].UUID, nil)
+		var statusDoc lic.StatusDoc
+		if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &statusDoc); err != nil {
+			t.Fatal(err)
+		}
+		if statusDoc.Status != expected {
+			t.Errorf("license %d: expected status %s, got %s", i, expected, statusDoc.Status)
+		}
+	}
+
+	// the operation is audited
+	req, _ = http.NewRequest("GET", "/audit", nil)
+	response = executeRequest(req)
+	var audits []stor.Audit
+	if err := json.Unmarshal(response.Body.Bytes(), &audits); err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) == 0 || audits[0].Action != "revoke" || audits[0].Reason != "fraud" || audits[0].Actor != "admin" || audits[0].Count != 2 || audits[0].JobID != job.ID {
+		t.Errorf("unexpected audit %+v", audits)
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/edrlab/lcp-server/pkg/conf"
 	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/edrlab/lcp-server/pkg/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
@@ -32,6 +33,9 @@ type Server struct {
 // s is the server variable shared by all tests
 var s Server
 
+// directory of the encrypted publications served by the download routes
+var storageDir string
+
 // PublicationTest data model
 type PublicationTest struct {
 	UUID          string `json:"uuid"`
@@ -42,6 +46,7 @@ type PublicationTest struct {
 	ContentType   string `json:"content_type"`
 	Size          uint32 `json:"size"`
 	Checksum      string `json:"checksum"`
+	Version       int    `json:"version,omitempty"`
 }
 
 // LicenseTest data model, no gorm data, no join
@@ -84,6 +89,10 @@ func setConfig() *conf.Config {
 			Profile:  "http://readium.org/lcp/basic-profile",
 			HintLink: "https://www.edrlab.org/lcp-help/{license_id}",
 		},
+		Jobs: conf.Jobs{
+			Concurrency: 4,
+			Retention:   60,
+		},
 	}
 
 	return &c
@@ -264,7 +273,14 @@ func TestMain(m *testing.M) {
 	s.Cert = &cert
 
 	// Set a context for controllers
-	h := NewAPICtrl(s.Config, s.Store, s.Cert)
+	h := NewAPICtrl(conf.NewLive(s.Config), s.Store, s.Cert)
+
+	// Setup the storage of encrypted publications
+	storageDir, err = os.MkdirTemp("", "lcp-storage")
+	if err != nil {
+		panic(err)
+	}
+	h.Storage = storage.NewFS(storageDir)
 
 	// Define the router
 	r := chi.NewRouter()
@@ -299,11 +315,16 @@ func TestMain(m *testing.M) {
 			r.Get("/", h.ListPublications)
 			r.Get("/search", h.SearchPublications) // GET /publication/search{?format}
 			r.Post("/", h.CreatePublication)       // POST /publications
+			r.Post("/refresh", h.RefreshPublications)
+			r.Get("/trash", h.ListDeletedPublications)
 
 			r.Route("/{publicationID}", func(r chi.Router) {
-				r.Get("/", h.GetPublication)       // GET /publications/123
-				r.Put("/", h.UpdatePublication)    // PUT /publications/123
-				r.Delete("/", h.DeletePublication) // DELETE /publications/123
+				r.Get("/", h.GetPublication)                       // GET /publications/123
+				r.Put("/", h.UpdatePublication)                    // PUT /publications/123
+				r.Delete("/", h.DeletePublication)                 // DELETE /publications/123
+				r.Post("/takedown", h.TakedownPublication)         // POST /publications/123/takedown
+				r.Post("/restore", h.RestorePublication)           // POST /publications/123/restore
+				r.Get("/download-link", h.PublicationDownloadLink) // GET /publications/123/download-link
 			})
 		})
 
@@ -320,27 +341,72 @@ func TestMain(m *testing.M) {
 			})
 		})
 
+		// Audit of administrative operations
+		r.Get("/audit", h.ListAudits)
+
+		// Data retention
+		r.Post("/retention", h.ApplyRetention)
+
+		// Data of a user
+		r.Get("/users/{userID}/data", h.ExportUserData)
+		r.Post("/users/{userID}/erase", h.EraseUserData)
+
+		// Bulk revocation
+		r.Post("/revoke", h.BulkRevoke)
+		r.Post("/cancel", h.BulkCancel)
+
+		// Background jobs
+		r.Get("/jobs/{jobID}", h.GetJob)
+		r.Get("/jobs/{jobID}/results", h.GetJobResults)
+
+		// License events
+		r.Get("/license-events/{licenseID}", h.ListLicenseEvents)
+		r.Get("/events", h.ListEvents)
+
 		// License generation
 		r.Route("/licenses", func(r chi.Router) {
-			r.Post("/", h.GenerateLicense) // POST /licenses
+			r.Post("/", h.GenerateLicense)       // POST /licenses
+			r.Post("/batch", h.GenerateLicenses) // POST /licenses/batch
 
 			r.Route("/{licenseID}", func(r chi.Router) {
-				r.Post("/", h.FreshLicense) // POST /licenses/123
+				r.Post("/", h.FreshLicense)                    // POST /licenses/123
+				r.Get("/download-link", h.LicenseDownloadLink) // GET /licenses/123/download-link
 			})
 		})
 
+		// Compatibility with the v1 REST api
+		r.Route("/v1", func(r chi.Router) {
+			r.Route("/contents/{publicationID}", func(r chi.Router) {
+				r.Get("/info", h.V1ContentInfo)
+				r.Put("/", h.V1StoreContent)
+				r.Delete("/", h.DeletePublication)
+				r.Post("/license", h.V1GenerateLicense)
+				r.Post("/licenses", h.V1GenerateLicense)
+			})
+			r.Get("/licenses/{licenseID}", h.V1GetLicense)
+			r.Post("/licenses/{licenseID}", h.V1GetLicense)
+		})
+
 		// Status document management
 		r.Group(func(r chi.Router) {
+			r.Use(h.Localize)
 			r.Use(render.SetContentType(render.ContentTypeJSON))
-			r.Get("/status/{licenseID}", h.StatusDoc)   // Get /status/123
-			r.Post("/register/{licenseID}", h.Register) // POST /register/123
-			r.Put("/renew/{licenseID}", h.Renew)        // PUT /renew/123
-			r.Put("/return/{licenseID}", h.Return)      // PUT /return/123
-			r.Put("/revoke/{licenseID}", h.Revoke)      // PUT /revoke/123
+			r.Get("/status/{licenseID}", h.StatusDoc)           // Get /status/123
+			r.Get("/status/{licenseID}/events", h.StatusEvents) // GET /status/123/events
+			r.Post("/register/{licenseID}", h.Register)         // POST /register/123
+			r.Put("/renew/{licenseID}", h.Renew)                // PUT /renew/123
+			r.Put("/return/{licenseID}", h.Return)              // PUT /return/123
+			r.Put("/revoke/{licenseID}", h.Revoke)              // PUT /revoke/123
+			r.Get("/fresh/{licenseID}", h.SignedFreshLicense)   // GET /fresh/123
 		})
 
+		// Download of encrypted publications
+		r.Get("/download/publications/{publicationID}", h.DownloadPublication)
+		r.Get("/download/licenses/{licenseID}", h.DownloadLicensedPublication)
+
 	})
 
 	code := m.Run()
+	os.RemoveAll(storageDir)
 	os.Exit(code)
 }
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/google/uuid"
+)
+
+func TestUserData(t *testing.T) {
+
+	// two licenses of a user, one of them used by a device
+	user := "reader " + uuid.New().String() + "@example.com"
+	var licenses []*LicenseTest
+	for i := 0; i < 2; i++ {
+		lic, _ := createLicense(t)
+		licenses = append(licenses, lic)
+		defer deleteLicense(t, lic.UUID)
+		lic.UserID = user
+		data, _ := json.Marshal(lic)
+		req, _ := http.NewRequest("PUT", "/licenseinfo/"+lic.UUID, bytes.NewReader(data))
+		checkResponseCode(t, http.StatusOK, executeRequest(req))
+	}
+	req, _ := http.NewRequest("POST", "/register/"+licenses[0].UUID+"?id=1&name=device1", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	// user info stored for fresh licenses
+	info, err := s.Store.License().Get(licenses[0].UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.FreshData = &stor.FreshLicenseData{UserName: "Reader", UserProperties: map[string]interface{}{"library": "central"}}
+	if err := s.Store.License().Update(info); err != nil {
+		t.Fatal(err)
+	}
+	// a deleted license of the user, whose events are kept
+	deleted, _ := createLicense(t)
+	deleted.UserID = user
+	data, _ := json.Marshal(deleted)
+	req, _ = http.NewRequest("PUT", "/licenseinfo/"+deleted.UUID, bytes.NewReader(data))
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	req, _ = http.NewRequest("POST", "/register/"+deleted.UUID+"?id=2&name=device2", nil)
+	checkResponseCode(t, http.StatusOK, executeRequest(req))
+	deleteLicense(t, deleted.UUID)
+	path := "/users/" + url.PathEscape(user)
+
+	// export
+	req, _ = http.NewRequest("GET", path+"/data", nil)
+	response := executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var export UserDataExport
+		if err := json.Unmarshal(response.Body.Bytes(), &export); err != nil {
+			t.Fatal(err)
+		}
+		if export.UserID != user || len(export.Licenses) != 2 {
+			t.Fatalf("unexpected export %+v", export)
+		}
+		for _, l := range export.Licenses {
+			if l.Publication.Title == "" {
+				t.Errorf("expected the title of the publication of license %s", l.UUID)
+			}
+			if l.UUID == licenses[0].UUID && (len(l.Events) != 1 || l.Events[0].DeviceName != "device1") {
+				t.Errorf("expected the events of license %s, got %+v", l.UUID, l.Events)
+			}
+			if l.UUID == licenses[0].UUID && (l.UserName != "Reader" || l.UserProperties["library"] != "central") {
+				t.Errorf("expected the user info of license %s, got %s %v", l.UUID, l.UserName, l.UserProperties)
+			}
+		}
+	}
+
+	// erasure
+	erase := func(payload string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path+"/erase", strings.NewReader(payload))
+		req.SetBasicAuth("admin", "secret")
+		return executeRequest(req)
+	}
+	checkResponseCode(t, http.StatusBadRequest, erase(`{}`))
+	response = erase(`{"reason":"request of the reader"}`)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var res ErasureResponse
+		if err := json.Unmarshal(response.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Licenses) != 3 || res.Revoked != 2 || res.Events < 2 {
+			t.Errorf("unexpected erasure %+v", res)
+		}
+	}
+
+	// nothing is left about the user, the licenses are revoked or cancelled
+	req, _ = http.NewRequest("GET", path+"/data", nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req))
+	for i, expected := range []string{stor.STATUS_REVOKED, stor.STATUS_CANCELLED} {
+		req, _ := http.NewRequest("GET", "/licenseinfo/"+licenses[i].UUID, nil)
+		var outLic LicenseTest
+		if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &outLic); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(outLic.UserID, stor.PseudonymPrefix) || outLic.Status != expected {
+			t.Errorf("license %d: unexpected user %s or status %s", i, outLic.UserID, outLic.Status)
+		}
+	}
+	for _, id := range []string{licenses[0].UUID, deleted.UUID} {
+		events, _, err := s.Store.Event().Search(stor.EventFilter{LicenseID: id}, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*events) == 0 {
+			t.Errorf("expected the events of license %s", id)
+		}
+		for _, e := range *events {
+			if !strings.HasPrefix(e.DeviceID, stor.PseudonymPrefix) || e.DeviceName != "" {
+				t.Errorf("expected an anonymized event, got %+v", e)
+			}
+		}
+	}
+
+	// the operation is audited, without the identifier of the user
+	req, _ = http.NewRequest("GET", "/audit", nil)
+	var audits []stor.Audit
+	if err := json.Unmarshal(executeRequest(req).Body.Bytes(), &audits); err != nil {
+		t.Fatal(err)
+	}
+	if len(audits) == 0 || audits[0].Action != AuditErasure || audits[0].Count != 3 || strings.Contains(audits[0].Target, user) {
+		t.Errorf("unexpected audit %+v", audits)
+	}
+}
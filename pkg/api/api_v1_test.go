@@ -0,0 +1,111 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/google/uuid"
+)
+
+// ---
+// v1 compatibility Tests
+// ---
+
+func TestV1Compat(t *testing.T) {
+
+	contentID := uuid.New().String()
+	notification := &V1Encrypted{
+		ContentID:   contentID,
+		ContentKey:  bytes.Repeat([]byte{7}, 32),
+		Output:      "https://storage.example.com/" + contentID + ".epub",
+		FileName:    "book.epub",
+		Size:        2048,
+		Checksum:    "abcd",
+		ContentType: "application/epub+zip",
+	}
+
+	// a local path is refused, the content must be stored already
+	local := *notification
+	local.Output = "/tmp/book.epub"
+	data, _ := json.Marshal(&local)
+	req, _ := http.NewRequest("PUT", "/v1/contents/"+contentID, bytes.NewReader(data))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	// create, then update the content
+	data, _ = json.Marshal(notification)
+	req, _ = http.NewRequest("PUT", "/v1/contents/"+contentID, bytes.NewReader(data))
+	checkResponseCode(t, http.StatusCreated, executeRequest(req))
+
+	notification.Size = 4096
+	data, _ = json.Marshal(notification)
+	req, _ = http.NewRequest("PUT", "/v1/contents/"+contentID, bytes.NewReader(data))
+	response := executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var pub PublicationTest
+		json.Unmarshal(response.Body.Bytes(), &pub)
+		if pub.Size != 4096 || pub.Title != "book.epub" {
+			t.Errorf("unexpected publication %+v", pub)
+		}
+	}
+
+	// content info, as read by lcpencrypt
+	req, _ = http.NewRequest("GET", "/v1/contents/"+contentID+"/info", nil)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var content V1Content
+		json.Unmarshal(response.Body.Bytes(), &content)
+		if content.ID != contentID || !bytes.Equal(content.EncryptionKey, notification.ContentKey) || content.Length != 4096 {
+			t.Errorf("unexpected content info %+v", content)
+		}
+	}
+
+	// generate a license from a v1 partial license
+	partial := `{"provider":"http://edrlab.org","user":{"id":"user1","email":"user1@example.com","encrypted":["email"]},
+	"encryption":{"profile":"` + lic.LCP_Basic_Profile + `","user_key":{"text_hint":"the hint",
+	"hex_value":"FAEB00CA518BEA7CB11A7EF31FB6183B489B1B6EADB792BEC64A03B3F6FF80A8"}},"rights":{"print":10}}`
+	req, _ = http.NewRequest("POST", "/v1/contents/"+contentID+"/license", strings.NewReader(partial))
+	response = executeRequest(req)
+	var license lic.License
+	if !checkResponseCode(t, http.StatusCreated, response) {
+		t.FailNow()
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &license); err != nil {
+		t.Fatal(err)
+	}
+	if license.User.ID != "user1" || license.Rights.Print == nil || *license.Rights.Print != 10 {
+		t.Errorf("unexpected license %+v", license)
+	}
+	defer deleteLicense(t, license.UUID)
+
+	// a bad passphrase hash is refused
+	req, _ = http.NewRequest("POST", "/v1/contents/"+contentID+"/licenses", strings.NewReader(strings.Replace(partial, "FAEB00", "", 1)))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req))
+
+	// partial license, without payload
+	req, _ = http.NewRequest("GET", "/v1/licenses/"+license.UUID, nil)
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusPartialContent, response) {
+		var stored V1PartialLicense
+		json.Unmarshal(response.Body.Bytes(), &stored)
+		if stored.ID != license.UUID || stored.User.ID != "user1" || stored.Rights == nil || stored.Rights.Copy != nil {
+			t.Errorf("unexpected partial license %s", response.Body.String())
+		}
+	}
+
+	// fresh license
+	req, _ = http.NewRequest("POST", "/v1/licenses/"+license.UUID, strings.NewReader(partial))
+	response = executeRequest(req)
+	if checkResponseCode(t, http.StatusOK, response) {
+		var fresh lic.License
+		json.Unmarshal(response.Body.Bytes(), &fresh)
+		if fresh.UUID != license.UUID || fresh.User.Email == "" || fresh.User.Email == "user1@example.com" {
+			t.Errorf("unexpected fresh license %+v", fresh)
+		}
+	}
+	req, _ = http.NewRequest("GET", "/v1/licenses/"+uuid.New().String(), nil)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req))
+}
@@ -0,0 +1,49 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/render"
+)
+
+// ListAudits lists the records of administrative operations, most recent first.
+func (a *APICtrl) ListAudits(w http.ResponseWriter, r *http.Request) {
+
+	page, perPage := pagination(r)
+	if page == 0 || perPage == 0 {
+		page, perPage = 1, 20
+	}
+	audits, err := a.store(r).Audit().List(page, perPage)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if err := render.RenderList(w, r, NewAuditListResponse(audits)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// AuditResponse is the response payload for audits.
+type AuditResponse struct {
+	*stor.Audit
+}
+
+// NewAuditListResponse creates a rendered list of audits
+func NewAuditListResponse(audits *[]stor.Audit) []render.Renderer {
+	list := []render.Renderer{}
+	for i := 0; i < len(*audits); i++ {
+		list = append(list, &AuditResponse{Audit: &(*audits)[i]})
+	}
+	return list
+}
+
+// Render processes responses before marshalling.
+func (a *AuditResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/jobs"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContentType_NDJSON is the media type of newline delimited JSON
+const ContentType_NDJSON = "application/x-ndjson"
+
+// JobLicenses is the kind of the jobs generating batches of licenses
+const JobLicenses = "licenses"
+
+// GenerateLicenses generates a batch of licenses. The payload is a JSON array or a NDJSON stream of license requests.
+// By default, the results are streamed as NDJSON while the requests are processed;
+// with the async query parameter set to true, the batch is processed as a background job.
+func (a *APICtrl) GenerateLicenses(w http.ResponseWriter, r *http.Request) {
+
+	logger := logging.FromRequest(r)
+	cfg := a.Config()
+
+	reader, err := newBatchReader(r.Body)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if r.URL.Query().Get("async") == "true" {
+		// the whole batch is read before the response is sent
+		var items []json.RawMessage
+		for {
+			raw, err := reader.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(fmt.Errorf("item %d: %w", len(items), err)))
+				return
+			}
+			items = append(items, raw)
+		}
+		if len(items) == 0 {
+			render.Render(w, r, ErrInvalidRequest(errors.New("empty batch")))
+			return
+		}
+
+		job := a.Jobs.Start(JobLicenses, len(items), func(ctx context.Context, j *jobs.Job) error {
+			queue := make(chan batchItem)
+			go func() {
+				defer close(queue)
+				for i, raw := range items {
+					queue <- batchItem{index: i, raw: raw}
+				}
+			}()
+			generated, failed := a.processBatch(cfg, queue, func(res *BatchResult) {
+				line, _ := json.Marshal(res)
+				j.Record(res.Problem == nil, line)
+			}, logger)
+			logger.Infof("Batch job %s: %d licenses generated, %d failed", j.Info().ID, generated, failed)
+			return nil
+		})
+		logger.Infof("Batch job %s started, %d license requests", job.Info().ID, len(items))
+
+		w.Header().Set("Location", "/jobs/"+job.Info().ID)
+		render.Status(r, http.StatusAccepted)
+		if err := render.Render(w, r, NewJobResponse(job.Info())); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	}
+
+	// the request body is read while the response is written
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		logger.Debugf("Full duplex not enabled: %v", err)
+	}
+	// a large batch takes longer than the timeouts of the listener
+	if err := rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Warnf("Failed clearing the read deadline: %v", err)
+	}
+	disableWriteTimeout(w, r)
+
+	queue := make(chan batchItem)
+	var readErr error
+	var readIndex int
+	go func() {
+		defer close(queue)
+		for i := 0; ; i++ {
+			raw, err := reader.next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr, readIndex = err, i
+				return
+			}
+			select {
+			case queue <- batchItem{index: i, raw: raw}:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	w.Header().Set("Content-Type", ContentType_NDJSON)
+	w.WriteHeader(http.StatusOK)
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	emit := func(res *BatchResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(res)
+		rc.Flush()
+	}
+
+	generated, failed := a.processBatch(cfg, queue, emit, logger)
+	// the queue is closed, the reader has stopped
+	if readErr != nil {
+		emit(newBatchProblem(readIndex, ErrInvalidRequest(readErr)))
+		failed++
+	}
+	logger.Infof("Batch of licenses: %d generated, %d failed", generated, failed)
+}
+
+// batchItem is a license request of a batch, with its position in the batch.
+type batchItem struct {
+	index int
+	raw   json.RawMessage
+}
+
+// BatchResult is the result of a license request of a batch: a license or problem details.
+type BatchResult struct {
+	Index   int          `json:"index"`
+	Status  int          `json:"status"`
+	License *lic.License `json:"license,omitempty"`
+	Problem *ErrResponse `json:"problem,omitempty"`
+}
+
+func newBatchProblem(index int, errResp render.Renderer) *BatchResult {
+	problem := errResp.(*ErrResponse)
+	return &BatchResult{Index: index, Status: problem.HTTPStatusCode, Problem: problem}
+}
+
+// processBatch generates the licenses requested in a queue, with bounded concurrency,
+// and emits the result of each request as soon as it is available.
+func (a *APICtrl) processBatch(cfg *conf.Config, queue <-chan batchItem, emit func(*BatchResult), logger *log.Entry) (generated, failed int) {
+
+	workers := cfg.Jobs.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	pubs := &publicationCache{pubs: make(map[string]*stor.Publication)}
+	var ok, ko atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				res := a.batchLicense(cfg, pubs, item, logger)
+				if res.Problem == nil {
+					ok.Add(1)
+				} else {
+					ko.Add(1)
+				}
+				emit(res)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(ok.Load()), int(ko.Load())
+}
+
+// batchLicense generates the license of a batch item
+func (a *APICtrl) batchLicense(cfg *conf.Config, pubs *publicationCache, item batchItem, logger *log.Entry) *BatchResult {
+
+	licRequest := &LicenseRequest{}
+	if err := json.Unmarshal(item.raw, licRequest); err != nil {
+		return newBatchProblem(item.index, ErrInvalidRequest(err))
+	}
+	if err := licRequest.validate(); err != nil {
+		return newBatchProblem(item.index, ErrInvalidRequest(err))
+	}
+	pubInfo, errResp := pubs.get(a, licRequest)
+	if errResp != nil {
+		return newBatchProblem(item.index, errResp)
+	}
+	license, errResp := a.generateLicense(cfg, pubInfo, licRequest, logger)
+	if errResp != nil {
+		return newBatchProblem(item.index, errResp)
+	}
+	logger.WithFields(log.Fields{
+		"license_id": license.UUID,
+		"user_id":    licRequest.UserID,
+	}).Debug("New license generated in a batch")
+	return &BatchResult{Index: item.index, Status: http.StatusCreated, License: license}
+}
+
+// publicationCache avoids reading the same publication for every license request of a batch.
+type publicationCache struct {
+	mu   sync.Mutex
+	pubs map[string]*stor.Publication
+}
+
+func (c *publicationCache) get(a *APICtrl, licRequest *LicenseRequest) (*stor.Publication, render.Renderer) {
+	key := "id:" + licRequest.PublicationID
+	if licRequest.PublicationID == "" {
+		key = "alt:" + licRequest.AltID
+	}
+	c.mu.Lock()
+	pubInfo, ok := c.pubs[key]
+	c.mu.Unlock()
+	if ok {
+		licRequest.PublicationID = pubInfo.UUID
+		return pubInfo, nil
+	}
+	pubInfo, errResp := a.requestedPublication(licRequest)
+	if errResp == nil {
+		c.mu.Lock()
+		c.pubs[key] = pubInfo
+		c.mu.Unlock()
+	}
+	return pubInfo, errResp
+}
+
+// batchReader reads the items of a batch, sent as a JSON array or as a stream of JSON objects (NDJSON).
+type batchReader struct {
+	dec   *json.Decoder
+	array bool
+}
+
+func newBatchReader(body io.Reader) (*batchReader, error) {
+	br := bufio.NewReader(body)
+	// skip leading white space, then detect an array
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			br.ReadByte()
+			continue
+		}
+		if b[0] == '[' {
+			dec := json.NewDecoder(br)
+			dec.Token() // consume the opening bracket
+			return &batchReader{dec: dec, array: true}, nil
+		}
+		break
+	}
+	return &batchReader{dec: json.NewDecoder(br)}, nil
+}
+
+// next returns the next item of the batch, or io.EOF at the end of the batch.
+func (b *batchReader) next() (json.RawMessage, error) {
+	if b.array && !b.dec.More() {
+		if _, err := b.dec.Token(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	if err := b.dec.Decode(&raw); err != nil {
+		if err == io.EOF && b.array {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return raw, nil
+}
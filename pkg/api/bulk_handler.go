@@ -0,0 +1,255 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/jobs"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	log "github.com/sirupsen/logrus"
+)
+
+// Kinds of the bulk jobs, also used as audit actions
+const (
+	JobRevoke = "revoke"
+	JobCancel = "cancel"
+)
+
+// dryRunSample is the number of license identifiers returned by a dry run
+const dryRunSample = 100
+
+// BulkRevoke revokes the active licenses and cancels the ready licenses selected by a request.
+func (a *APICtrl) BulkRevoke(w http.ResponseWriter, r *http.Request) {
+	a.bulkRevoke(w, r, JobRevoke, []string{stor.STATUS_READY, stor.STATUS_ACTIVE})
+}
+
+// BulkCancel cancels the ready licenses selected by a request, i.e. licenses not yet used by a device.
+func (a *APICtrl) BulkCancel(w http.ResponseWriter, r *http.Request) {
+	a.bulkRevoke(w, r, JobCancel, []string{stor.STATUS_READY})
+}
+
+// bulkRevoke selects licenses, then reports their number (dry run) or processes them in a background job.
+// Each license is processed as a single revocation, and the operation is recorded as an audit.
+func (a *APICtrl) bulkRevoke(w http.ResponseWriter, r *http.Request, action string, statuses []string) {
+
+	// get the payload
+	data := &BulkRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	filter, err := a.bulkFilter(data, statuses)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	ids, err := a.store(r).License().FindIDs(filter)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+
+	if data.DryRun {
+		res := &BulkDryRunResponse{DryRun: true, Action: action, Count: len(ids), Licenses: ids}
+		if len(ids) > dryRunSample {
+			res.Licenses = ids[:dryRunSample]
+		}
+		if err := render.Render(w, r, res); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	}
+
+	logger := logging.FromRequest(r).WithField("action", action)
+	job := a.startRevokeJob(action, ids, statuses, logger.WithField("reason", data.Reason))
+
+	// record the operation
+	target, _ := json.Marshal(data.BulkSelection)
+	audit := &stor.Audit{
+		Timestamp: time.Now(),
+		Action:    action,
+		Actor:     callerID(r),
+		Reason:    data.Reason,
+		Target:    string(target),
+		Count:     int64(len(ids)),
+		JobID:     job.Info().ID,
+	}
+	if err := a.store(r).Audit().Create(audit); err != nil {
+		logger.Errorf("Failed to record an audit: %v", err)
+	}
+	logger.WithFields(log.Fields{
+		"job_id": audit.JobID,
+		"actor":  audit.Actor,
+		"reason": audit.Reason,
+	}).Infof("Bulk %s of %d licenses started", action, len(ids))
+
+	w.Header().Set("Location", "/jobs/"+job.Info().ID)
+	render.Status(r, http.StatusAccepted)
+	if err := render.Render(w, r, NewJobResponse(job.Info())); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// startRevokeJob revokes or cancels licenses in a background job, skipping the licenses
+// whose status is no longer one of the given statuses.
+func (a *APICtrl) startRevokeJob(action string, ids []string, statuses []string, logger *log.Entry) *jobs.Job {
+
+	lh := lic.NewLicenseCtrl(a.Live, a.Store)
+	lh.Log = logger
+
+	return a.Jobs.Start(action, len(ids), func(ctx context.Context, j *jobs.Job) error {
+		for _, id := range ids {
+			res := BulkResult{LicenseID: id}
+			// the status may have changed since the selection
+			license, err := a.Store.License().Get(id)
+			if err == nil && !slices.Contains(statuses, license.Status) {
+				res.Status = license.Status
+				res.Skipped = true
+			} else if statusDoc, err := lh.Revoke(id); err != nil {
+				res.Error = err.Error()
+			} else {
+				res.Status = statusDoc.Status
+			}
+			line, _ := json.Marshal(res)
+			j.Record(res.Error == "", line)
+		}
+		info := j.Info()
+		logger.Infof("Bulk %s job %s: %d licenses processed, %d failed", action, info.ID, info.Processed, info.Failed)
+		return nil
+	})
+}
+
+// bulkFilter converts the selection of a bulk request into a license filter,
+// restricted to the statuses the operation applies to.
+func (a *APICtrl) bulkFilter(data *BulkRequest, statuses []string) (stor.LicenseFilter, error) {
+
+	filter := stor.LicenseFilter{
+		UserID:        data.UserID,
+		PublicationID: data.PublicationID,
+		Statuses:      statuses,
+	}
+	if data.AltID != "" {
+		pub, err := a.Store.Publication().GetByAltID(data.AltID)
+		if err != nil {
+			return filter, fmt.Errorf("unknown publication alt id: %s", data.AltID)
+		}
+		if filter.PublicationID != "" && filter.PublicationID != pub.UUID {
+			return filter, errors.New("publication_id and alt_id identify different publications")
+		}
+		filter.PublicationID = pub.UUID
+	}
+
+	f := data.Filter
+	if f == nil {
+		return filter, nil
+	}
+	if f.Status != "" {
+		if !slices.Contains(statuses, f.Status) {
+			return filter, fmt.Errorf("the operation does not apply to %s licenses", f.Status)
+		}
+		filter.Statuses = []string{f.Status}
+	}
+	if f.Count != "" {
+		// count is a "min:max" tuple
+		parts := strings.Split(f.Count, ":")
+		if len(parts) != 2 {
+			return filter, fmt.Errorf("invalid count filter: %s", f.Count)
+		}
+		min, err1 := strconv.Atoi(parts[0])
+		max, err2 := strconv.Atoi(parts[1])
+		if err1 != nil || err2 != nil {
+			return filter, fmt.Errorf("invalid count filter: %s", f.Count)
+		}
+		filter.MinDevices, filter.MaxDevices = &min, &max
+	}
+	filter.IssuedSince = f.Since
+	filter.IssuedUntil = f.Until
+	return filter, nil
+}
+
+// callerID returns the identity of the caller of the private api:
+// the identity mapped to its client certificate, or its basic auth username.
+func callerID(r *http.Request) string {
+	if id, ok := logging.FromRequest(r).Data["caller"].(string); ok {
+		return id
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return user
+	}
+	return ""
+}
+
+// --
+// Request and Response payloads for the REST api.
+// --
+
+// BulkSelection selects the licenses of a bulk operation; criteria are combined.
+type BulkSelection struct {
+	UserID        string      `json:"user_id,omitempty"`
+	PublicationID string      `json:"publication_id,omitempty" validate:"omitempty,uuid"`
+	AltID         string      `json:"alt_id,omitempty"`
+	Filter        *BulkFilter `json:"filter,omitempty"`
+}
+
+// BulkFilter is a license search filter.
+type BulkFilter struct {
+	Status string     `json:"status,omitempty"`
+	Count  string     `json:"count,omitempty"` // min:max number of devices
+	Since  *time.Time `json:"since,omitempty"` // issued since
+	Until  *time.Time `json:"until,omitempty"` // issued before
+}
+
+// BulkRequest is the request payload of bulk revocations and cancellations.
+type BulkRequest struct {
+	BulkSelection
+	Reason string `json:"reason"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (b *BulkRequest) Bind(r *http.Request) error {
+	if strings.TrimSpace(b.Reason) == "" {
+		return errors.New("missing required reason")
+	}
+	if b.UserID == "" && b.PublicationID == "" && b.AltID == "" && (b.Filter == nil || *b.Filter == BulkFilter{}) {
+		return errors.New("missing license selection")
+	}
+	validate := validator.New()
+	return validate.Struct(b)
+}
+
+// BulkDryRunResponse reports the licenses selected by a bulk request; a sample of their identifiers is returned.
+type BulkDryRunResponse struct {
+	DryRun   bool     `json:"dry_run"`
+	Action   string   `json:"action"`
+	Count    int      `json:"count"`
+	Licenses []string `json:"licenses"`
+}
+
+// Render processes responses before marshalling.
+func (b *BulkDryRunResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// BulkResult is the result of the revocation or cancellation of a license in a bulk job.
+type BulkResult struct {
+	LicenseID string `json:"license_id"`
+	Status    string `json:"status,omitempty"`
+	Skipped   bool   `json:"skipped,omitempty"` // the status of the license changed since the selection
+	Error     string `json:"error,omitempty"`
+}
@@ -7,22 +7,23 @@ package api
 import (
 	"net/http"
 
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/render"
-	log "github.com/sirupsen/logrus"
 )
 
 // GetDashboardData provides a summary of key metrics and statistics about the system.
 func (a *APICtrl) GetDashboardData(w http.ResponseWriter, r *http.Request) {
 
 	var data *stor.DashboardData
+	cfg := a.Config()
 
-	data, err := a.Store.Dashboard().GetDashboard(
-		a.Config.Dashboard.ExcessiveSharingThreshold,
-		a.Config.Dashboard.LimitToLast12Months,
+	data, err := a.store(r).Dashboard().GetDashboard(
+		cfg.Dashboard.ExcessiveSharingThreshold,
+		cfg.Dashboard.LimitToLast12Months,
 	)
 	if err != nil {
-		log.Errorf("Get Dashboard Data: failed to get data: %v", err)
+		logging.FromRequest(r).Errorf("Get Dashboard Data: failed to get data: %v", err)
 		render.Render(w, r, ErrServer(err))
 		return
 	}
@@ -36,13 +37,14 @@ func (a *APICtrl) GetDashboardData(w http.ResponseWriter, r *http.Request) {
 func (a *APICtrl) GetOversharedLicenses(w http.ResponseWriter, r *http.Request) {
 
 	var data []stor.OversharedLicenseData
+	cfg := a.Config()
 
-	data, err := a.Store.Dashboard().GetOversharedLicenses(
-		a.Config.Dashboard.ExcessiveSharingThreshold,
-		a.Config.Dashboard.LimitToLast12Months,
+	data, err := a.store(r).Dashboard().GetOversharedLicenses(
+		cfg.Dashboard.ExcessiveSharingThreshold,
+		cfg.Dashboard.LimitToLast12Months,
 	)
 	if err != nil {
-		log.Errorf("Get Overshared Licenses: failed to get data: %v", err)
+		logging.FromRequest(r).Errorf("Get Overshared Licenses: failed to get data: %v", err)
 		render.Render(w, r, ErrServer(err))
 		return
 	}
@@ -0,0 +1,344 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/pack"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/edrlab/lcp-server/pkg/storage"
+)
+
+// maxCachedLicenses limits the number of licenses kept for downloads in progress.
+const maxCachedLicenses = 1000
+
+// DownloadLink is the response payload of the routes issuing signed download links.
+type DownloadLink struct {
+	Href    string    `json:"href"`
+	Expires time.Time `json:"expires"`
+}
+
+// Render processes responses before marshalling.
+func (d *DownloadLink) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// newDownloadLink returns a signed link to a download route of the server.
+func newDownloadLink(cfg *conf.Config, kind, id string) (*DownloadLink, error) {
+
+	href, err := url.JoinPath(cfg.PublicBaseUrl, "download", kind, id)
+	if err != nil {
+		return nil, err
+	}
+	expires := lic.LinkExpiry(time.Duration(cfg.Storage.Validity)*time.Minute, time.Now())
+	return &DownloadLink{
+		Href:    lic.SignLink(href, cfg.Storage.Secret, kind+":"+id, expires),
+		Expires: expires,
+	}, nil
+}
+
+// checkDownloadLink verifies the signature and expiration date of a download link.
+func checkDownloadLink(cfg *conf.Config, kind, id string, r *http.Request) error {
+	q := r.URL.Query()
+	return lic.CheckLink(cfg.Storage.Secret, kind+":"+id, q.Get("expires"), q.Get("sig"), time.Now())
+}
+
+// PublicationDownloadLink returns a signed link to the encrypted file of a publication.
+func (a *APICtrl) PublicationDownloadLink(w http.ResponseWriter, r *http.Request) {
+
+	if a.Storage == nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	publicationID := chi.URLParam(r, "publicationID")
+	if publicationID == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("missing publicationID parameter")))
+		return
+	}
+	if _, err := a.store(r).Publication().Get(publicationID); err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	link, err := newDownloadLink(a.Config(), "publications", publicationID)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if err := render.Render(w, r, link); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// LicenseDownloadLink returns a signed link to the encrypted file of the publication of a license,
+// in which a fresh license is embedded.
+func (a *APICtrl) LicenseDownloadLink(w http.ResponseWriter, r *http.Request) {
+
+	var licenseID string
+	if licenseID = getLicenseID(w, r); licenseID == "" {
+		return
+	}
+	if a.Storage == nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	licInfo, err := a.store(r).License().Get(licenseID)
+	if err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if licInfo.FreshData == nil {
+		render.Render(w, r, ErrInvalidRequest(errors.New("the user info of the license has not been kept, a fresh license cannot be embedded")))
+		return
+	}
+	pubInfo, err := a.store(r).Publication().Get(licInfo.PublicationID)
+	if err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if pack.LicensePath(pubInfo.ContentType) == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("a license cannot be embedded in a publication of type "+pubInfo.ContentType)))
+		return
+	}
+	link, err := newDownloadLink(a.Config(), "licenses", licenseID)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if err := render.Render(w, r, link); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// DownloadPublication serves the encrypted file of a publication, via a signed link.
+// Range requests are supported.
+func (a *APICtrl) DownloadPublication(w http.ResponseWriter, r *http.Request) {
+
+	cfg := a.Config()
+	publicationID := chi.URLParam(r, "publicationID")
+	if a.Storage == nil || publicationID == "" {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if err := checkDownloadLink(cfg, "publications", publicationID, r); err != nil {
+		logging.FromRequest(r).WithField("publication_id", publicationID).Infof("Download refused: %v", err)
+		render.Render(w, r, ErrForbidden(err))
+		return
+	}
+	pubInfo, err := a.store(r).Publication().Get(publicationID)
+	if err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	// a publication taken down may forbid fresh licenses, and downloads
+	if pubInfo.BlocksFreshLicenses() {
+		render.Render(w, r, ErrForbidden(errors.New("the publication has been taken down")))
+		return
+	}
+	obj, key, errResp := a.openPublication(r.Context(), pubInfo)
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	defer obj.Close()
+
+	disableWriteTimeout(w, r)
+	setDownloadHeaders(w, pubInfo, key, pubInfo.Checksum)
+	http.ServeContent(w, r, "", obj.ModTime(), obj)
+}
+
+// licenseUseStatus returns the status of a license which is no longer in use at a date, or an empty string.
+func licenseUseStatus(l *stor.LicenseInfo, now time.Time) string {
+	switch l.Status {
+	case stor.STATUS_REVOKED, stor.STATUS_CANCELLED, stor.STATUS_RETURNED, stor.STATUS_EXPIRED:
+		return l.Status
+	}
+	if l.End != nil && l.End.Before(now) {
+		return stor.STATUS_EXPIRED
+	}
+	return ""
+}
+
+// DownloadLicensedPublication serves the encrypted file of the publication of a license, via a signed link,
+// with a fresh license embedded. Range requests are supported.
+func (a *APICtrl) DownloadLicensedPublication(w http.ResponseWriter, r *http.Request) {
+
+	var licenseID string
+	if licenseID = getLicenseID(w, r); licenseID == "" {
+		return
+	}
+	cfg := a.Config()
+	if a.Storage == nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if err := checkDownloadLink(cfg, "licenses", licenseID, r); err != nil {
+		logging.FromRequest(r).WithField("license_id", licenseID).Infof("Download refused: %v", err)
+		render.Render(w, r, ErrForbidden(err))
+		return
+	}
+	licInfo, err := a.store(r).License().Get(licenseID)
+	if err != nil || licInfo.FreshData == nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	// a license which is no longer in use may not be embedded in a publication
+	if status := licenseUseStatus(licInfo, time.Now()); status != "" {
+		logging.FromRequest(r).WithField("license_id", licenseID).Infof("Download refused, the license is %s", status)
+		render.Render(w, r, ErrForbidden(errors.New("the license is "+status)))
+		return
+	}
+	pubInfo, err := a.store(r).Publication().Get(licInfo.PublicationID)
+	if err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	path := pack.LicensePath(pubInfo.ContentType)
+	if path == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("a license cannot be embedded in a publication of type "+pubInfo.ContentType)))
+		return
+	}
+
+	// the license is generated once per link, so that the successive range requests of a download get the same bytes
+	sig := r.URL.Query().Get("sig")
+	data, ok := a.downloads.get(sig, time.Now())
+	if !ok {
+		license, errResp := a.freshLicense(licenseID, newFreshLicenseRequest(licInfo), logging.FromRequest(r))
+		if errResp != nil {
+			render.Render(w, r, errResp)
+			return
+		}
+		if data, err = json.Marshal(license); err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+		expires, _ := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		data = a.downloads.add(sig, data, time.Unix(expires, 0), time.Now())
+	}
+
+	obj, key, errResp := a.openPublication(r.Context(), pubInfo)
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	defer obj.Close()
+
+	modTime := obj.ModTime()
+	if licInfo.Updated != nil && licInfo.Updated.After(modTime) {
+		modTime = *licInfo.Updated
+	}
+	pkg, err := pack.Embed(obj, obj.Size(), path, data, modTime)
+	if err != nil {
+		logging.FromRequest(r).WithField("license_id", licenseID).Errorf("Failed embedding a license: %v", err)
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	h := sha256.Sum256(append([]byte(pubInfo.Checksum), data...))
+	disableWriteTimeout(w, r)
+	setDownloadHeaders(w, pubInfo, key, hex.EncodeToString(h[:16]))
+	http.ServeContent(w, r, "", modTime, pkg)
+}
+
+// openPublication opens the encrypted file of a publication in the storage,
+// and returns it with its storage key, or an error response.
+func (a *APICtrl) openPublication(ctx context.Context, pubInfo *stor.Publication) (storage.Object, string, render.Renderer) {
+
+	key, err := storage.Key(pubInfo.Href)
+	if err != nil {
+		return nil, "", ErrNotFound()
+	}
+	obj, err := a.Storage.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, "", ErrNotFound()
+	}
+	if err != nil {
+		return nil, "", ErrServer(err)
+	}
+	return obj, key, nil
+}
+
+// setDownloadHeaders sets the headers of a publication download, before range processing.
+func setDownloadHeaders(w http.ResponseWriter, pubInfo *stor.Publication, filename, etag string) {
+	w.Header().Set("Content-Type", pubInfo.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("ETag", strconv.Quote(etag))
+}
+
+// disableWriteTimeout clears the write deadline of the listener for a response which may take long to send,
+// e.g. a large audiobook or a streamed batch; the read and idle timeouts still apply.
+func disableWriteTimeout(w http.ResponseWriter, r *http.Request) {
+	err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logging.FromRequest(r).Warnf("Failed clearing the write deadline: %v", err)
+	}
+}
+
+// licenseCache keeps the licenses embedded in downloads until their link expires.
+// It is local to a server instance: the requests of a download link must be routed to the same instance.
+type licenseCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLicense
+}
+
+type cachedLicense struct {
+	data    []byte
+	expires time.Time
+}
+
+// get returns the license cached for a link, if it has not expired.
+func (c *licenseCache) get(key string, now time.Time) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || now.After(e.expires) {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// add caches the license of a link and returns the cached license,
+// which is the one of a concurrent request if it was added first.
+func (c *licenseCache) add(key string, data []byte, expires, now time.Time) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedLicense)
+	}
+	if e, ok := c.entries[key]; ok && !now.After(e.expires) {
+		return e.data
+	}
+	if len(c.entries) >= maxCachedLicenses {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= maxCachedLicenses {
+		// evict any entry; the download using it gets a new license via If-Range
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = cachedLicense{data: data, expires: expires}
+	return data
+}
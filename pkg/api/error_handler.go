@@ -5,9 +5,13 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/edrlab/lcp-server/pkg/i18n"
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/go-chi/render"
+	"github.com/google/uuid"
 )
 
 /*
@@ -25,6 +29,7 @@ The server must return  a type and a title in the Problem Details JSON object.
 The "about:blank" URI [RFC6694], when used as a problem type, indicates that the problem has no additional semantics beyond that of the HTTP status code.
 
 The server should attempt to localize both title and detail based on the Accept-Language header sent by the client.
+On the status document routes, titles are localized; details, which are technical messages, are not.
 
 Samples of problem details error message:
 {
@@ -69,19 +74,51 @@ type ErrResponse struct {
 	//optional
 	Detail   string `json:"detail,omitempty"` // application-level error message
 	Instance string `json:"instance,omitempty"`
+	// message key of the title, localized if the request carries a language
+	key string
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	render.Status(r, e.HTTPStatusCode)
+	if p := i18n.FromContext(r.Context()); p != nil && e.key != "" {
+		e.Title = p.Sprintf(e.key)
+	}
+	if e.Instance == "" {
+		e.Instance = ProblemInstance(r)
+	}
 	return nil
 }
 
+// ProblemInstance returns a URI identifying the occurrence of a problem, derived from the request identifier.
+func ProblemInstance(r *http.Request) string {
+	id := logging.RequestID(r.Context())
+	if id == "" {
+		return ""
+	}
+	if _, err := uuid.Parse(id); err == nil {
+		return "urn:uuid:" + id
+	}
+	return id
+}
+
 func ErrInvalidRequest(err error) render.Renderer {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &ErrResponse{
+			Err:            err,
+			HTTPStatusCode: 413,
+			Type:           "about:blank",
+			Title:          "Request body too large",
+			key:            i18n.ErrorBodyTooLarge,
+			Detail:         err.Error(),
+		}
+	}
 	return &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: 400,
 		Type:           "about:blank",
 		Title:          "Invalid request",
+		key:            i18n.ErrorInvalidRequest,
 		Detail:         err.Error(),
 	}
 }
@@ -92,6 +129,7 @@ func ErrRender(err error) render.Renderer {
 		HTTPStatusCode: 422,
 		Type:           "about:blank",
 		Title:          "Error rendering response",
+		key:            i18n.ErrorRender,
 		Detail:         err.Error(),
 	}
 }
@@ -102,14 +140,51 @@ func ErrServer(err error) render.Renderer {
 		HTTPStatusCode: 500,
 		Type:           SERVER_ERROR,
 		Title:          "An unexpected error has occurred",
+		key:            i18n.ErrorServer,
+		Detail:         err.Error(),
+	}
+}
+
+func ErrUnauthorized(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 401,
+		Type:           "about:blank",
+		Title:          "Authentication required",
+		key:            i18n.ErrorUnauthorized,
 		Detail:         err.Error(),
 	}
 }
 
-var ErrNotFound = &ErrResponse{
-	HTTPStatusCode: 404,
-	Type:           "about:blank",
-	Title:          "Resource not found",
+func ErrForbidden(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 403,
+		Type:           "about:blank",
+		Title:          "Access forbidden",
+		key:            i18n.ErrorForbidden,
+		Detail:         err.Error(),
+	}
+}
+
+func ErrTooManyRequests(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 429,
+		Type:           "about:blank",
+		Title:          "Too many requests",
+		key:            i18n.ErrorTooManyRequests,
+		Detail:         err.Error(),
+	}
+}
+
+func ErrNotFound() render.Renderer {
+	return &ErrResponse{
+		HTTPStatusCode: 404,
+		Type:           "about:blank",
+		Title:          "Resource not found",
+		key:            i18n.ErrorNotFound,
+	}
 }
 
 func ErrRegister(err error) render.Renderer {
@@ -118,6 +193,7 @@ func ErrRegister(err error) render.Renderer {
 		HTTPStatusCode: 400,
 		Type:           REGISTER_ERROR,
 		Title:          "Error registering a device",
+		key:            i18n.ErrorRegistration,
 		Detail:         err.Error(),
 	}
 }
@@ -128,6 +204,7 @@ func ErrRenew(err error) render.Renderer {
 		HTTPStatusCode: 400,
 		Type:           RENEW_ERROR,
 		Title:          "Error extending a license",
+		key:            i18n.ErrorRenew,
 		Detail:         err.Error(),
 	}
 }
@@ -138,6 +215,7 @@ func ErrReturn(err error) render.Renderer {
 		HTTPStatusCode: 400,
 		Type:           RETURN_ERROR,
 		Title:          "Error returning a license",
+		key:            i18n.ErrorReturn,
 		Detail:         err.Error(),
 	}
 }
@@ -148,6 +226,7 @@ func ErrRevoke(err error) render.Renderer {
 		HTTPStatusCode: 400,
 		Type:           REVOKE_ERROR,
 		Title:          "Error revoking / cancelling a license",
+		key:            i18n.ErrorRevoke,
 		Detail:         err.Error(),
 	}
 }
@@ -0,0 +1,58 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/edrlab/lcp-server/pkg/jobs"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// GetJob returns the state of a background job.
+func (a *APICtrl) GetJob(w http.ResponseWriter, r *http.Request) {
+
+	job, ok := a.Jobs.Get(chi.URLParam(r, "jobID"))
+	if !ok {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if err := render.Render(w, r, NewJobResponse(job.Info())); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// GetJobResults returns the results recorded by a background job, as NDJSON.
+// It can be called while the job is running.
+func (a *APICtrl) GetJobResults(w http.ResponseWriter, r *http.Request) {
+
+	job, ok := a.Jobs.Get(chi.URLParam(r, "jobID"))
+	if !ok {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	w.Header().Set("Content-Type", ContentType_NDJSON)
+	for _, line := range job.Results() {
+		w.Write(line)
+		w.Write([]byte("\n"))
+	}
+}
+
+// JobResponse is the response payload for jobs.
+type JobResponse struct {
+	jobs.Info
+}
+
+// NewJobResponse creates a rendered job
+func NewJobResponse(info jobs.Info) *JobResponse {
+	return &JobResponse{Info: info}
+}
+
+// Render processes responses before marshalling.
+func (j *JobResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
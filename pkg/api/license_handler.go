@@ -5,11 +5,16 @@
 package api
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/edrlab/lcp-server/pkg/conf"
 	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -29,12 +34,61 @@ func (a *APICtrl) GenerateLicense(w http.ResponseWriter, r *http.Request) {
 	// get the payload
 	licRequest := &LicenseRequest{}
 	if err := render.Bind(r, licRequest); err != nil {
-		log.Errorf("error binding a Generate License request: %v", err)
+		logging.FromRequest(r).Errorf("error binding a Generate License request: %v", err)
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
 	// get the corresponding publication
+	pubInfo, errResp := a.requestedPublication(licRequest)
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+
+	// generate the license
+	cfg := a.Config()
+	license, errResp := a.generateLicense(cfg, pubInfo, licRequest, logging.FromRequest(r))
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+
+	logging.FromRequest(r).WithFields(log.Fields{
+		"license_id": license.UUID,
+		"user_id":    licRequest.UserID,
+	}).Infof("New license generated on %s", license.Issued.Format(time.RFC822))
+
+	render.Status(r, http.StatusCreated)
+
+	// return a download link as a Location header
+	if returnLink == "true" {
+		flt := lic.FreshLicenseLink(cfg, license.UUID, time.Now())
+		template, _ := uritemplates.Parse(flt)
+		values := make(map[string]interface{})
+		values["license_id"] = license.UUID
+		expanded, err := template.Expand(values)
+		if err != nil {
+			logging.FromRequest(r).Errorf("failed to expand the fresh license link: %s", flt)
+			render.Render(w, r, ErrServer(err))
+			return
+		}
+		// set http 303 See Other with Location header
+		w.Header().Set("Location", expanded)
+		w.WriteHeader(http.StatusSeeOther)
+
+		// return the license
+	} else {
+		if err := render.Render(w, r, NewLicenseResponse(license)); err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+	}
+}
+
+// requestedPublication returns the publication identified in a license request,
+// or an error response if it does not exist or has been deleted.
+func (a *APICtrl) requestedPublication(licRequest *LicenseRequest) (*stor.Publication, render.Renderer) {
 	var pubInfo *stor.Publication
 	var err error
 	if licRequest.PublicationID != "" {
@@ -44,42 +98,53 @@ func (a *APICtrl) GenerateLicense(w http.ResponseWriter, r *http.Request) {
 		// set the publication ID in the request for further processing
 		licRequest.PublicationID = pubInfo.UUID
 	} else {
-		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication identifier in payload")))
-		return
+		return nil, ErrInvalidRequest(errors.New("missing required publication identifier in payload"))
 	}
 	// error if the database request was not successful
 	if err != nil {
-		render.Render(w, r, ErrInvalidRequest(errors.New("invalid publication ID")))
-		return
+		return nil, ErrInvalidRequest(errors.New("invalid publication ID"))
 	}
 	// error if the publication has been soft-deleted
 	if pubInfo.DeletedAt.Valid {
-		render.Render(w, r, ErrInvalidRequest(errors.New("the publication has been previously deleted")))
-		return
+		return nil, ErrInvalidRequest(errors.New("the publication has been previously deleted"))
 	}
+	return pubInfo, nil
+}
+
+// generateLicense stores the info of a new license and returns the license,
+// or an error response.
+func (a *APICtrl) generateLicense(cfg *conf.Config, pubInfo *stor.Publication, licRequest *LicenseRequest, logger *log.Entry) (*lic.License, render.Renderer) {
+
+	license, err := CreateLicense(cfg, a.Store, a.Cert, pubInfo, licRequest)
+	if err != nil {
+		logger.WithField("publication_id", pubInfo.UUID).Errorf("Failed generating a license: %v", err)
+		return nil, ErrServer(err)
+	}
+	return license, nil
+}
+
+// CreateLicense stores the info of a new license and returns the license.
+// It is shared by the REST api and the admin tool working on the database.
+func CreateLicense(cfg *conf.Config, st stor.Store, cert *tls.Certificate, pubInfo *stor.Publication, licRequest *LicenseRequest) (*lic.License, error) {
 
 	// set license info
-	licInfo := newLicenseInfo(a.Config.License.Provider, a.Config.Status.RenewMaxDays, licRequest)
+	licInfo := NewLicenseInfo(cfg.License.Provider, cfg.Status.RenewMaxDays, licRequest)
+	// keep the user info if the server serves fresh licenses
+	if cfg.FreshLicense.Enabled {
+		licInfo.FreshData = licRequest.FreshLicenseData()
+	}
 
 	// store license info
-	err = a.Store.License().Create(licInfo)
-	if err != nil {
-		render.Render(w, r, ErrServer(err))
-		return
+	if err := st.License().Create(licInfo); err != nil {
+		return nil, err
 	}
 	// get back license info to retrieve gorm data
-	licInfo, err = a.Store.License().Get(licInfo.UUID)
+	licInfo, err := st.License().Get(licInfo.UUID)
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
-		return
+		return nil, err
 	}
 
-	userInfo := lic.UserInfo{
-		ID:        licRequest.UserID,
-		Name:      licRequest.UserName,
-		Email:     licRequest.UserEmail,
-		Encrypted: licRequest.UserEncrypted,
-	}
+	userInfo := licRequest.UserInfo()
 	encryption := lic.Encryption{
 		Profile: licRequest.Profile,
 		UserKey: lic.UserKey{
@@ -88,88 +153,107 @@ func (a *APICtrl) GenerateLicense(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// generate the license
-	license, err := lic.NewLicense(a.Config, a.Cert, pubInfo, licInfo, &userInfo, &encryption, licRequest.PassHash)
+	license, err := lic.NewLicense(cfg, cert, pubInfo, licInfo, &userInfo, &encryption, licRequest.PassHash)
 	if err != nil {
-		log.Errorf("Failed generating a license: %v", err)
-		render.Render(w, r, ErrServer(err))
-		return
-	}
-
-	log.Printf("New license %s generated on %s", license.UUID, license.Issued.Format(time.RFC822))
-
-	render.Status(r, http.StatusCreated)
-
-	// return a download link as a Location header
-	if returnLink == "true" {
-		flt := a.Config.Status.FreshLicenseLink
-		template, _ := uritemplates.Parse(flt)
-		values := make(map[string]interface{})
-		values["license_id"] = license.UUID
-		expanded, err := template.Expand(values)
-		if err != nil {
-			log.Printf("failed to expand the fresh license link: %s", template)
-			render.Render(w, r, ErrServer(err))
-			return
-		}
-		// set http 303 See Other with Location header
-		w.Header().Set("Location", expanded)
-		w.WriteHeader(http.StatusSeeOther)
-		
-	// return the license
-	} else {
-		if err = render.Render(w, r, NewLicenseResponse(license)); err != nil {
-			render.Render(w, r, ErrRender(err))
-			return
-		}
+		return nil, fmt.Errorf("license %s: %w", licInfo.UUID, err)
 	}
+	return license, nil
 }
 
 // FreshLicense returns a fresh license
 func (a *APICtrl) FreshLicense(w http.ResponseWriter, r *http.Request) {
-	var err error
 
 	// get the payload
 	licRequest := &LicenseRequest{}
-	if err = render.Bind(r, licRequest); err != nil {
-		log.Errorf("error binding a Fresh License request: %v", err)
+	if err := render.Bind(r, licRequest); err != nil {
+		logging.FromRequest(r).Errorf("error binding a Fresh License request: %v", err)
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
-
-	// get the license
-	var licInfo *stor.LicenseInfo
-	if licenseID := chi.URLParam(r, "licenseID"); licenseID != "" {
-		licInfo, err = a.Store.License().Get(licenseID)
-	} else {
+	licenseID := chi.URLParam(r, "licenseID")
+	if licenseID == "" {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing licenseID parameter")))
 		return
 	}
-	if err != nil {
-		render.Render(w, r, ErrNotFound)
+
+	license, errResp := a.freshLicense(licenseID, licRequest, logging.FromRequest(r))
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	if err := render.Render(w, r, NewLicenseResponse(license)); err != nil {
+		render.Render(w, r, ErrRender(err))
 		return
 	}
+}
 
-	// get the corresponding publication
-	var pubInfo *stor.Publication
+// SignedFreshLicense returns a fresh license generated from the user info captured at license generation.
+// This public route is called via the signed link of the status document.
+func (a *APICtrl) SignedFreshLicense(w http.ResponseWriter, r *http.Request) {
 
-	if licInfo.PublicationID != "" {
-		pubInfo, err = a.Store.Publication().Get(licInfo.PublicationID)
-	} else {
-		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication identifier in payload")))
+	var licenseID string
+	if licenseID = getLicenseID(w, r); licenseID == "" {
+		return
+	}
+	cfg := a.Config()
+	if !cfg.FreshLicense.Enabled {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	q := r.URL.Query()
+	if err := lic.CheckFreshLink(cfg.FreshLicense.Secret, licenseID, q.Get("expires"), q.Get("sig"), time.Now()); err != nil {
+		logging.FromRequest(r).WithField("license_id", licenseID).Infof("Fresh license refused: %v", err)
+		render.Render(w, r, ErrForbidden(err))
+		return
+	}
+
+	licInfo, err := a.store(r).License().Get(licenseID)
+	if err != nil || licInfo.FreshData == nil {
+		// licenses generated before the feature was enabled, or whose user has been erased
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	license, errResp := a.freshLicense(licenseID, newFreshLicenseRequest(licInfo), logging.FromRequest(r))
+	if errResp != nil {
+		render.Render(w, r, errResp)
 		return
 	}
+
+	data, err := json.Marshal(license)
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrRender(err))
 		return
 	}
+	w.Header().Set("Content-Type", lic.ContentType_LCP_JSON)
+	w.Header().Set("Content-Disposition", "attachment; filename=\"license.lcpl\"")
+	w.Write(data)
+}
 
-	userInfo := lic.UserInfo{
-		ID:        licRequest.UserID,
-		Name:      licRequest.UserName,
-		Email:     licRequest.UserEmail,
-		Encrypted: licRequest.UserEncrypted,
+// freshLicense generates a fresh license from stored license info, or returns an error response.
+func (a *APICtrl) freshLicense(licenseID string, licRequest *LicenseRequest, logger *log.Entry) (*lic.License, render.Renderer) {
+
+	// get the license
+	licInfo, err := a.Store.License().Get(licenseID)
+	if err != nil {
+		return nil, ErrNotFound()
+	}
+
+	// get the corresponding publication
+	if licInfo.PublicationID == "" {
+		return nil, ErrInvalidRequest(errors.New("missing required publication identifier in payload"))
+	}
+	pubInfo, err := a.Store.Publication().Get(licInfo.PublicationID)
+	if err != nil {
+		return nil, ErrNotFound()
+	}
+	// a publication taken down may forbid fresh licenses
+	if pubInfo.BlocksFreshLicenses() {
+		logger.WithField("license_id", licInfo.UUID).Info("Fresh license refused, the publication has been taken down")
+		return nil, ErrForbidden(errors.New("the publication has been taken down"))
 	}
 
+	userInfo := licRequest.UserInfo()
+
 	encryption := lic.Encryption{
 		Profile: licRequest.Profile,
 		UserKey: lic.UserKey{
@@ -178,21 +262,20 @@ func (a *APICtrl) FreshLicense(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// generate the license
-	license, err := lic.NewLicense(a.Config, a.Cert, pubInfo, licInfo, &userInfo, &encryption, licRequest.PassHash)
+	license, err := lic.NewLicense(a.Config(), a.Cert, pubInfo, licInfo, &userInfo, &encryption, licRequest.PassHash)
 	if err != nil {
-		render.Render(w, r, ErrServer(err))
-		return
-	}
-	log.Printf("Fresh license %s generated", license.UUID)
-
-	if err := render.Render(w, r, NewLicenseResponse(license)); err != nil {
-		render.Render(w, r, ErrRender(err))
-		return
+		logger.WithField("license_id", licInfo.UUID).Errorf("Failed generating a fresh license: %v", err)
+		return nil, ErrServer(err)
 	}
+	logger.WithFields(log.Fields{
+		"license_id": license.UUID,
+		"user_id":    licRequest.UserID,
+	}).Info("Fresh license generated")
+	return license, nil
 }
 
-// newLicenseInfo sets license info from request parameters
-func newLicenseInfo(provider string, renewMaxDays int, licRequest *LicenseRequest) *stor.LicenseInfo {
+// NewLicenseInfo sets license info from request parameters
+func NewLicenseInfo(provider string, renewMaxDays int, licRequest *LicenseRequest) *stor.LicenseInfo {
 
 	noLimit := int32(-1) // -1 stored for no print/copy limits
 	if licRequest.Copy == nil {
@@ -212,10 +295,12 @@ func newLicenseInfo(provider string, renewMaxDays int, licRequest *LicenseReques
 		Copy:          *licRequest.Copy,
 		Print:         *licRequest.Print,
 		Status:        stor.STATUS_READY,
+		Rights:        licRequest.Rights,
+		Extensions:    licRequest.Extensions,
 	}
 	if licInfo.End != nil {
-	maxEnd := licInfo.End.AddDate(0, 0, renewMaxDays)
-	licInfo.MaxEnd = &maxEnd
+		maxEnd := licInfo.End.AddDate(0, 0, renewMaxDays)
+		licInfo.MaxEnd = &maxEnd
 	}
 
 	return &licInfo
@@ -226,29 +311,93 @@ func newLicenseInfo(provider string, renewMaxDays int, licRequest *LicenseReques
 // --
 
 // LicenseRequest is the request payload for licenses.
-// TODO: add an extension point for custom user properties, that have to
-// be returned in the license, optionally encrypted.
+// Custom user properties are keyed by a URI; they are returned in the license,
+// and encrypted if listed in user_encrypted.
+// Custom rights and license extensions are also keyed by a URI; they are stored
+// with the license info and override the defaults of the publication.
 type LicenseRequest struct {
-	PublicationID string     `json:"publication_id" validate:"omitempty,uuid"`
-	AltID         string     `json:"alt_id,omitempty"`
-	UserID        string     `json:"user_id,omitempty" validate:"required"`
-	UserName      string     `json:"user_name,omitempty"`
-	UserEmail     string     `json:"user_email,omitempty"`
-	UserEncrypted []string   `json:"user_encrypted,omitempty"`
-	Start         *time.Time `json:"start,omitempty"`
-	End           *time.Time `json:"end,omitempty"`
-	Copy          *int32     `json:"copy,omitempty"`
-	Print         *int32     `json:"print,omitempty"`
-	Profile       string     `json:"profile,omitempty"`
-	TextHint      string     `json:"text_hint" validate:"required"`
-	PassHash      string     `json:"pass_hash" validate:"required"`
+	PublicationID  string                 `json:"publication_id" validate:"omitempty,uuid"`
+	AltID          string                 `json:"alt_id,omitempty"`
+	UserID         string                 `json:"user_id,omitempty" validate:"required"`
+	UserName       string                 `json:"user_name,omitempty"`
+	UserEmail      string                 `json:"user_email,omitempty"`
+	UserEncrypted  []string               `json:"user_encrypted,omitempty"`
+	UserProperties map[string]interface{} `json:"user_properties,omitempty"`
+	Start          *time.Time             `json:"start,omitempty"`
+	End            *time.Time             `json:"end,omitempty"`
+	Copy           *int32                 `json:"copy,omitempty"`
+	Print          *int32                 `json:"print,omitempty"`
+	Profile        string                 `json:"profile,omitempty"`
+	TextHint       string                 `json:"text_hint" validate:"required"`
+	PassHash       string                 `json:"pass_hash" validate:"required"`
+	Rights         map[string]interface{} `json:"rights,omitempty"`
+	Extensions     map[string]interface{} `json:"extensions,omitempty"`
 }
 
 // Bind post-processes requests after unmarshalling.
 func (l *LicenseRequest) Bind(r *http.Request) error {
-	// validate the request
+	return l.validate()
+}
+
+// validate checks required fields and values
+func (l *LicenseRequest) validate() error {
 	validate := validator.New()
-	return validate.Struct(l)
+	if err := validate.Struct(l); err != nil {
+		return err
+	}
+	if err := lic.ValidateUserExtensions(l.UserProperties); err != nil {
+		return err
+	}
+	if err := lic.ValidateRightsExtensions(l.Rights); err != nil {
+		return err
+	}
+	return lic.ValidateLicenseExtensions(l.Extensions)
+}
+
+// FreshLicenseData returns the user info and user key material kept for fresh licenses.
+func (l *LicenseRequest) FreshLicenseData() *stor.FreshLicenseData {
+	return &stor.FreshLicenseData{
+		UserName:       l.UserName,
+		UserEmail:      l.UserEmail,
+		UserEncrypted:  l.UserEncrypted,
+		UserProperties: l.UserProperties,
+		Profile:        l.Profile,
+		TextHint:       l.TextHint,
+		PassHash:       l.PassHash,
+	}
+}
+
+// newFreshLicenseRequest returns the request of a fresh license from the user info kept with a license.
+func newFreshLicenseRequest(licInfo *stor.LicenseInfo) *LicenseRequest {
+	fd := licInfo.FreshData
+	return &LicenseRequest{
+		UserID:         licInfo.UserID,
+		UserName:       fd.UserName,
+		UserEmail:      fd.UserEmail,
+		UserEncrypted:  fd.UserEncrypted,
+		UserProperties: fd.UserProperties,
+		Profile:        fd.Profile,
+		TextHint:       fd.TextHint,
+		PassHash:       fd.PassHash,
+	}
+}
+
+// UserInfo returns the user info to be set in a license.
+// Custom properties are copied, as encryption modifies them in place.
+func (l *LicenseRequest) UserInfo() lic.UserInfo {
+	userInfo := lic.UserInfo{
+		ID:        l.UserID,
+		Name:      l.UserName,
+		Email:     l.UserEmail,
+		Encrypted: l.UserEncrypted,
+	}
+	if len(l.UserProperties) > 0 {
+		userInfo.Extensions = make(map[string]interface{}, len(l.UserProperties))
+		for key, value := range l.UserProperties {
+			userInfo.Extensions[key] = value
+		}
+	}
+	return userInfo
 }
 
 // LicenseResponse is the response payload for licenses.
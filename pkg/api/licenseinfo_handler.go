@@ -11,16 +11,17 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
-	log "github.com/sirupsen/logrus"
 )
 
 // ListLicenses lists licenses present in the database.
 func (a *APICtrl) ListLicenses(w http.ResponseWriter, r *http.Request) {
-	log.Debug("List Licenses")
+	logging.FromRequest(r).Debug("List Licenses")
 
 	page := r.Context().Value(PageKey).(int)
 	perPage := r.Context().Value(PerPageKey).(int)
@@ -28,9 +29,9 @@ func (a *APICtrl) ListLicenses(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if page == 0 || perPage == 0 {
-		licenses, err = a.Store.License().ListAll()
+		licenses, err = a.store(r).License().ListAll()
 	} else {
-		licenses, err = a.Store.License().List(page, perPage)
+		licenses, err = a.store(r).License().List(page, perPage)
 	}
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
@@ -53,13 +54,13 @@ func (a *APICtrl) SearchLicenses(w http.ResponseWriter, r *http.Request) {
 		if decodedUserID, err := url.QueryUnescape(userID); err == nil {
 			userID = decodedUserID
 		}
-		licenses, err = a.Store.License().FindByUser(userID, false)
+		licenses, err = a.store(r).License().FindByUser(userID, false)
 		// by publication
 	} else if pubID := r.URL.Query().Get("pub"); pubID != "" {
-		licenses, err = a.Store.License().FindByPublication(pubID)
+		licenses, err = a.store(r).License().FindByPublication(pubID)
 		// by status
 	} else if status := r.URL.Query().Get("status"); status != "" {
-		licenses, err = a.Store.License().FindByStatus(status)
+		licenses, err = a.store(r).License().FindByStatus(status)
 		// by device count
 	} else if count := r.URL.Query().Get("count"); count != "" {
 		// count is a "min:max" tuple
@@ -75,15 +76,15 @@ func (a *APICtrl) SearchLicenses(w http.ResponseWriter, r *http.Request) {
 		if max, err = strconv.Atoi(parts[1]); err != nil {
 			render.Render(w, r, ErrInvalidRequest(err))
 		}
-		licenses, err = a.Store.License().FindByDeviceCount(min, max)
+		licenses, err = a.store(r).License().FindByDeviceCount(min, max)
 		// by month (format: YYYY-MM)
 	} else if month := r.URL.Query().Get("month"); month != "" {
-		licenses, err = a.Store.License().FindByDate(month, stor.ExcludePubInfo)
+		licenses, err = a.store(r).License().FindByDate(month, stor.ExcludePubInfo)
 		// by date (format: YYYY-MM-DD)
 	} else if date := r.URL.Query().Get("date"); date != "" {
-		licenses, err = a.Store.License().FindByDate(date, stor.ExcludePubInfo)
+		licenses, err = a.store(r).License().FindByDate(date, stor.ExcludePubInfo)
 	} else {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 	if err != nil {
@@ -107,7 +108,7 @@ func (a *APICtrl) ListUserLicenses(w http.ResponseWriter, r *http.Request) {
 		if decodedUserID, err := url.PathUnescape(userID); err == nil {
 			userID = decodedUserID
 		}
-		licenses, err = a.Store.License().FindByUser(userID, true)
+		licenses, err = a.store(r).License().FindByUser(userID, true)
 		if err != nil {
 			render.Render(w, r, ErrServer(err))
 			return
@@ -146,12 +147,12 @@ func (a *APICtrl) CreateLicense(w http.ResponseWriter, r *http.Request) {
 	// set the max end date if there is an end date and the max end date is not set in the input.
 	// the renew max date will be 0 if not set in the configuration
 	if license.End != nil && license.MaxEnd == nil {
-		maxEnd := license.End.AddDate(0, 0, a.Config.Status.RenewMaxDays)
+		maxEnd := license.End.AddDate(0, 0, a.Config().Status.RenewMaxDays)
 		license.MaxEnd = &maxEnd
 	}
 
 	// db create
-	err := a.Store.License().Create(license)
+	err := a.store(r).License().Create(license)
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
@@ -171,13 +172,13 @@ func (a *APICtrl) GetLicense(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if licenseID := chi.URLParam(r, "licenseID"); licenseID != "" {
-		license, err = a.Store.License().Get(licenseID)
+		license, err = a.store(r).License().Get(licenseID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required license identifier")))
 		return
 	}
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 	if err := render.Render(w, r, NewLicenseInfoResponse(license)); err != nil {
@@ -202,13 +203,13 @@ func (a *APICtrl) UpdateLicense(w http.ResponseWriter, r *http.Request) {
 
 	// get the existing license
 	if licenseID := chi.URLParam(r, "licenseID"); licenseID != "" {
-		license, err = a.Store.License().Get(licenseID)
+		license, err = a.store(r).License().Get(licenseID)
 	} else {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 
@@ -225,7 +226,7 @@ func (a *APICtrl) UpdateLicense(w http.ResponseWriter, r *http.Request) {
 	license.DeviceCount = licUpdates.DeviceCount
 
 	// db update
-	err = a.Store.License().Update(license)
+	err = a.store(r).License().Update(license)
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
@@ -245,18 +246,18 @@ func (a *APICtrl) DeleteLicense(w http.ResponseWriter, r *http.Request) {
 
 	// get the existing license
 	if licenseID := chi.URLParam(r, "licenseID"); licenseID != "" {
-		license, err = a.Store.License().Get(licenseID)
+		license, err = a.store(r).License().Get(licenseID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required license ID"))) // licenseID is nil)
 		return
 	}
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 
 	// db delete
-	err = a.Store.License().Delete(license)
+	err = a.store(r).License().Delete(license)
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
@@ -269,27 +270,96 @@ func (a *APICtrl) DeleteLicense(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// ListLicenseEvents returns all events for a specific license
+// ListLicenseEvents returns the events of a specific license, filtered by type, device and time range.
 func (a *APICtrl) ListLicenseEvents(w http.ResponseWriter, r *http.Request) {
-	var events *[]stor.Event
-	var err error
 
-	if licenseID := chi.URLParam(r, "licenseID"); licenseID != "" {
-		events, err = a.Store.Event().List(licenseID)
-	} else {
+	licenseID := chi.URLParam(r, "licenseID")
+	if licenseID == "" {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required license identifier")))
 		return
 	}
+	filter, err := eventFilter(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	filter.LicenseID = licenseID
+	a.renderEvents(w, r, filter)
+}
+
+// ListEvents returns the events of every license, filtered by license, type, device and time range.
+func (a *APICtrl) ListEvents(w http.ResponseWriter, r *http.Request) {
+
+	filter, err := eventFilter(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	filter.LicenseID = r.URL.Query().Get("license")
+	a.renderEvents(w, r, filter)
+}
+
+// renderEvents renders a page of the events selected by a filter.
+// The total number of events is returned in the X-Total-Count header. If the route is not paginated
+// and the events exceed the security limit, a Link header points to the next page.
+func (a *APICtrl) renderEvents(w http.ResponseWriter, r *http.Request, filter stor.EventFilter) {
+
+	page, perPage := pagination(r)
+	events, total, err := a.store(r).Event().Search(filter, page, perPage)
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
 	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if page == 0 && int64(len(*events)) < total {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", "2")
+		q.Set("per_page", strconv.Itoa(len(*events)))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+		logging.FromRequest(r).Warnf("Events truncated, %d of %d returned", len(*events), total)
+	}
 	if err := render.RenderList(w, r, NewEventListResponse(events)); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
 }
 
+// eventTypes lists the valid values of the type filter
+var eventTypes = map[string]bool{
+	stor.EVENT_REGISTER: true,
+	stor.EVENT_RENEW:    true,
+	stor.EVENT_RETURN:   true,
+	stor.EVENT_REVOKE:   true,
+	stor.EVENT_CANCEL:   true,
+}
+
+// eventFilter reads the type, device, since and until query parameters; dates are in RFC 3339 format.
+func eventFilter(r *http.Request) (stor.EventFilter, error) {
+	q := r.URL.Query()
+	filter := stor.EventFilter{
+		Type:     q.Get("type"),
+		DeviceID: q.Get("device"),
+	}
+	if filter.Type != "" && !eventTypes[filter.Type] {
+		return filter, fmt.Errorf("invalid event type: %s", filter.Type)
+	}
+	for _, p := range []struct {
+		name string
+		date **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s parameter: %s", p.name, v)
+			}
+			*p.date = &t
+		}
+	}
+	return filter, nil
+}
+
 // --
 // Request and Response payloads for the REST api.
 // --
@@ -342,6 +412,7 @@ func (l *LicenseInfoResponse) Render(w http.ResponseWriter, r *http.Request) err
 // EventResponse is the response payload for events.
 type EventResponse struct {
 	*stor.Event
+	LicenseID string `json:"license_id,omitempty"`
 }
 
 // NewEventListResponse creates a rendered list of events
@@ -355,7 +426,7 @@ func NewEventListResponse(events *[]stor.Event) []render.Renderer {
 
 // NewEventResponse creates a rendered event
 func NewEventResponse(event *stor.Event) *EventResponse {
-	return &EventResponse{Event: event}
+	return &EventResponse{Event: event, LicenseID: event.LicenseID}
 }
 
 // Render processes event responses before marshalling.
@@ -0,0 +1,52 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/i18n"
+)
+
+// configLocalizer associates a localizer with the configuration it was built from
+type configLocalizer struct {
+	config    *conf.Config
+	localizer *i18n.Localizer
+}
+
+// localizer returns the localizer of the current configuration,
+// which is built again after a configuration reload.
+func (a *APICtrl) localizer() *i18n.Localizer {
+	cfg := a.Config()
+	if cl := a.loc.Load(); cl != nil && cl.config == cfg {
+		return cl.localizer
+	}
+	l, err := i18n.New(cfg.Localization.DefaultLanguage, cfg.Localization.Messages)
+	if err != nil {
+		// the configuration is validated, this should not happen
+		log.Errorf("Invalid localization settings, using the built-in messages: %v", err)
+		l, _ = i18n.New("", nil)
+	}
+	a.loc.Store(&configLocalizer{config: cfg, localizer: l})
+	return l
+}
+
+// Localize selects the language of the response from the Accept-Language request header.
+// The printer of localized messages is stored in the request context.
+func (a *APICtrl) Localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := a.localizer()
+		tag := l.Match(r.Header.Get("Accept-Language"))
+
+		w.Header().Set("Content-Language", tag.String())
+		w.Header().Add("Vary", "Accept-Language")
+
+		ctx := i18n.NewContext(r.Context(), l.Printer(tag))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
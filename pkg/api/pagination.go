@@ -1,5 +1,7 @@
 package api
 
+import "net/http"
+
 // PaginationKey is used to store pagination parameters in the context.
 type PaginationKey string
 
@@ -7,3 +9,11 @@ const (
 	PageKey    PaginationKey = "page"
 	PerPageKey PaginationKey = "per_page"
 )
+
+// pagination returns the pagination parameters stored in the context of a request,
+// or zero values if the route is not paginated.
+func pagination(r *http.Request) (page, perPage int) {
+	page, _ = r.Context().Value(PageKey).(int)
+	perPage, _ = r.Context().Value(PerPageKey).(int)
+	return
+}
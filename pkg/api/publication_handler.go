@@ -6,10 +6,12 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
 
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/chi/v5"
@@ -18,7 +20,7 @@ import (
 
 // ListPublications lists publications present in the database.
 func (a *APICtrl) ListPublications(w http.ResponseWriter, r *http.Request) {
-	log.Debug("List Publications")
+	logging.FromRequest(r).Debug("List Publications")
 
 	page := r.Context().Value(PageKey).(int)
 	perPage := r.Context().Value(PerPageKey).(int)
@@ -27,9 +29,9 @@ func (a *APICtrl) ListPublications(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if page == 0 || perPage == 0 {
-		publications, err = a.Store.Publication().ListAll()
+		publications, err = a.store(r).Publication().ListAll()
 	} else {
-		publications, err = a.Store.Publication().List(page, perPage)
+		publications, err = a.store(r).Publication().List(page, perPage)
 	}
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
@@ -43,7 +45,7 @@ func (a *APICtrl) ListPublications(w http.ResponseWriter, r *http.Request) {
 
 // SearchPublications searches publications corresponding to a specific criteria.
 func (a *APICtrl) SearchPublications(w http.ResponseWriter, r *http.Request) {
-	log.Debug("Search Publications ")
+	logging.FromRequest(r).Debug("Search Publications ")
 
 	var publications *[]stor.Publication
 	var err error
@@ -67,7 +69,7 @@ func (a *APICtrl) SearchPublications(w http.ResponseWriter, r *http.Request) {
 			err = errors.New("invalid content type query string parameter")
 		}
 		if contentType != "" {
-			publications, err = a.Store.Publication().FindByType(contentType)
+			publications, err = a.store(r).Publication().FindByType(contentType)
 		}
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("invalid format parameter")))
@@ -89,7 +91,7 @@ func (a *APICtrl) CreatePublication(w http.ResponseWriter, r *http.Request) {
 	// get the payload
 	data := &PublicationRequest{}
 	if err := render.Bind(r, data); err != nil {
-		log.Errorf("Create Publication: unable to bind the json request: %v", err)
+		logging.FromRequest(r).Errorf("Create Publication: unable to bind the json request: %v", err)
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
@@ -97,20 +99,25 @@ func (a *APICtrl) CreatePublication(w http.ResponseWriter, r *http.Request) {
 
 	// Check the presence of a UUID
 	if publication.UUID == "" {
-		log.Error("Create Publication: missing required publication UUID")
+		logging.FromRequest(r).Error("Create Publication: missing required publication UUID")
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication ID")))
 		return
 	}
 
+	// the version of the content and the takedown are managed by the server
+	publication.Version = 1
+	publication.ContentUpdated = nil
+	publication.TakedownPolicy, publication.TakedownReason, publication.TakedownAt = "", "", nil
+
 	// db create
-	err := a.Store.Publication().Create(publication)
+	err := a.store(r).Publication().Create(publication)
 	if err != nil {
-		log.Errorf("Create Publication: failed to create publication: %v", err)
+		logging.FromRequest(r).Errorf("Create Publication: failed to create publication: %v", err)
 		render.Render(w, r, ErrServer(err))
 		return
 	}
 
-	log.Debug("Create Publication ", publication.Title)
+	logging.FromRequest(r).Debug("Create Publication ", publication.Title)
 
 	render.Status(r, http.StatusCreated)
 	if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
@@ -126,15 +133,15 @@ func (a *APICtrl) GetPublication(w http.ResponseWriter, r *http.Request) {
 	var err error
 
 	if publicationID := chi.URLParam(r, "publicationID"); publicationID != "" {
-		log.Debugf("Get Publication: %s", publicationID)
-		publication, err = a.Store.Publication().Get(publicationID)
+		logging.FromRequest(r).Debugf("Get Publication: %s", publicationID)
+		publication, err = a.store(r).Publication().Get(publicationID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication ID")))
 	}
 
 	// if the publication has been soft-deleted, it is considered not found
 	if err != nil || publication.DeletedAt.Valid {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 	if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
@@ -154,16 +161,16 @@ func (a *APICtrl) GetPublicationByAltID(w http.ResponseWriter, r *http.Request)
 		if decodedAltID, err := url.PathUnescape(altID); err == nil {
 			altID = decodedAltID
 		}
-		publication, err = a.Store.Publication().GetByAltID(altID)
+		publication, err = a.store(r).Publication().GetByAltID(altID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required Alt ID")))
 	}
 	// if the publication has been soft-deleted, it is considered not found
 	if err != nil || publication.DeletedAt.Valid {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
-	log.Debugf("Publication ID: %s", publication.UUID)
+	logging.FromRequest(r).Debugf("Publication ID: %s", publication.UUID)
 	if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
@@ -186,18 +193,23 @@ func (a *APICtrl) UpdatePublication(w http.ResponseWriter, r *http.Request) {
 
 	// get the existing publication
 	if publicationID := chi.URLParam(r, "publicationID"); publicationID != "" {
-		log.Debugf("Update Publication: %s", publicationID)
-		publication, err = a.Store.Publication().Get(publicationID)
+		logging.FromRequest(r).Debugf("Update Publication: %s", publicationID)
+		publication, err = a.store(r).Publication().Get(publicationID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication ID"))) // publicationID is nil
 		return
 	}
 	// if the publication has been soft-deleted, it is considered not found
 	if err != nil || publication.DeletedAt.Valid {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 
+	// a change of the encrypted file must be signalled to the holders of licenses
+	contentChanged := publication.Href != pubUpdates.Href ||
+		publication.Checksum != pubUpdates.Checksum ||
+		publication.Size != pubUpdates.Size
+
 	// set updated fields
 	publication.AltID = pubUpdates.AltID
 	publication.Provider = pubUpdates.Provider
@@ -209,9 +221,19 @@ func (a *APICtrl) UpdatePublication(w http.ResponseWriter, r *http.Request) {
 	publication.ContentType = pubUpdates.ContentType
 	publication.Size = pubUpdates.Size
 	publication.Checksum = pubUpdates.Checksum
+	publication.LicenseRights = pubUpdates.LicenseRights
+	publication.LicenseExtensions = pubUpdates.LicenseExtensions
 
 	// db update
-	err = a.Store.Publication().Update(publication)
+	if contentChanged {
+		var count int64
+		count, err = a.store(r).Publication().UpdateContent(publication)
+		if err == nil {
+			logging.FromRequest(r).Infof("Update Publication: content of %s updated to version %d, %d licenses updated", publication.UUID, publication.Version, count)
+		}
+	} else {
+		err = a.store(r).Publication().Update(publication)
+	}
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
@@ -223,6 +245,49 @@ func (a *APICtrl) UpdatePublication(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxRefresh is the maximum number of publications refreshed in one request
+const maxRefresh = 1000
+
+// RefreshPublications signals that the content of a set of publications has changed,
+// e.g. after their re-encryption at the same location: the version of each publication
+// is incremented and its licenses are updated, so that clients fetch fresh licenses.
+func (a *APICtrl) RefreshPublications(w http.ResponseWriter, r *http.Request) {
+
+	// get the payload
+	data := &RefreshRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	results := []render.Renderer{}
+	for _, publicationID := range data.Publications {
+		result := &RefreshResult{UUID: publicationID}
+		results = append(results, result)
+
+		publication, err := a.store(r).Publication().Get(publicationID)
+		// soft-deleted publications are not refreshed
+		if err != nil || publication.DeletedAt.Valid {
+			result.Error = "publication not found"
+			continue
+		}
+		count, err := a.store(r).Publication().UpdateContent(publication)
+		if err != nil {
+			logging.FromRequest(r).Errorf("Refresh Publications: failed to update %s: %v", publicationID, err)
+			result.Error = "update failed"
+			continue
+		}
+		result.Version = publication.Version
+		result.Licenses = count
+	}
+	logging.FromRequest(r).Infof("Refresh Publications: %d publications processed", len(data.Publications))
+
+	if err := render.RenderList(w, r, results); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
 // DeletePublication removes an existing Publication from the database.
 func (a *APICtrl) DeletePublication(w http.ResponseWriter, r *http.Request) {
 
@@ -231,20 +296,20 @@ func (a *APICtrl) DeletePublication(w http.ResponseWriter, r *http.Request) {
 
 	// get the existing publication
 	if publicationID := chi.URLParam(r, "publicationID"); publicationID != "" {
-		log.Debugf("Delete Publication: %s", publicationID)
-		publication, err = a.Store.Publication().Get(publicationID)
+		logging.FromRequest(r).Debugf("Delete Publication: %s", publicationID)
+		publication, err = a.store(r).Publication().Get(publicationID)
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required publication ID"))) // publicationID is nil
 		return
 	}
 	// if the publication has been soft-deleted, it is considered not found
 	if err != nil || publication.DeletedAt.Valid {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
 		return
 	}
 
 	// db delete
-	err = a.Store.Publication().Delete(publication)
+	err = a.store(r).Publication().Delete(publication)
 	if err != nil {
 		render.Render(w, r, ErrServer(err))
 		return
@@ -293,7 +358,42 @@ func NewPublicationResponse(pub *stor.Publication) *PublicationResponse {
 
 // Bind post-processes requests after unmarshalling.
 func (p *PublicationRequest) Bind(r *http.Request) error {
-	return p.Publication.Validate()
+	if err := p.Publication.Validate(); err != nil {
+		return err
+	}
+	if err := lic.ValidateRightsExtensions(p.LicenseRights); err != nil {
+		return err
+	}
+	return lic.ValidateLicenseExtensions(p.LicenseExtensions)
+}
+
+// RefreshRequest is the payload of a request for refreshing publications.
+type RefreshRequest struct {
+	Publications []string `json:"publications"`
+}
+
+// RefreshResult is the result of the refresh of a publication.
+type RefreshResult struct {
+	UUID     string `json:"uuid"`
+	Version  int    `json:"version,omitempty"`
+	Licenses int64  `json:"licenses_updated"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (p *RefreshRequest) Bind(r *http.Request) error {
+	if len(p.Publications) == 0 {
+		return errors.New("missing publication identifiers")
+	}
+	if len(p.Publications) > maxRefresh {
+		return fmt.Errorf("too many publications, the maximum is %d", maxRefresh)
+	}
+	return nil
+}
+
+// Render processes responses before marshalling.
+func (res *RefreshResult) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
 }
 
 // Render processes responses before marshalling.
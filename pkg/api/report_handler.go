@@ -12,14 +12,14 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/edrlab/lcp-server/pkg/logging"
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/render"
-	log "github.com/sirupsen/logrus"
 )
 
 // ReportGeneratedLicenses generates a CSV report of licenses for a specific month or date
 func (a *APICtrl) ReportGeneratedLicenses(w http.ResponseWriter, r *http.Request) {
-	log.Debug("Report Generated Licenses, monthly or daily")
+	logging.FromRequest(r).Debug("Report Generated Licenses, monthly or daily")
 
 	var licenses *[]stor.LicenseInfo
 	var err error
@@ -31,10 +31,10 @@ func (a *APICtrl) ReportGeneratedLicenses(w http.ResponseWriter, r *http.Request
 			render.Render(w, r, ErrInvalidRequest(errors.New("cannot specify both month and date parameters")))
 			return
 		}
-		licenses, err = a.Store.License().FindByDate(month, stor.IncludePubInfo)
+		licenses, err = a.store(r).License().FindByDate(month, stor.IncludePubInfo)
 		period = month
 	} else if date := r.URL.Query().Get("date"); date != "" {
-		licenses, err = a.Store.License().FindByDate(date, stor.IncludePubInfo)
+		licenses, err = a.store(r).License().FindByDate(date, stor.IncludePubInfo)
 		period = date
 	} else {
 		render.Render(w, r, ErrInvalidRequest(errors.New("missing required parameter: either month (YYYY-MM) or date (YYYY-MM-DD)")))
@@ -57,7 +57,7 @@ func (a *APICtrl) ReportGeneratedLicenses(w http.ResponseWriter, r *http.Request
 	// Write CSV header
 	header := []string{"CreatedAt", "PublicationAltID", "PublicationTitle", "UserID", "Status", "Start", "End", "MaxEnd", "DeviceCount"}
 	if err := csvWriter.Write(header); err != nil {
-		log.Errorf("Error writing CSV header: %v", err)
+		logging.FromRequest(r).Errorf("Error writing CSV header: %v", err)
 		render.Render(w, r, ErrServer(err))
 		return
 	}
@@ -77,7 +77,7 @@ func (a *APICtrl) ReportGeneratedLicenses(w http.ResponseWriter, r *http.Request
 		}
 
 		if err := csvWriter.Write(record); err != nil {
-			log.Errorf("Error writing CSV record: %v", err)
+			logging.FromRequest(r).Errorf("Error writing CSV record: %v", err)
 			render.Render(w, r, ErrServer(err))
 			return
 		}
@@ -90,4 +90,4 @@ func formatTimePtr(t *time.Time) string {
 		return ""
 	}
 	return t.Format("2006-01-02T15:04:05Z07:00")
-}
\ No newline at end of file
+}
@@ -0,0 +1,233 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/jobs"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// JobRetention is the kind of the retention jobs, also used as audit action
+const JobRetention = "retention"
+
+// schedulerActor identifies the periodic tasks in audits
+const schedulerActor = "scheduler"
+
+// ApplyRetention applies the data retention rules once, or reports what they would do (dry run).
+func (a *APICtrl) ApplyRetention(w http.ResponseWriter, r *http.Request) {
+
+	// get the payload
+	data := &RetentionRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	rules := a.Config().DataRetention
+	if rules.LicenseDays == 0 && rules.EventDays == 0 && rules.PublicationDays == 0 {
+		render.Render(w, r, ErrInvalidRequest(errors.New("no data retention rule is configured")))
+		return
+	}
+	now := time.Now()
+
+	if data.DryRun {
+		report, err := a.retention(rules, now, true)
+		if err != nil {
+			render.Render(w, r, ErrServer(err))
+			return
+		}
+		if err := render.Render(w, r, report); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	}
+
+	logger := logging.FromRequest(r).WithField("action", JobRetention)
+	job := a.Jobs.Start(JobRetention, 1, func(ctx context.Context, j *jobs.Job) error {
+		report, err := a.retention(rules, now, false)
+		if err != nil {
+			logger.Errorf("Retention job %s failed: %v", j.Info().ID, err)
+			return err
+		}
+		line, _ := json.Marshal(report)
+		j.Record(true, line)
+		logReport(logger, report)
+		return nil
+	})
+	a.auditRetention(rules, callerID(r), data.Reason, job.Info().ID, nil, logger)
+
+	w.Header().Set("Location", "/jobs/"+job.Info().ID)
+	render.Status(r, http.StatusAccepted)
+	if err := render.Render(w, r, NewJobResponse(job.Info())); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// ExecuteRetention applies the data retention rules, as a periodic task.
+// If the rules are configured as a dry run, it only logs what they would do.
+func (a *APICtrl) ExecuteRetention(now time.Time) {
+
+	rules := a.Config().DataRetention
+	logger := log.WithField("action", JobRetention)
+	report, err := a.retention(rules, now, rules.DryRun)
+	if err != nil {
+		logger.Errorf("Failed to apply the data retention rules: %v", err)
+		return
+	}
+	logReport(logger, report)
+	if !report.DryRun && report.total() > 0 {
+		a.auditRetention(rules, schedulerActor, "retention policy", "", report, logger)
+	}
+}
+
+// retention applies the rules, or counts the entities they apply to (dry run).
+// The rules leave the aggregate counts of the dashboard unchanged: a user is pseudonymized
+// only once all their licenses are finished, licenses are never deleted, and the publications
+// deleted are no longer counted.
+func (a *APICtrl) retention(rules conf.DataRetention, now time.Time, dryRun bool) (*RetentionReport, error) {
+
+	repo := a.Store.Retention()
+	report := &RetentionReport{DryRun: dryRun}
+
+	if rules.LicenseDays > 0 {
+		users, count, err := repo.RetiredUsers(now.AddDate(0, 0, -rules.LicenseDays))
+		if err != nil {
+			return nil, err
+		}
+		report.Users, report.Licenses = int64(len(users)), count
+		if !dryRun {
+			report.Licenses = 0
+			for _, user := range users {
+				n, err := repo.PseudonymizeUser(user, pseudonym(rules.Secret, user))
+				if err != nil {
+					return report, err
+				}
+				report.Licenses += n
+			}
+		}
+	}
+
+	if rules.EventDays > 0 {
+		before := now.AddDate(0, 0, -rules.EventDays)
+		report.EventAction = rules.Events
+		var err error
+		switch {
+		case rules.Events == conf.RetentionPurge && dryRun:
+			report.Events, err = repo.CountRetiredEvents(before)
+		case rules.Events == conf.RetentionPurge:
+			report.Events, err = repo.PurgeEvents(before)
+		default:
+			var devices []string
+			devices, report.Events, err = repo.RetiredDevices(before)
+			if err == nil && !dryRun {
+				report.Events = 0
+				for _, device := range devices {
+					var n int64
+					if n, err = repo.AnonymizeDevice(device, pseudonym(rules.Secret, device), before); err != nil {
+						break
+					}
+					report.Events += n
+				}
+			}
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	if rules.PublicationDays > 0 {
+		before := now.AddDate(0, 0, -rules.PublicationDays)
+		var err error
+		if dryRun {
+			report.Publications, err = repo.CountDeletedPublications(before)
+		} else {
+			report.Publications, err = repo.PurgeDeletedPublications(before)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// pseudonym returns a stable pseudonym of an identifier, which cannot be reversed without the secret.
+func pseudonym(secret, id string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return stor.PseudonymPrefix + hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// auditRetention records an application of the retention rules.
+func (a *APICtrl) auditRetention(rules conf.DataRetention, actor, reason, jobID string, report *RetentionReport, logger *log.Entry) {
+
+	// the secret is not part of the audit
+	rules.Secret = ""
+	target, _ := json.Marshal(rules)
+	audit := &stor.Audit{
+		Timestamp: time.Now(),
+		Action:    JobRetention,
+		Actor:     actor,
+		Reason:    reason,
+		Target:    string(target),
+		JobID:     jobID,
+	}
+	if report != nil {
+		audit.Count = report.total()
+	}
+	if err := a.Store.Audit().Create(audit); err != nil {
+		logger.Errorf("Failed to record an audit: %v", err)
+	}
+}
+
+func logReport(logger *log.Entry, report *RetentionReport) {
+	logger.WithField("dry_run", report.DryRun).Infof("Data retention: %d users pseudonymized in %d licenses, %d events %s, %d publications purged",
+		report.Users, report.Licenses, report.Events, report.EventAction, report.Publications)
+}
+
+// --
+// Request and Response payloads for the REST api.
+// --
+
+// RetentionRequest is the request payload of an application of the retention rules.
+type RetentionRequest struct {
+	Reason string `json:"reason,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (rr *RetentionRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// RetentionReport counts the entities processed by the retention rules, or to be processed (dry run).
+type RetentionReport struct {
+	DryRun       bool   `json:"dry_run"`
+	Users        int64  `json:"users"`    // users pseudonymized
+	Licenses     int64  `json:"licenses"` // licenses of these users
+	Events       int64  `json:"events"`   // events anonymized or purged
+	EventAction  string `json:"event_action,omitempty"`
+	Publications int64  `json:"publications"` // publications permanently deleted
+}
+
+// Render processes responses before marshalling.
+func (rr *RetentionReport) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (rr *RetentionReport) total() int64 {
+	return rr.Licenses + rr.Events + rr.Publications
+}
@@ -8,9 +8,13 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/edrlab/lcp-server/pkg/i18n"
 	"github.com/edrlab/lcp-server/pkg/lic"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
@@ -29,12 +33,22 @@ func (a *APICtrl) StatusDoc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lh := lic.NewLicenseCtrl(a.Config, a.Store)
+	lh := a.licenseCtrl(r)
 
 	// get license info
-	license, err := a.Store.License().Get(licenseID)
+	license, err := a.store(r).License().Get(licenseID)
 	if err != nil {
-		render.Render(w, r, ErrNotFound)
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	// set the cache validators, and stop here if the client has an up-to-date copy
+	etag, lastModified := lh.StatusValidators(license)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", a.Config().Status.CacheControl)
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -45,6 +59,23 @@ func (a *APICtrl) StatusDoc(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StatusEvents returns a page of the event history of a license.
+func (a *APICtrl) StatusEvents(w http.ResponseWriter, r *http.Request) {
+
+	// check the presence of the required params
+	var licenseID string
+	if licenseID = getLicenseID(w, r); licenseID == "" {
+		return
+	}
+
+	// check that the license exists
+	if _, err := a.store(r).License().Get(licenseID); err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	a.renderEvents(w, r, stor.EventFilter{LicenseID: licenseID})
+}
+
 // Register records a new device using the license and returns a status document.
 func (a *APICtrl) Register(w http.ResponseWriter, r *http.Request) {
 
@@ -58,7 +89,7 @@ func (a *APICtrl) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lh := lic.NewLicenseCtrl(a.Config, a.Store)
+	lh := a.licenseCtrl(r)
 
 	// register
 	statusDoc, err := lh.Register(licenseID, deviceInfo)
@@ -90,7 +121,7 @@ func (a *APICtrl) Renew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lh := lic.NewLicenseCtrl(a.Config, a.Store)
+	lh := a.licenseCtrl(r)
 
 	// renew
 	statusDoc, err := lh.Renew(licenseID, deviceInfo, newEnd)
@@ -116,7 +147,7 @@ func (a *APICtrl) Return(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lh := lic.NewLicenseCtrl(a.Config, a.Store)
+	lh := a.licenseCtrl(r)
 
 	// return
 	statusDoc, err := lh.Return(licenseID, deviceInfo)
@@ -139,7 +170,7 @@ func (a *APICtrl) Revoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	lh := lic.NewLicenseCtrl(a.Config, a.Store)
+	lh := a.licenseCtrl(r)
 
 	// revoke
 	statusDoc, err := lh.Revoke(licenseID)
@@ -157,6 +188,37 @@ func (a *APICtrl) Revoke(w http.ResponseWriter, r *http.Request) {
 // local functions
 // --
 
+// licenseCtrl returns a license controller which logs with the request scoped logger.
+func (a *APICtrl) licenseCtrl(r *http.Request) *lic.LicenseCtrl {
+	lh := lic.NewLicenseCtrl(a.Live, a.store(r))
+	lh.Log = logging.FromRequest(r)
+	lh.Printer = i18n.FromContext(r.Context())
+	return lh
+}
+
+// notModified evaluates the conditional headers of a request, see RFC 9110 section 13.2.2.
+// If-Modified-Since is ignored when If-None-Match is present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			// weak comparison
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
 func getLicenseID(w http.ResponseWriter, r *http.Request) (licenseID string) {
 
 	if licenseID = chi.URLParam(r, "licenseID"); licenseID == "" {
@@ -0,0 +1,298 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/jobs"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// Audit actions related to publications
+const (
+	AuditTakedown = "takedown"
+	AuditRestore  = "restore"
+)
+
+// TakedownPublication takes a publication down, now or at a scheduled date.
+// The publication is soft-deleted and its licenses are handled according to the requested policy:
+// kept, kept with fresh licenses refused, or revoked and cancelled in a background job.
+// With dry_run set, the impact of the takedown is reported and nothing is changed.
+func (a *APICtrl) TakedownPublication(w http.ResponseWriter, r *http.Request) {
+
+	data := &TakedownRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	publication, err := a.store(r).Publication().Get(chi.URLParam(r, "publicationID"))
+	// if the publication has been soft-deleted, it is considered not found
+	if err != nil || publication.DeletedAt.Valid {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	res, err := a.takedownImpact(publication, data.Policy)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if data.DryRun {
+		res.DryRun = true
+		if err := render.Render(w, r, res); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	}
+
+	logger := logging.FromRequest(r).WithFields(log.Fields{
+		"publication_id": publication.UUID,
+		"policy":         data.Policy,
+	})
+
+	publication.TakedownPolicy = data.Policy
+	publication.TakedownReason = data.Reason
+	publication.TakedownAt = data.At
+	scheduled := data.At != nil && data.At.After(time.Now())
+
+	var job *jobs.Job
+	if scheduled {
+		err = a.store(r).Publication().Update(publication)
+	} else {
+		job, err = a.executeTakedown(publication, logger)
+	}
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+
+	// record the operation
+	target, _ := json.Marshal(map[string]interface{}{
+		"publication_id": publication.UUID,
+		"policy":         publication.TakedownPolicy,
+		"takedown_at":    publication.TakedownAt,
+	})
+	audit := &stor.Audit{
+		Timestamp: time.Now(),
+		Action:    AuditTakedown,
+		Actor:     callerID(r),
+		Reason:    data.Reason,
+		Target:    string(target),
+		Count:     int64(res.LicensesRevoked),
+	}
+	if job != nil {
+		audit.JobID = job.Info().ID
+		res.JobID = audit.JobID
+	}
+	if err := a.store(r).Audit().Create(audit); err != nil {
+		logger.Errorf("Failed to record an audit: %v", err)
+	}
+	if scheduled {
+		logger.Infof("Takedown scheduled at %s", publication.TakedownAt.Format(time.RFC3339))
+	}
+
+	res.Publication = publication
+	if scheduled || job != nil {
+		if job != nil {
+			w.Header().Set("Location", "/jobs/"+res.JobID)
+		}
+		render.Status(r, http.StatusAccepted)
+	}
+	if err := render.Render(w, r, res); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// takedownImpact reports the licenses affected by the takedown of a publication.
+func (a *APICtrl) takedownImpact(publication *stor.Publication, policy string) (*TakedownResponse, error) {
+
+	res := &TakedownResponse{
+		PublicationID:        publication.UUID,
+		Policy:               policy,
+		FreshLicensesBlocked: policy == stor.TAKEDOWN_BLOCK || policy == stor.TAKEDOWN_REVOKE,
+	}
+	for _, status := range []string{stor.STATUS_READY, stor.STATUS_ACTIVE} {
+		ids, err := a.Store.License().FindIDs(stor.LicenseFilter{PublicationID: publication.UUID, Statuses: []string{status}})
+		if err != nil {
+			return nil, err
+		}
+		if status == stor.STATUS_READY {
+			res.ReadyLicenses = len(ids)
+		} else {
+			res.ActiveLicenses = len(ids)
+		}
+	}
+	if policy == stor.TAKEDOWN_REVOKE {
+		res.LicensesRevoked = res.ReadyLicenses + res.ActiveLicenses
+	}
+	return res, nil
+}
+
+// executeTakedown soft-deletes a publication whose takedown policy is set.
+// With the revoke policy, its ready and active licenses are cancelled or revoked in a background job.
+func (a *APICtrl) executeTakedown(publication *stor.Publication, logger *log.Entry) (*jobs.Job, error) {
+
+	if publication.TakedownAt == nil || publication.TakedownAt.After(time.Now()) {
+		now := time.Now()
+		publication.TakedownAt = &now
+	}
+	if err := a.Store.Publication().Update(publication); err != nil {
+		return nil, err
+	}
+	if err := a.Store.Publication().Delete(publication); err != nil {
+		return nil, err
+	}
+	logger.WithField("reason", publication.TakedownReason).Info("Publication taken down")
+
+	if publication.TakedownPolicy != stor.TAKEDOWN_REVOKE {
+		return nil, nil
+	}
+	statuses := []string{stor.STATUS_READY, stor.STATUS_ACTIVE}
+	ids, err := a.Store.License().FindIDs(stor.LicenseFilter{PublicationID: publication.UUID, Statuses: statuses})
+	if err != nil {
+		return nil, err
+	}
+	job := a.startRevokeJob(JobRevoke, ids, statuses, logger.WithField("reason", publication.TakedownReason))
+	logger.WithField("job_id", job.Info().ID).Infof("Revocation of %d licenses started", len(ids))
+	return job, nil
+}
+
+// ExecuteScheduledTakedowns takes down the publications whose takedown is scheduled before a given date.
+// It is called periodically by the server.
+func (a *APICtrl) ExecuteScheduledTakedowns(before time.Time) {
+
+	publications, err := a.Store.Publication().FindScheduledTakedowns(before)
+	if err != nil {
+		log.Errorf("Failed to get the scheduled takedowns: %v", err)
+		return
+	}
+	for i := range *publications {
+		publication := &(*publications)[i]
+		logger := log.WithFields(log.Fields{
+			"publication_id": publication.UUID,
+			"policy":         publication.TakedownPolicy,
+		})
+		if _, err := a.executeTakedown(publication, logger); err != nil {
+			logger.Errorf("Failed to execute a scheduled takedown: %v", err)
+		}
+	}
+}
+
+// ListDeletedPublications lists the soft-deleted publications, most recently deleted first.
+func (a *APICtrl) ListDeletedPublications(w http.ResponseWriter, r *http.Request) {
+
+	page, perPage := pagination(r)
+	if page == 0 || perPage == 0 {
+		page, perPage = 1, 20
+	}
+	publications, total, err := a.store(r).Publication().ListDeleted(page, perPage)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	if err := render.RenderList(w, r, NewPublicationListResponse(publications)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// RestorePublication restores a soft-deleted publication, or cancels a scheduled takedown.
+// Licenses revoked or cancelled by a takedown remain so.
+func (a *APICtrl) RestorePublication(w http.ResponseWriter, r *http.Request) {
+
+	publication, err := a.store(r).Publication().Get(chi.URLParam(r, "publicationID"))
+	if err != nil {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	if !publication.DeletedAt.Valid && publication.TakedownPolicy == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("the publication is neither deleted nor scheduled for takedown")))
+		return
+	}
+	// a publication created since with the same alt id would hide the restored one
+	if publication.AltID != "" {
+		if latest, err := a.store(r).Publication().GetByAltID(publication.AltID); err == nil && latest.UUID != publication.UUID && !latest.DeletedAt.Valid {
+			render.Render(w, r, ErrInvalidRequest(fmt.Errorf("publication %s has the same alt id", latest.UUID)))
+			return
+		}
+	}
+
+	if err := a.store(r).Publication().Restore(publication); err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+
+	target, _ := json.Marshal(map[string]string{"publication_id": publication.UUID})
+	audit := &stor.Audit{
+		Timestamp: time.Now(),
+		Action:    AuditRestore,
+		Actor:     callerID(r),
+		Target:    string(target),
+	}
+	if err := a.store(r).Audit().Create(audit); err != nil {
+		logging.FromRequest(r).Errorf("Failed to record an audit: %v", err)
+	}
+	logging.FromRequest(r).WithField("publication_id", publication.UUID).Info("Publication restored")
+
+	if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// --
+// Request and Response payloads for the REST api.
+// --
+
+// TakedownRequest is the request payload of a publication takedown.
+type TakedownRequest struct {
+	Policy string     `json:"policy"`
+	Reason string     `json:"reason"`
+	At     *time.Time `json:"at,omitempty"` // scheduled takedown
+	DryRun bool       `json:"dry_run,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (t *TakedownRequest) Bind(r *http.Request) error {
+	switch t.Policy {
+	case stor.TAKEDOWN_KEEP, stor.TAKEDOWN_BLOCK, stor.TAKEDOWN_REVOKE:
+	default:
+		return fmt.Errorf("invalid takedown policy %q, expected keep, block or revoke", t.Policy)
+	}
+	if strings.TrimSpace(t.Reason) == "" {
+		return errors.New("missing required reason")
+	}
+	return nil
+}
+
+// TakedownResponse reports the impact of a takedown and, unless it is a dry run, the publication taken down.
+type TakedownResponse struct {
+	DryRun               bool              `json:"dry_run,omitempty"`
+	PublicationID        string            `json:"publication_id"`
+	Policy               string            `json:"policy"`
+	ReadyLicenses        int               `json:"ready_licenses"`
+	ActiveLicenses       int               `json:"active_licenses"`
+	LicensesRevoked      int               `json:"licenses_revoked"` // revoked or cancelled
+	FreshLicensesBlocked bool              `json:"fresh_licenses_blocked"`
+	JobID                string            `json:"job_id,omitempty"`
+	Publication          *stor.Publication `json:"publication,omitempty"`
+}
+
+// Render processes responses before marshalling.
+func (t *TakedownResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
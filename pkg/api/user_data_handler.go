@@ -0,0 +1,261 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditErasure is the audit action of the erasure of a user
+const AuditErasure = "erasure"
+
+// ExportUserData returns everything held about a user: licenses, publications and events.
+func (a *APICtrl) ExportUserData(w http.ResponseWriter, r *http.Request) {
+
+	userID := userIDParam(r)
+	licenses, err := a.store(r).License().FindByUser(userID, stor.ExcludePubInfo)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if len(*licenses) == 0 {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+
+	export := &UserDataExport{UserID: userID, ExportedAt: time.Now().UTC(), Licenses: []UserLicenseData{}}
+	// publications are shared by licenses; deleted publications are included
+	publications := map[string]*stor.Publication{}
+	for i := range *licenses {
+		l := &(*licenses)[i]
+		p, ok := publications[l.PublicationID]
+		if !ok {
+			if p, err = a.store(r).Publication().Get(l.PublicationID); err != nil {
+				p = &stor.Publication{UUID: l.PublicationID}
+			}
+			publications[l.PublicationID] = p
+		}
+		events, err := a.store(r).Event().SearchAll(stor.EventFilter{LicenseID: l.UUID})
+		if err != nil {
+			render.Render(w, r, ErrServer(err))
+			return
+		}
+		export.Licenses = append(export.Licenses, newUserLicenseData(l, p, *events))
+	}
+
+	logging.FromRequest(r).WithField("actor", callerID(r)).Infof("Data of a user exported, %d licenses", len(export.Licenses))
+	if err := render.Render(w, r, export); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// EraseUserData removes the identifiers of a user from their licenses and events.
+// Licenses in use are revoked or cancelled first, unless the configuration keeps them.
+func (a *APICtrl) EraseUserData(w http.ResponseWriter, r *http.Request) {
+
+	// get the payload
+	data := &ErasureRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	userID := userIDParam(r)
+	// deleted licenses are included, as their events are kept
+	licenses, err := a.store(r).Retention().UserLicenses(userID)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if len(*licenses) == 0 {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	logger := logging.FromRequest(r).WithField("action", AuditErasure)
+
+	res := &ErasureResponse{Licenses: []string{}}
+	for _, l := range *licenses {
+		res.Licenses = append(res.Licenses, l.UUID)
+	}
+
+	// revoke or cancel the licenses in use
+	if a.Config().DataRetention.Erasure != conf.ErasureKeep {
+		lh := a.licenseCtrl(r)
+		for _, l := range *licenses {
+			if l.DeletedAt.Valid || (l.Status != stor.STATUS_READY && l.Status != stor.STATUS_ACTIVE) {
+				continue
+			}
+			if _, err := lh.Revoke(l.UUID); err != nil {
+				logger.WithField("license_id", l.UUID).Errorf("Failed to revoke a license before erasure: %v", err)
+				render.Render(w, r, ErrRevoke(err))
+				return
+			}
+			res.Revoked++
+		}
+	}
+
+	// the user and its devices get random pseudonyms, which cannot be linked to them
+	if _, err := a.store(r).Retention().PseudonymizeUser(userID, randomPseudonym()); err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	devices, err := a.store(r).Retention().LicenseDevices(res.Licenses)
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	for _, device := range devices {
+		n, err := a.store(r).Retention().AnonymizeLicenseDevice(res.Licenses, device, randomPseudonym())
+		if err != nil {
+			render.Render(w, r, ErrServer(err))
+			return
+		}
+		res.Events += n
+	}
+
+	// record the operation, without the identifier of the user
+	target, _ := json.Marshal(map[string][]string{"licenses": res.Licenses})
+	audit := &stor.Audit{
+		Timestamp: time.Now(),
+		Action:    AuditErasure,
+		Actor:     callerID(r),
+		Reason:    data.Reason,
+		Target:    string(target),
+		Count:     int64(len(res.Licenses)),
+	}
+	if err := a.store(r).Audit().Create(audit); err != nil {
+		logger.Errorf("Failed to record an audit: %v", err)
+	}
+	logger.WithFields(log.Fields{
+		"actor":  audit.Actor,
+		"reason": audit.Reason,
+	}).Infof("Data of a user erased: %d licenses, %d revoked, %d events", len(res.Licenses), res.Revoked, res.Events)
+
+	if err := render.Render(w, r, res); err != nil {
+		render.Render(w, r, ErrRender(err))
+	}
+}
+
+// userIDParam returns the user identifier of a request, which may be escaped.
+func userIDParam(r *http.Request) string {
+	userID := chi.URLParam(r, "userID")
+	if decoded, err := url.PathUnescape(userID); err == nil {
+		userID = decoded
+	}
+	return userID
+}
+
+// randomPseudonym returns a pseudonym which is not derived from the value it replaces.
+func randomPseudonym() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return stor.PseudonymPrefix + hex.EncodeToString(b)
+}
+
+// --
+// Request and Response payloads for the REST api.
+// --
+
+// UserDataExport is the export of the data held about a user.
+type UserDataExport struct {
+	UserID     string            `json:"user_id"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Licenses   []UserLicenseData `json:"licenses"`
+}
+
+// Render processes responses before marshalling.
+func (u *UserDataExport) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// UserLicenseData is a license of an exported user, with its publication and events.
+type UserLicenseData struct {
+	UUID      string `json:"uuid"`
+	UserName  string `json:"user_name,omitempty"`  // kept if the server serves fresh licenses
+	UserEmail string `json:"user_email,omitempty"` // kept if the server serves fresh licenses
+	// extra user properties, kept if the server serves fresh licenses
+	UserProperties map[string]interface{} `json:"user_properties,omitempty"`
+	Provider       string                 `json:"provider"`
+	Issued         time.Time              `json:"issued"`
+	Updated        *time.Time             `json:"updated,omitempty"`
+	Start          *time.Time             `json:"start,omitempty"`
+	End            *time.Time             `json:"end,omitempty"`
+	Print          int32                  `json:"print"`
+	Copy           int32                  `json:"copy"`
+	Status         string                 `json:"status"`
+	StatusUpdated  *time.Time             `json:"status_updated,omitempty"`
+	DeviceCount    int                    `json:"device_count"`
+	Publication    UserPublication        `json:"publication"`
+	Events         []stor.Event           `json:"events"`
+}
+
+// UserPublication identifies the publication of an exported license.
+type UserPublication struct {
+	UUID    string `json:"uuid"`
+	AltID   string `json:"alt_id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Authors string `json:"authors,omitempty"`
+}
+
+func newUserLicenseData(l *stor.LicenseInfo, p *stor.Publication, events []stor.Event) UserLicenseData {
+	data := UserLicenseData{
+		UUID:          l.UUID,
+		Provider:      l.Provider,
+		Issued:        l.CreatedAt,
+		Updated:       l.Updated,
+		Start:         l.Start,
+		End:           l.End,
+		Print:         l.Print,
+		Copy:          l.Copy,
+		Status:        l.Status,
+		StatusUpdated: l.StatusUpdated,
+		DeviceCount:   l.DeviceCount,
+		Publication:   UserPublication{UUID: p.UUID, AltID: p.AltID, Title: p.Title, Authors: p.Authors},
+		Events:        events,
+	}
+	if l.FreshData != nil {
+		data.UserName, data.UserEmail = l.FreshData.UserName, l.FreshData.UserEmail
+		data.UserProperties = l.FreshData.UserProperties
+	}
+	return data
+}
+
+// ErasureRequest is the request payload of the erasure of a user.
+type ErasureRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (e *ErasureRequest) Bind(r *http.Request) error {
+	if strings.TrimSpace(e.Reason) == "" {
+		return errors.New("missing required reason")
+	}
+	return nil
+}
+
+// ErasureResponse reports the erasure of a user.
+type ErasureResponse struct {
+	Licenses []string `json:"licenses"` // licenses of the user
+	Revoked  int      `json:"revoked"`  // licenses revoked or cancelled
+	Events   int64    `json:"events"`   // events anonymized
+}
+
+// Render processes responses before marshalling.
+func (e *ErasureResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,363 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package api
+
+// Compatibility layer for the REST API of readium-lcp-server v1.
+// These handlers map the v1 routes and payloads onto the current operations,
+// for integrations which have not been migrated yet (e.g. lcpencrypt with -v2=false).
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/logging"
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	log "github.com/sirupsen/logrus"
+)
+
+// V1ContentInfo returns the information on a content, as a v1 server does.
+func (a *APICtrl) V1ContentInfo(w http.ResponseWriter, r *http.Request) {
+
+	publication, err := a.store(r).Publication().Get(chi.URLParam(r, "publicationID"))
+	// if the publication has been soft-deleted, it is considered not found
+	if err != nil || publication.DeletedAt.Valid {
+		render.Render(w, r, ErrNotFound())
+		return
+	}
+	content := &V1Content{
+		ID:            publication.UUID,
+		EncryptionKey: publication.EncryptionKey,
+		Location:      publication.Href,
+		Length:        int64(publication.Size),
+		Sha256:        publication.Checksum,
+		Type:          publication.ContentType,
+	}
+	if err := render.Render(w, r, content); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// V1StoreContent creates or updates a publication from the notification of a v1 encryption tool.
+func (a *APICtrl) V1StoreContent(w http.ResponseWriter, r *http.Request) {
+
+	data := &V1Encrypted{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	publicationID := chi.URLParam(r, "publicationID")
+	if data.ContentID != "" && data.ContentID != publicationID {
+		render.Render(w, r, ErrInvalidRequest(errors.New("the content id does not match the path")))
+		return
+	}
+	logger := logging.FromRequest(r).WithField("publication_id", publicationID)
+
+	publication, err := a.store(r).Publication().Get(publicationID)
+	if err != nil {
+		// create the publication
+		publication = data.publication(publicationID)
+		publication.Version = 1
+		if err := a.store(r).Publication().Create(publication); err != nil {
+			render.Render(w, r, ErrServer(err))
+			return
+		}
+		logger.Info("Publication created from a v1 notification")
+		render.Status(r, http.StatusCreated)
+		if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	}
+	if publication.DeletedAt.Valid {
+		render.Render(w, r, ErrInvalidRequest(errors.New("the publication has been previously deleted")))
+		return
+	}
+
+	// update the publication; a change of the encrypted file is signalled to the holders of licenses
+	updates := data.publication(publicationID)
+	contentChanged := publication.Href != updates.Href ||
+		publication.Checksum != updates.Checksum ||
+		publication.Size != updates.Size
+	publication.EncryptionKey = updates.EncryptionKey
+	publication.Href = updates.Href
+	publication.ContentType = updates.ContentType
+	publication.Size = updates.Size
+	publication.Checksum = updates.Checksum
+	if contentChanged {
+		var count int64
+		count, err = a.store(r).Publication().UpdateContent(publication)
+		if err == nil {
+			logger.Infof("Content updated to version %d from a v1 notification, %d licenses updated", publication.Version, count)
+		}
+	} else {
+		err = a.store(r).Publication().Update(publication)
+	}
+	if err != nil {
+		render.Render(w, r, ErrServer(err))
+		return
+	}
+	if err := render.Render(w, r, NewPublicationResponse(publication)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// V1GenerateLicense generates a license from a v1 partial license.
+func (a *APICtrl) V1GenerateLicense(w http.ResponseWriter, r *http.Request) {
+
+	partial := &V1PartialLicense{}
+	if err := render.Bind(r, partial); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	licRequest, err := partial.licenseRequest()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	licRequest.PublicationID = chi.URLParam(r, "publicationID")
+	if err := licRequest.validate(); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	pubInfo, errResp := a.requestedPublication(licRequest)
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	license, errResp := a.generateLicense(a.Config(), pubInfo, licRequest, logging.FromRequest(r))
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	logging.FromRequest(r).WithFields(log.Fields{
+		"license_id": license.UUID,
+		"user_id":    licRequest.UserID,
+	}).Infof("New license generated from a v1 request on %s", license.Issued.Format(time.RFC822))
+
+	render.Status(r, http.StatusCreated)
+	if err := render.Render(w, r, NewLicenseResponse(license)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// V1GetLicense returns a fresh license if a partial license is sent,
+// or the partial license stored on the server (http 206) if the request has no payload.
+func (a *APICtrl) V1GetLicense(w http.ResponseWriter, r *http.Request) {
+
+	licenseID := chi.URLParam(r, "licenseID")
+
+	partial := &V1PartialLicense{}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	if err := json.NewDecoder(r.Body).Decode(partial); errors.Is(err, io.EOF) {
+		licInfo, err := a.store(r).License().Get(licenseID)
+		if err != nil {
+			render.Render(w, r, ErrNotFound())
+			return
+		}
+		render.Status(r, http.StatusPartialContent)
+		if err := render.Render(w, r, newV1PartialLicense(licInfo)); err != nil {
+			render.Render(w, r, ErrRender(err))
+		}
+		return
+	} else if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	licRequest, err := partial.licenseRequest()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if licRequest.TextHint == "" || licRequest.PassHash == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("missing required user key")))
+		return
+	}
+	license, errResp := a.freshLicense(licenseID, licRequest, logging.FromRequest(r))
+	if errResp != nil {
+		render.Render(w, r, errResp)
+		return
+	}
+	if err := render.Render(w, r, NewLicenseResponse(license)); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// --
+// Request and Response payloads of the v1 REST api.
+// --
+
+// V1Content is the v1 representation of a publication.
+type V1Content struct {
+	ID            string `json:"id"`
+	EncryptionKey []byte `json:"key,omitempty"`
+	Location      string `json:"location"`
+	Length        int64  `json:"length"`
+	Sha256        string `json:"sha256"`
+	Type          string `json:"type"`
+}
+
+// Render processes responses before marshalling.
+func (c *V1Content) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// V1Encrypted is the notification sent by a v1 encryption tool.
+type V1Encrypted struct {
+	ContentID   string `json:"content-id"`
+	ContentKey  []byte `json:"content-encryption-key"`
+	StorageMode int    `json:"storage-mode"`
+	Output      string `json:"protected-content-location"`
+	FileName    string `json:"protected-content-disposition"`
+	Size        int64  `json:"protected-content-length"`
+	Checksum    string `json:"protected-content-sha256"`
+	ContentType string `json:"protected-content-type,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+// The v1 server could store the encrypted file itself: here it must already be available at a URL.
+func (e *V1Encrypted) Bind(r *http.Request) error {
+	if len(e.ContentKey) == 0 {
+		return errors.New("missing required content encryption key")
+	}
+	if u, err := url.Parse(e.Output); err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("the protected content location must be a URL")
+	}
+	return nil
+}
+
+// publication maps a v1 notification to a publication.
+func (e *V1Encrypted) publication(publicationID string) *stor.Publication {
+	pub := &stor.Publication{
+		UUID:          publicationID,
+		Title:         e.FileName,
+		EncryptionKey: e.ContentKey,
+		Href:          e.Output,
+		ContentType:   e.ContentType,
+		Size:          uint32(e.Size),
+		Checksum:      e.Checksum,
+	}
+	if pub.Title == "" {
+		pub.Title = publicationID
+	}
+	if pub.ContentType == "" {
+		pub.ContentType = "application/epub+zip"
+	}
+	return pub
+}
+
+// V1PartialLicense is the partial license sent to, or returned by, a v1 server.
+type V1PartialLicense struct {
+	Provider   string            `json:"provider,omitempty"`
+	ID         string            `json:"id,omitempty"`
+	Issued     *time.Time        `json:"issued,omitempty"`
+	Updated    *time.Time        `json:"updated,omitempty"`
+	User       V1User            `json:"user"`
+	Encryption *V1Encryption     `json:"encryption,omitempty"`
+	Rights     *V1Rights         `json:"rights,omitempty"`
+	Links      []json.RawMessage `json:"links,omitempty"` // ignored
+}
+
+// V1User is the user information of a v1 partial license.
+type V1User struct {
+	ID        string   `json:"id"`
+	Email     string   `json:"email,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Encrypted []string `json:"encrypted,omitempty"`
+}
+
+// V1Encryption is the encryption information of a v1 partial license.
+type V1Encryption struct {
+	Profile string `json:"profile,omitempty"`
+	UserKey struct {
+		TextHint string `json:"text_hint,omitempty"`
+		Value    []byte `json:"value,omitempty"`     // passphrase hash, kept by v1 for backward compatibility
+		HexValue string `json:"hex_value,omitempty"` // hex encoded passphrase hash, takes precedence
+	} `json:"user_key"`
+}
+
+// V1Rights are the rights of a v1 partial license.
+type V1Rights struct {
+	Print *int32     `json:"print,omitempty"`
+	Copy  *int32     `json:"copy,omitempty"`
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// Bind post-processes requests after unmarshalling.
+func (l *V1PartialLicense) Bind(r *http.Request) error {
+	return nil
+}
+
+// Render processes responses before marshalling.
+func (l *V1PartialLicense) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// licenseRequest maps a v1 partial license to a license request.
+func (l *V1PartialLicense) licenseRequest() (*LicenseRequest, error) {
+	licRequest := &LicenseRequest{
+		UserID:        l.User.ID,
+		UserName:      l.User.Name,
+		UserEmail:     l.User.Email,
+		UserEncrypted: l.User.Encrypted,
+	}
+	if l.Encryption != nil {
+		licRequest.Profile = l.Encryption.Profile
+		licRequest.TextHint = l.Encryption.UserKey.TextHint
+		licRequest.PassHash = l.Encryption.UserKey.HexValue
+		if licRequest.PassHash == "" && l.Encryption.UserKey.Value != nil {
+			licRequest.PassHash = hex.EncodeToString(l.Encryption.UserKey.Value)
+		}
+		if value, err := hex.DecodeString(licRequest.PassHash); err != nil || len(value) != 32 {
+			return nil, errors.New("invalid user key value, expected a 32 bytes hash")
+		}
+	}
+	if l.Rights != nil {
+		licRequest.Print = l.Rights.Print
+		licRequest.Copy = l.Rights.Copy
+		licRequest.Start = l.Rights.Start
+		licRequest.End = l.Rights.End
+	}
+	return licRequest, nil
+}
+
+// newV1PartialLicense returns the partial license corresponding to stored license info.
+func newV1PartialLicense(licInfo *stor.LicenseInfo) *V1PartialLicense {
+	issued := licInfo.CreatedAt
+	partial := &V1PartialLicense{
+		Provider: licInfo.Provider,
+		ID:       licInfo.UUID,
+		Issued:   &issued,
+		Updated:  licInfo.Updated,
+		User:     V1User{ID: licInfo.UserID},
+		Rights: &V1Rights{
+			Start: licInfo.Start,
+			End:   licInfo.End,
+		},
+	}
+	// -1 is stored for no print/copy limits
+	if licInfo.Print >= 0 {
+		partial.Rights.Print = &licInfo.Print
+	}
+	if licInfo.Copy >= 0 {
+		partial.Rights.Copy = &licInfo.Copy
+	}
+	return partial
+}
@@ -5,8 +5,12 @@
 package conf
 
 import (
+	"crypto/tls"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -15,21 +19,273 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// Insecure default values, used when no value is configured
+const (
+	DefaultJWTSecretKey  = "default_jwt_secret_key_please_change_in_production"
+	DefaultAdminUsername = "admin"
+	DefaultAdminPassword = "supersecret"
+)
+
 // LCP Server configuration
 type Config struct {
-	LogLevel      string `yaml:"log_level" envconfig:"loglevel"` // "debug", "info", "warn", "error"
+	LogLevel      string `yaml:"log_level" envconfig:"loglevel"`   // "debug", "info", "warn", "error"
+	LogFormat     string `yaml:"log_format" envconfig:"logformat"` // "json" (default), "text"
 	PublicBaseUrl string `yaml:"public_base_url" envconfig:"publicbaseurl"`
 	Port          int    `yaml:"port"`
 	Dsn           string `yaml:"dsn"`
+	TLS           `yaml:"tls"`
+	Listeners     `yaml:"listeners"`
 	Access        `yaml:"access"`
 	Certificate   `yaml:"certificate"`
 	License       `yaml:"license"`
 	Status        `yaml:"status"`
 	Dashboard     `yaml:"dashboard"`
 	JWT           `yaml:"jwt"`
+	Health        `yaml:"health"`
+	CORS          `yaml:"cors"`
+	RateLimit     `yaml:"rate_limit"`
+	Localization  `yaml:"localization"`
+	Jobs          `yaml:"jobs"`
+	V1Compat      `yaml:"v1_compat"`
+	DataRetention `yaml:"data_retention"`
+	FreshLicense  `yaml:"fresh_license"`
+	Storage       `yaml:"storage"`
 	Resources     string `yaml:"resources"`
 }
 
+// Client authentication modes on the private api
+const (
+	ClientAuthNone     = "none"     // basic authentication only
+	ClientAuthOptional = "optional" // client certificate or basic authentication
+	ClientAuthRequire  = "require"  // client certificate only
+)
+
+type TLS struct {
+	Cert       string            `yaml:"cert" envconfig:"tls_cert"`              // Path; https is enabled if set
+	PrivateKey string            `yaml:"private_key" envconfig:"tls_privatekey"` // Path
+	MinVersion string            `yaml:"min_version" envconfig:"tls_minversion"` // "1.2" (default), "1.3"
+	MaxVersion string            `yaml:"max_version" envconfig:"tls_maxversion"` // no maximum if not set
+	ClientCA   string            `yaml:"client_ca" envconfig:"tls_clientca"`     // Path to the CA certificates accepted for client authentication
+	ClientAuth string            `yaml:"client_auth" envconfig:"tls_clientauth"` // "none" (default), "optional", "require"
+	Identities map[string]string `yaml:"identities" envconfig:"tls_identities"`  // certificate subject (DN or CN) -> caller identity
+}
+
+// Enabled returns true if the server must listen over https.
+func (t TLS) Enabled() bool {
+	return t.Cert != ""
+}
+
+// ParseTLSVersion converts a TLS version like "1.2" to its crypto/tls value.
+func ParseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q", v)
+}
+
+// Listeners separates the public LSD traffic from the private and dashboard traffic.
+// If no private address is set, every route is served by the public listener.
+type Listeners struct {
+	Public  Listener `yaml:"public" envconfig:"listeners_public"`
+	Private Listener `yaml:"private" envconfig:"listeners_private"`
+}
+
+type Listener struct {
+	Addr         string `yaml:"addr" envconfig:"addr"`                   // host:port; the public address defaults to :port
+	ReadTimeout  int    `yaml:"read_timeout" envconfig:"readtimeout"`    // seconds
+	WriteTimeout int    `yaml:"write_timeout" envconfig:"writetimeout"`  // seconds
+	IdleTimeout  int    `yaml:"idle_timeout" envconfig:"idletimeout"`    // seconds
+	MaxBodyBytes int64  `yaml:"max_body_bytes" envconfig:"maxbodybytes"` // maximum size of a request body
+}
+
+// setDefaults sets the listener values which are not configured
+func (l *Listener) setDefaults(def Listener) {
+	if l.ReadTimeout == 0 {
+		l.ReadTimeout = def.ReadTimeout
+	}
+	if l.WriteTimeout == 0 {
+		l.WriteTimeout = def.WriteTimeout
+	}
+	if l.IdleTimeout == 0 {
+		l.IdleTimeout = def.IdleTimeout
+	}
+	if l.MaxBodyBytes == 0 {
+		l.MaxBodyBytes = def.MaxBodyBytes
+	}
+}
+
+// Split returns true if the private and dashboard routes are served by a dedicated listener.
+func (l Listeners) Split() bool {
+	return l.Private.Addr != ""
+}
+
+// Single returns the listener serving every route when no private address is set:
+// the public address, with the private limits, as publications and license batches are sent through it.
+// Body sizes are still limited per route, see the router.
+func (l Listeners) Single() Listener {
+	single := l.Private
+	single.Addr = l.Public.Addr
+	return single
+}
+
+// Rate limit stores
+const (
+	RateLimitMemory   = "memory"   // limits are applied per server instance
+	RateLimitDatabase = "database" // limits are shared by every server instance
+)
+
+// RateLimit configures the rate limiting of the status document routes
+type RateLimit struct {
+	Enabled        bool     `yaml:"enabled" envconfig:"ratelimit_enabled"`                // false if not set
+	Store          string   `yaml:"store" envconfig:"ratelimit_store"`                    // "memory" (default) or "database"
+	TrustProxy     bool     `yaml:"trust_proxy" envconfig:"ratelimit_trustproxy"`         // use the client ip set by a reverse proxy
+	TrustedProxies []string `yaml:"trusted_proxies" envconfig:"ratelimit_trustedproxies"` // ip addresses or CIDR ranges of the reverse proxies
+	PerIP          Limit    `yaml:"per_ip" envconfig:"ratelimit_perip"`                   // requests per client ip
+	PerLicense     Limit    `yaml:"per_license" envconfig:"ratelimit_perlicense"`         // requests per license
+	PerDevice      Limit    `yaml:"per_device" envconfig:"ratelimit_perdevice"`           // register, renew and return requests per device
+}
+
+// ParseProxy parses the ip address or CIDR range of a trusted reverse proxy.
+func ParseProxy(p string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(p); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(p)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip address or CIDR range %q", p)
+	}
+	bits := 8 * len(ip.To4())
+	if bits == 0 {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Proxies returns the ranges of the trusted reverse proxies; invalid values are reported by the validation.
+func (r RateLimit) Proxies() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, p := range r.TrustedProxies {
+		if n, err := ParseProxy(p); err == nil {
+			nets = append(nets, n)
+		}
+	}
+	return nets
+}
+
+type Limit struct {
+	PerMinute *int `yaml:"per_minute" envconfig:"perminute"` // 0 disables the limit; a default value applies if not set
+	Burst     int  `yaml:"burst" envconfig:"burst"`
+}
+
+// Rate returns the number of requests per minute, 0 if the limit is disabled.
+func (l Limit) Rate() int {
+	if l.PerMinute == nil {
+		return 0
+	}
+	return *l.PerMinute
+}
+
+// setDefaults sets the limit values which are not configured
+func (l *Limit) setDefaults(perMinute, burst int) {
+	if l.PerMinute == nil {
+		l.PerMinute = &perMinute
+		if l.Burst == 0 {
+			l.Burst = burst
+		}
+	}
+}
+
+// Jobs configures the batch operations executed by the server
+type Jobs struct {
+	Concurrency int `yaml:"concurrency" envconfig:"jobs_concurrency"` // number of items processed in parallel; 4 if not set
+	Retention   int `yaml:"retention" envconfig:"jobs_retention"`     // minutes during which the results of a background job are kept; 60 if not set
+	MaxResults  int `yaml:"max_results" envconfig:"jobs_maxresults"`  // results kept in memory per background job; 10000 if not set
+}
+
+// V1Compat exposes the readium-lcp-server v1 REST API on the private api, for integrations not migrated yet
+type V1Compat struct {
+	Enabled bool   `yaml:"enabled" envconfig:"v1compat_enabled"`
+	Prefix  string `yaml:"prefix" envconfig:"v1compat_prefix"` // path prefix of the v1 routes; "/v1" if not set
+}
+
+// DataRetention configures the removal of personal data from finished licenses;
+// a rule is disabled if its number of days is 0.
+type DataRetention struct {
+	Enabled         bool   `yaml:"enabled" envconfig:"retention_enabled"`                  // runs the rules periodically
+	Interval        int    `yaml:"interval" envconfig:"retention_interval"`                // hours between two periodic runs; 24 if not set
+	DryRun          bool   `yaml:"dry_run" envconfig:"retention_dryrun"`                   // periodic runs only log what they would do
+	Secret          string `yaml:"secret" envconfig:"retention_secret"`                    // key of the pseudonyms
+	LicenseDays     int    `yaml:"license_days" envconfig:"retention_licensedays"`         // pseudonymizes users whose licenses are finished for this number of days
+	EventDays       int    `yaml:"event_days" envconfig:"retention_eventdays"`             // processes the events of licenses finished for this number of days
+	Events          string `yaml:"events" envconfig:"retention_events"`                    // "anonymize" (default) or "purge"
+	PublicationDays int    `yaml:"publication_days" envconfig:"retention_publicationdays"` // hard-deletes unused publications deleted for this number of days
+	Erasure         string `yaml:"erasure" envconfig:"retention_erasure"`                  // licenses in use by an erased user: "revoke" (default) or "keep"
+}
+
+// Processing of the events by the retention rules
+const (
+	RetentionAnonymize = "anonymize"
+	RetentionPurge     = "purge"
+)
+
+// Processing of the licenses in use by the erasure of a user
+const (
+	ErasureRevoke = "revoke"
+	ErasureKeep   = "keep"
+)
+
+// FreshLicense configures the fresh license route served by the server itself.
+// If enabled, the status documents point to this route, via links signed and valid for a limited time,
+// instead of the fresh license link of the provider.
+type FreshLicense struct {
+	Enabled  bool   `yaml:"enabled" envconfig:"freshlicense_enabled"`   // captures user info at license generation
+	Secret   string `yaml:"secret" envconfig:"freshlicense_secret"`     // key of the link signatures
+	Validity int    `yaml:"validity" envconfig:"freshlicense_validity"` // minutes during which a link is valid; 60 if not set
+}
+
+// Storage backends of encrypted publications
+const (
+	StorageFS = "fs"
+	StorageS3 = "s3"
+)
+
+// Storage configures the encrypted publications served by the server itself, via signed links.
+// The storage key of a publication is the file name of its href.
+type Storage struct {
+	Mode      string    `yaml:"mode" envconfig:"storage_mode"`           // "fs" or "s3"; publications are not served if not set
+	Directory string    `yaml:"directory" envconfig:"storage_directory"` // local directory of the fs mode
+	S3        S3Storage `yaml:"s3" envconfig:"storage_s3"`
+	Secret    string    `yaml:"secret" envconfig:"storage_secret"`     // key of the link signatures
+	Validity  int       `yaml:"validity" envconfig:"storage_validity"` // minutes during which a link is valid; 60 if not set
+}
+
+type S3Storage struct {
+	Bucket    string `yaml:"bucket" envconfig:"bucket"`
+	Region    string `yaml:"region" envconfig:"region"`
+	Endpoint  string `yaml:"endpoint" envconfig:"endpoint"` // for s3 compatible services; aws if not set
+	AccessKey string `yaml:"access_key" envconfig:"accesskey"`
+	SecretKey string `yaml:"secret_key" envconfig:"secretkey"`
+	PathStyle bool   `yaml:"path_style" envconfig:"pathstyle"` // path-style addressing, for s3 compatible services
+}
+
+// Enabled returns true if the server serves encrypted publications.
+func (s Storage) Enabled() bool {
+	return s.Mode != ""
+}
+
+// Localization of status document messages and problem details
+type Localization struct {
+	DefaultLanguage string                       `yaml:"default_language" envconfig:"localization_defaultlanguage"` // used if no language requested by the client is available; "en" if not set
+	Messages        map[string]map[string]string `yaml:"messages" ignored:"true"`                                   // language -> message key -> message, overriding the built-in messages
+}
+
 type Access struct {
 	Username string `yaml:"username" envconfig:"access_username"`
 	Password string `yaml:"password" envconfig:"access_password"`
@@ -52,6 +308,8 @@ type Status struct {
 	RenewDefaultDays            int    `yaml:"renew_default_days" envconfig:"status_renewdefaultdays"`
 	RenewMaxDays                int    `yaml:"renew_max_days" envconfig:"status_renewmaxdays"`
 	RenewLink                   string `yaml:"renew_link" envconfig:"status_renewlink"`
+	CacheControl                string `yaml:"cache_control" envconfig:"status_cachecontrol"` // Cache-Control header of status documents
+	MaxEvents                   int    `yaml:"max_events" envconfig:"status_maxevents"`       // 0: all events are included in status documents, the 1000 most recent ones at most
 }
 
 type Dashboard struct {
@@ -64,6 +322,25 @@ type JWT struct {
 	Admin     map[string]string `yaml:"admin" envconfig:"jwt_admin"` // list of admin usernames and passwords
 }
 
+type Health struct {
+	ShutdownDelay         *int `yaml:"shutdown_delay" envconfig:"health_shutdowndelay"`                   // seconds during which the server reports not ready before shutting down; 5 if not set
+	CertExpiryWarningDays int  `yaml:"cert_expiry_warning_days" envconfig:"health_certexpirywarningdays"` // number of days before expiry which triggers a warning
+}
+
+// Delay returns the number of seconds during which the server reports not ready before shutting down, 0 for none.
+func (h Health) Delay() int {
+	if h.ShutdownDelay == nil {
+		return 0
+	}
+	return *h.ShutdownDelay
+}
+
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" envconfig:"cors_allowedorigins"` // URLs of the frontends calling the server
+	AllowedMethods   []string `yaml:"allowed_methods" envconfig:"cors_allowedmethods"`
+	AllowCredentials *bool    `yaml:"allow_credentials" envconfig:"cors_allowcredentials"` // true if not set
+}
+
 func Init(configFile string) (*Config, error) {
 
 	var c Config
@@ -173,11 +450,78 @@ func Init(configFile string) (*Config, error) {
 	if c.Port == 0 {
 		c.Port = 8989
 	}
+	if c.Listeners.Public.Addr == "" {
+		c.Listeners.Public.Addr = ":" + strconv.Itoa(c.Port)
+	}
+	// status documents and registrations are small and must be processed quickly
+	c.Listeners.Public.setDefaults(Listener{ReadTimeout: 10, WriteTimeout: 30, IdleTimeout: 60, MaxBodyBytes: 64 << 10})
+	// publications and licenses requests may be large and slow
+	c.Listeners.Private.setDefaults(Listener{ReadTimeout: 60, WriteTimeout: 300, IdleTimeout: 120, MaxBodyBytes: 10 << 20})
+	if c.TLS.MinVersion == "" {
+		c.TLS.MinVersion = "1.2"
+	}
+	if c.TLS.ClientAuth == "" {
+		c.TLS.ClientAuth = ClientAuthNone
+	}
+	if c.Status.CacheControl == "" {
+		// clients may store status documents, but must revalidate them on each use
+		c.Status.CacheControl = "private, no-cache"
+	}
 	if c.Dashboard.ExcessiveSharingThreshold == 0 {
 		c.Dashboard.ExcessiveSharingThreshold = 1
 	}
+	if c.Health.ShutdownDelay == nil {
+		delay := 5
+		c.Health.ShutdownDelay = &delay
+	}
+	if c.Health.CertExpiryWarningDays == 0 {
+		c.Health.CertExpiryWarningDays = 30
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		c.CORS.AllowedOrigins = []string{"http://localhost:8090", "http://localhost:8091"}
+	}
+	if len(c.CORS.AllowedMethods) == 0 {
+		c.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	}
+	if c.CORS.AllowCredentials == nil {
+		allow := true
+		c.CORS.AllowCredentials = &allow
+	}
+	if c.RateLimit.Store == "" {
+		c.RateLimit.Store = RateLimitMemory
+	}
+	c.RateLimit.PerIP.setDefaults(120, 60)
+	c.RateLimit.PerLicense.setDefaults(30, 20)
+	c.RateLimit.PerDevice.setDefaults(10, 10)
+	if c.Jobs.Concurrency == 0 {
+		c.Jobs.Concurrency = 4
+	}
+	if c.Jobs.Retention == 0 {
+		c.Jobs.Retention = 60
+	}
+	if c.Jobs.MaxResults == 0 {
+		c.Jobs.MaxResults = 10000
+	}
+	if c.DataRetention.Interval == 0 {
+		c.DataRetention.Interval = 24
+	}
+	if c.DataRetention.Events == "" {
+		c.DataRetention.Events = RetentionAnonymize
+	}
+	if c.DataRetention.Erasure == "" {
+		c.DataRetention.Erasure = ErasureRevoke
+	}
+	if c.FreshLicense.Validity == 0 {
+		c.FreshLicense.Validity = 60
+	}
+	if c.Storage.Validity == 0 {
+		c.Storage.Validity = 60
+	}
+	if c.V1Compat.Prefix == "" {
+		c.V1Compat.Prefix = "/v1"
+	}
 	if c.JWT.SecretKey == "" {
-		c.JWT.SecretKey = "default_jwt_secret_key_please_change_in_production"
+		c.JWT.SecretKey = DefaultJWTSecretKey
 	}
 
 	// Initialize JWT.Admin map if nil
@@ -187,7 +531,7 @@ func Init(configFile string) (*Config, error) {
 
 	// Set default dashboard account if none configured
 	if len(c.JWT.Admin) == 0 {
-		c.JWT.Admin["admin"] = "supersecret"
+		c.JWT.Admin[DefaultAdminUsername] = DefaultAdminPassword
 		log.Println("⚠️  No dashboard account configured, using default account: admin/supersecret")
 	}
 
@@ -0,0 +1,92 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package conf
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// initConfig loads a configuration file with the given content.
+func initConfig(t *testing.T, content string) *Config {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Init(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestListenerDefaults(t *testing.T) {
+
+	c := initConfig(t, "port: 9000\n")
+	if c.Listeners.Split() {
+		t.Fatal("expected a single listener")
+	}
+	// the single listener keeps the public address, but must not cut large uploads and downloads
+	single := c.Listeners.Single()
+	if single.Addr != ":9000" || single.ReadTimeout != 60 || single.WriteTimeout != 300 || single.IdleTimeout != 120 {
+		t.Errorf("unexpected single listener %+v", single)
+	}
+	if c.Listeners.Public.WriteTimeout != 30 || c.Listeners.Public.MaxBodyBytes != 64<<10 {
+		t.Errorf("unexpected public listener %+v", c.Listeners.Public)
+	}
+
+	c = initConfig(t, "listeners:\n  private:\n    addr: \":9001\"\n    write_timeout: 600\n")
+	if !c.Listeners.Split() || c.Listeners.Private.WriteTimeout != 600 || c.Listeners.Public.WriteTimeout != 30 {
+		t.Errorf("unexpected listeners %+v", c.Listeners)
+	}
+}
+
+func TestRateLimitDefaults(t *testing.T) {
+
+	c := initConfig(t, "port: 9000\n")
+	if c.RateLimit.Enabled {
+		t.Error("rate limiting must be disabled by default")
+	}
+	if c.RateLimit.PerIP.Rate() != 120 || c.RateLimit.PerIP.Burst != 60 || c.RateLimit.PerDevice.Rate() != 10 {
+		t.Errorf("unexpected default limits %+v", c.RateLimit)
+	}
+
+	// a limit is disabled by a per_minute value of 0
+	c = initConfig(t, "rate_limit:\n  enabled: true\n  per_ip:\n    per_minute: 0\n  per_license:\n    burst: 5\n")
+	if !c.RateLimit.Enabled || c.RateLimit.PerIP.Rate() != 0 {
+		t.Errorf("expected a disabled ip limit, got %+v", c.RateLimit.PerIP)
+	}
+	if c.RateLimit.PerLicense.Rate() != 30 || c.RateLimit.PerLicense.Burst != 5 {
+		t.Errorf("unexpected license limit %+v", c.RateLimit.PerLicense)
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+
+	for _, p := range []string{"10.0.0.0/8", "192.168.1.1", "::1", "2001:db8::/32"} {
+		if _, err := ParseProxy(p); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+	}
+	if n, _ := ParseProxy("192.168.1.1"); n.Contains(net.ParseIP("192.168.1.2")) {
+		t.Error("a single address must not match another one")
+	}
+	if _, err := ParseProxy("proxy.example.com"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestShutdownDelay(t *testing.T) {
+
+	if c := initConfig(t, "port: 9000\n"); c.Health.Delay() != 5 {
+		t.Errorf("expected a default delay of 5 seconds, got %d", c.Health.Delay())
+	}
+	// a delay of 0 is kept, the server then drains its connections immediately
+	if c := initConfig(t, "health:\n  shutdown_delay: 0\n"); c.Health.Delay() != 0 {
+		t.Errorf("expected no delay, got %d", c.Health.Delay())
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package conf
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Live gives reader-safe access to a configuration which can be replaced at runtime.
+// Readers must not modify the configuration they get; a new configuration is swapped in as a whole.
+type Live struct {
+	current atomic.Pointer[Config]
+}
+
+// NewLive returns a live configuration initialized with c.
+func NewLive(c *Config) *Live {
+	l := &Live{}
+	l.current.Store(c)
+	return l
+}
+
+// Config returns the current configuration.
+func (l *Live) Config() *Config {
+	return l.current.Load()
+}
+
+// Swap replaces the current configuration and returns the previous one.
+func (l *Live) Swap(c *Config) *Config {
+	return l.current.Swap(c)
+}
+
+// staticFields lists the properties which are only taken into account at startup,
+// with accessors used to compare and preserve them during a reload.
+var staticFields = []struct {
+	path string
+	get  func(c *Config) interface{}
+	keep func(dst, src *Config)
+}{
+	{"port", func(c *Config) interface{} { return c.Port }, func(d, s *Config) { d.Port = s.Port }},
+	{"dsn", func(c *Config) interface{} { return c.Dsn }, func(d, s *Config) { d.Dsn = s.Dsn }},
+	{"listeners", func(c *Config) interface{} { return c.Listeners }, func(d, s *Config) { d.Listeners = s.Listeners }},
+	{"tls", func(c *Config) interface{} { return c.TLS }, func(d, s *Config) { d.TLS = s.TLS }},
+	{"access", func(c *Config) interface{} { return c.Access }, func(d, s *Config) { d.Access = s.Access }},
+	{"certificate", func(c *Config) interface{} { return c.Certificate }, func(d, s *Config) { d.Certificate = s.Certificate }},
+	{"cors", func(c *Config) interface{} { return c.CORS }, func(d, s *Config) { d.CORS = s.CORS }},
+	{"rate_limit.store", func(c *Config) interface{} { return c.RateLimit.Store }, func(d, s *Config) { d.RateLimit.Store = s.RateLimit.Store }},
+	{"v1_compat", func(c *Config) interface{} { return c.V1Compat }, func(d, s *Config) { d.V1Compat = s.V1Compat }},
+	{"resources", func(c *Config) interface{} { return c.Resources }, func(d, s *Config) { d.Resources = s.Resources }},
+	{"storage.mode", func(c *Config) interface{} { return c.Storage.Mode }, func(d, s *Config) { d.Storage.Mode = s.Storage.Mode }},
+	{"storage.directory", func(c *Config) interface{} { return c.Storage.Directory }, func(d, s *Config) { d.Storage.Directory = s.Storage.Directory }},
+	{"storage.s3", func(c *Config) interface{} { return c.Storage.S3 }, func(d, s *Config) { d.Storage.S3 = s.Storage.S3 }},
+}
+
+// StaticChanges returns the paths of the properties which differ between c and next,
+// but cannot be changed without a restart of the server.
+func (c *Config) StaticChanges(next *Config) []string {
+	var changed []string
+	for _, f := range staticFields {
+		if !reflect.DeepEqual(f.get(c), f.get(next)) {
+			changed = append(changed, f.path)
+		}
+	}
+	return changed
+}
+
+// KeepStatic copies into c the properties of prev which cannot be changed without a restart.
+func (c *Config) KeepStatic(prev *Config) {
+	for _, f := range staticFields {
+		f.keep(c, prev)
+	}
+}
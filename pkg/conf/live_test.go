@@ -0,0 +1,54 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package conf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLive(t *testing.T) {
+
+	c1 := validConfig()
+	l := NewLive(c1)
+	if l.Config() != c1 {
+		t.Fatal("expected the initial configuration")
+	}
+
+	c2 := validConfig()
+	c2.Status.RenewDefaultDays = 14
+	if prev := l.Swap(c2); prev != c1 {
+		t.Error("expected the previous configuration to be returned")
+	}
+	if l.Config().Status.RenewDefaultDays != 14 {
+		t.Error("expected the new configuration")
+	}
+}
+
+func TestStaticChanges(t *testing.T) {
+
+	prev := validConfig()
+	next := validConfig()
+	next.Port = 9000
+	next.Dsn = "sqlite3://lcp.db"
+	next.Status.RenewDefaultDays = 14
+	next.LogLevel = "debug"
+
+	changed := prev.StaticChanges(next)
+	if !reflect.DeepEqual(changed, []string{"port", "dsn"}) {
+		t.Errorf("unexpected static changes %v", changed)
+	}
+
+	next.KeepStatic(prev)
+	if next.Port != prev.Port || next.Dsn != prev.Dsn {
+		t.Error("expected static settings to be kept")
+	}
+	if next.Status.RenewDefaultDays != 14 || next.LogLevel != "debug" {
+		t.Error("expected live settings to be changed")
+	}
+	if len(prev.StaticChanges(next)) != 0 {
+		t.Error("expected no static change after KeepStatic")
+	}
+}
@@ -0,0 +1,440 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package conf
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/edrlab/lcp-server/pkg/i18n"
+	"github.com/jtacoma/uritemplates"
+	log "github.com/sirupsen/logrus"
+)
+
+// Severity of a configuration problem
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Problem is a configuration problem, attached to the path of the faulty property.
+type Problem struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Severity, p.Field, p.Message)
+}
+
+// Problems is the list of problems found in a configuration.
+type Problems []Problem
+
+// HasErrors returns true if at least one problem is an error, not a mere warning.
+func (ps Problems) HasErrors() bool {
+	for _, p := range ps {
+		if p.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Error implements the error interface, listing every error.
+func (ps Problems) Error() string {
+	var msgs []string
+	for _, p := range ps {
+		if p.Severity == SeverityError {
+			msgs = append(msgs, p.Field+": "+p.Message)
+		}
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// profileRegexp matches the LCP profiles accepted by the server
+var profileRegexp = regexp.MustCompile(`^http://readium\.org/lcp/(basic-profile|profile-1\.0|profile-2\.[0-9x])$`)
+
+var knownDialects = []string{"sqlite3", "mysql", "postgres"}
+
+// Validate checks the configuration and returns every problem found.
+// It must be called on a configuration processed by Init, i.e. with defaults set.
+func (c *Config) Validate() Problems {
+	v := &validator{}
+
+	if c.LogLevel != "" {
+		if _, err := log.ParseLevel(c.LogLevel); err != nil {
+			v.errorf("log_level", "unknown log level %q", c.LogLevel)
+		}
+	}
+	if c.LogFormat != "" && c.LogFormat != "json" && c.LogFormat != "text" {
+		v.errorf("log_format", "unknown log format %q, use json or text", c.LogFormat)
+	}
+
+	v.absoluteURL("public_base_url", c.PublicBaseUrl, true)
+	if strings.HasPrefix(c.PublicBaseUrl, "http://") {
+		v.warnf("public_base_url", "the public base url is not served over https")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		v.errorf("port", "invalid port %d", c.Port)
+	}
+	v.dsn("dsn", c.Dsn)
+	v.listener("listeners.public", c.Listeners.Public)
+	if c.Listeners.Split() {
+		v.listener("listeners.private", c.Listeners.Private)
+		if c.Listeners.Private.Addr == c.Listeners.Public.Addr {
+			v.errorf("listeners.private.addr", "must differ from the public address")
+		}
+	}
+
+	// tls
+	v.tls(c.TLS)
+
+	// private api
+	if c.Access.Username == "" || c.Access.Password == "" {
+		v.errorf("access", "username and password are required to protect the private api")
+	}
+
+	// certificate
+	v.file("certificate.cert", c.Certificate.Cert)
+	v.file("certificate.private_key", c.Certificate.PrivateKey)
+
+	// license
+	v.absoluteURL("license.provider", c.License.Provider, true)
+	if c.License.Profile == "" {
+		v.errorf("license.profile", "a default profile is required")
+	} else if !profileRegexp.MatchString(c.License.Profile) {
+		v.errorf("license.profile", "unknown LCP profile %q", c.License.Profile)
+	}
+	v.template("license.hint_link", c.License.HintLink, true)
+
+	// status
+	v.template("status.fresh_license_link", c.Status.FreshLicenseLink, !c.FreshLicense.Enabled)
+	v.template("status.renew_link", c.Status.RenewLink, false)
+	if c.Status.RenewDefaultDays < 0 {
+		v.errorf("status.renew_default_days", "must be positive or zero")
+	}
+	if c.Status.RenewMaxDays < 0 {
+		v.errorf("status.renew_max_days", "must be positive or zero")
+	}
+	if c.Status.MaxEvents < 0 {
+		v.errorf("status.max_events", "must be positive or zero")
+	}
+	if c.Status.RenewMaxDays > 0 && c.Status.RenewDefaultDays > c.Status.RenewMaxDays {
+		v.warnf("status.renew_default_days", "greater than renew_max_days, extensions will be capped")
+	}
+
+	// dashboard
+	if c.Dashboard.ExcessiveSharingThreshold < 0 {
+		v.errorf("dashboard.excessive_sharing_threshold", "must be positive or zero")
+	}
+	if c.JWT.SecretKey == DefaultJWTSecretKey {
+		v.warnf("jwt.secret_key", "insecure default secret key")
+	} else if len(c.JWT.SecretKey) < 32 {
+		v.warnf("jwt.secret_key", "the secret key should be at least 32 characters long")
+	}
+	if pwd, ok := c.JWT.Admin[DefaultAdminUsername]; ok && pwd == DefaultAdminPassword {
+		v.warnf("jwt.admin", "insecure default dashboard account")
+	}
+
+	// health
+	if c.Health.Delay() < 0 {
+		v.errorf("health.shutdown_delay", "must be positive or zero")
+	}
+
+	// cors
+	for i, origin := range c.CORS.AllowedOrigins {
+		field := fmt.Sprintf("cors.allowed_origins[%d]", i)
+		if origin == "*" {
+			if c.CORS.AllowCredentials != nil && *c.CORS.AllowCredentials {
+				v.errorf(field, "a wildcard origin cannot be used when credentials are allowed")
+			} else {
+				v.warnf(field, "any origin is allowed")
+			}
+			continue
+		}
+		v.absoluteURL(field, origin, true)
+	}
+	for i, method := range c.CORS.AllowedMethods {
+		if method != strings.ToUpper(method) || strings.ContainsAny(method, " ,") {
+			v.errorf(fmt.Sprintf("cors.allowed_methods[%d]", i), "invalid http method %q", method)
+		}
+	}
+
+	// rate limiting
+	if c.RateLimit.Store != "" && c.RateLimit.Store != RateLimitMemory && c.RateLimit.Store != RateLimitDatabase {
+		v.errorf("rate_limit.store", "unknown store %q, use memory or database", c.RateLimit.Store)
+	}
+	v.limit("rate_limit.per_ip", c.RateLimit.PerIP)
+	v.limit("rate_limit.per_license", c.RateLimit.PerLicense)
+	v.limit("rate_limit.per_device", c.RateLimit.PerDevice)
+	for i, p := range c.RateLimit.TrustedProxies {
+		if _, err := ParseProxy(p); err != nil {
+			v.errorf(fmt.Sprintf("rate_limit.trusted_proxies[%d]", i), "%v", err)
+		}
+	}
+
+	// jobs
+	if c.Jobs.Concurrency < 0 {
+		v.errorf("jobs.concurrency", "must be positive")
+	}
+	if c.Jobs.Retention < 0 {
+		v.errorf("jobs.retention", "must be positive")
+	}
+	if c.Jobs.MaxResults < 0 {
+		v.errorf("jobs.max_results", "must be positive")
+	}
+
+	// v1 compatibility
+	if c.V1Compat.Enabled && (!strings.HasPrefix(c.V1Compat.Prefix, "/") || c.V1Compat.Prefix == "/" || strings.HasSuffix(c.V1Compat.Prefix, "/")) {
+		v.errorf("v1_compat.prefix", "must start with a slash and must not end with one")
+	}
+
+	// data retention
+	dr := c.DataRetention
+	if dr.Interval < 0 {
+		v.errorf("data_retention.interval", "must be positive")
+	}
+	if dr.LicenseDays < 0 || dr.EventDays < 0 || dr.PublicationDays < 0 {
+		v.errorf("data_retention", "numbers of days must be positive")
+	}
+	if dr.Events != "" && dr.Events != RetentionAnonymize && dr.Events != RetentionPurge {
+		v.errorf("data_retention.events", "unknown value %q, use anonymize or purge", dr.Events)
+	}
+	if dr.Erasure != "" && dr.Erasure != ErasureRevoke && dr.Erasure != ErasureKeep {
+		v.errorf("data_retention.erasure", "unknown value %q, use revoke or keep", dr.Erasure)
+	}
+	if dr.Secret == "" && (dr.LicenseDays > 0 || (dr.EventDays > 0 && dr.Events != RetentionPurge)) {
+		v.errorf("data_retention.secret", "required to pseudonymize users and devices")
+	}
+
+	// fresh licenses served by the server
+	if c.FreshLicense.Enabled {
+		if c.FreshLicense.Secret == "" {
+			v.errorf("fresh_license.secret", "required to sign the fresh license links")
+		} else if len(c.FreshLicense.Secret) < 32 {
+			v.warnf("fresh_license.secret", "the secret should be at least 32 characters long")
+		}
+	}
+	if c.FreshLicense.Validity < 0 {
+		v.errorf("fresh_license.validity", "must be positive")
+	}
+
+	// storage of encrypted publications
+	st := c.Storage
+	switch st.Mode {
+	case "":
+	case StorageFS:
+		if fi, err := os.Stat(st.Directory); err != nil || !fi.IsDir() {
+			v.errorf("storage.directory", "%q is not an accessible directory", st.Directory)
+		}
+	case StorageS3:
+		if st.S3.Bucket == "" {
+			v.errorf("storage.s3.bucket", "required")
+		}
+		if st.S3.Region == "" {
+			v.errorf("storage.s3.region", "required")
+		}
+		if st.S3.Endpoint != "" {
+			v.absoluteURL("storage.s3.endpoint", st.S3.Endpoint, true)
+		}
+	default:
+		v.errorf("storage.mode", "unknown storage %q, use fs or s3", st.Mode)
+	}
+	if st.Enabled() {
+		if st.Secret == "" {
+			v.errorf("storage.secret", "required to sign the download links")
+		} else if len(st.Secret) < 32 {
+			v.warnf("storage.secret", "the secret should be at least 32 characters long")
+		}
+	}
+	if st.Validity < 0 {
+		v.errorf("storage.validity", "must be positive")
+	}
+
+	// localization
+	if _, err := i18n.New(c.Localization.DefaultLanguage, c.Localization.Messages); err != nil {
+		v.errorf("localization", "%v", err)
+	}
+
+	// static resources
+	if c.Resources != "" {
+		if fi, err := os.Stat(c.Resources); err != nil || !fi.IsDir() {
+			v.errorf("resources", "%q is not an accessible directory", c.Resources)
+		}
+	}
+
+	return v.problems
+}
+
+// validator accumulates configuration problems
+type validator struct {
+	problems Problems
+}
+
+func (v *validator) errorf(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Field: field, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Field: field, Severity: SeverityWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// absoluteURL checks that a value is an absolute http(s) url
+func (v *validator) absoluteURL(field, value string, required bool) {
+	if value == "" {
+		if required {
+			v.errorf(field, "required")
+		}
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.errorf(field, "%q is not an absolute http(s) url", value)
+	}
+}
+
+// template checks that a value is a uri template which can be expanded with a license id
+func (v *validator) template(field, value string, required bool) {
+	if value == "" {
+		if required {
+			v.errorf(field, "required")
+		}
+		return
+	}
+	tmpl, err := uritemplates.Parse(value)
+	if err != nil {
+		v.errorf(field, "unparseable uri template: %v", err)
+		return
+	}
+	expanded, err := tmpl.Expand(map[string]interface{}{"license_id": "00000000-0000-0000-0000-000000000000"})
+	if err != nil {
+		v.errorf(field, "the uri template cannot be expanded: %v", err)
+		return
+	}
+	u, err := url.Parse(expanded)
+	if err != nil || !u.IsAbs() {
+		v.errorf(field, "the expanded template %q is not an absolute url", expanded)
+		return
+	}
+	hasLicenseID := false
+	for _, name := range tmpl.Names() {
+		if name == "license_id" {
+			hasLicenseID = true
+		}
+	}
+	if !hasLicenseID {
+		v.warnf(field, "the uri template does not reference {license_id}")
+	}
+}
+
+// tls checks the https and client authentication settings
+func (v *validator) tls(t TLS) {
+	if t.Enabled() || t.PrivateKey != "" {
+		v.file("tls.cert", t.Cert)
+		v.file("tls.private_key", t.PrivateKey)
+	}
+	var min, max uint16
+	var err error
+	if t.MinVersion != "" {
+		if min, err = ParseTLSVersion(t.MinVersion); err != nil {
+			v.errorf("tls.min_version", "%v", err)
+		} else if min < tls.VersionTLS12 {
+			v.warnf("tls.min_version", "TLS versions older than 1.2 are insecure")
+		}
+	}
+	if t.MaxVersion != "" {
+		if max, err = ParseTLSVersion(t.MaxVersion); err != nil {
+			v.errorf("tls.max_version", "%v", err)
+		} else if min != 0 && max < min {
+			v.errorf("tls.max_version", "lower than min_version")
+		}
+	}
+	switch t.ClientAuth {
+	case "", ClientAuthNone:
+		return
+	case ClientAuthOptional, ClientAuthRequire:
+	default:
+		v.errorf("tls.client_auth", "unknown client authentication mode %q, use none, optional or require", t.ClientAuth)
+		return
+	}
+	if !t.Enabled() {
+		v.errorf("tls.client_auth", "client certificates require https, set tls.cert and tls.private_key")
+	}
+	v.file("tls.client_ca", t.ClientCA)
+	if len(t.Identities) == 0 {
+		v.errorf("tls.identities", "no certificate subject is mapped to a caller identity")
+	}
+}
+
+// listener checks the address, timeouts and body size limit of a listener
+func (v *validator) listener(field string, l Listener) {
+	if _, port, err := net.SplitHostPort(l.Addr); err != nil {
+		v.errorf(field+".addr", "invalid address %q, expected host:port", l.Addr)
+	} else if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		v.errorf(field+".addr", "invalid port in %q", l.Addr)
+	}
+	if l.ReadTimeout < 0 {
+		v.errorf(field+".read_timeout", "must be positive or zero")
+	}
+	if l.WriteTimeout < 0 {
+		v.errorf(field+".write_timeout", "must be positive or zero")
+	}
+	if l.IdleTimeout < 0 {
+		v.errorf(field+".idle_timeout", "must be positive or zero")
+	}
+	if l.MaxBodyBytes < 0 {
+		v.errorf(field+".max_body_bytes", "must be positive or zero")
+	}
+}
+
+// limit checks a rate limit
+func (v *validator) limit(field string, l Limit) {
+	if l.Rate() < 0 {
+		v.errorf(field+".per_minute", "must be positive or zero")
+	}
+	if l.Burst < 0 {
+		v.errorf(field+".burst", "must be positive or zero")
+	}
+}
+
+// dsn checks the form of a data source name
+func (v *validator) dsn(field, value string) {
+	if value == "" {
+		v.errorf(field, "required")
+		return
+	}
+	parts := strings.SplitN(value, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		v.errorf(field, "expected a dialect://connection string")
+		return
+	}
+	for _, d := range knownDialects {
+		if parts[0] == d {
+			return
+		}
+	}
+	v.errorf(field, "unknown database dialect %q", parts[0])
+}
+
+// file checks that a required file is readable
+func (v *validator) file(field, path string) {
+	if path == "" {
+		v.errorf(field, "required")
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		v.errorf(field, "unreadable file: %v", err)
+		return
+	}
+	f.Close()
+}
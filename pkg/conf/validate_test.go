@@ -0,0 +1,161 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package conf
+
+import (
+	"testing"
+)
+
+func validConfig() *Config {
+	allow := true
+	return &Config{
+		PublicBaseUrl: "https://lcp.edrlab.org",
+		Port:          8989,
+		Dsn:           "sqlite3://file::memory:?cache=shared",
+		Listeners:     Listeners{Public: Listener{Addr: ":8989"}},
+		Access:        Access{Username: "user", Password: "password"},
+		Certificate: Certificate{
+			Cert:       "../test/cert/cert-edrlab-test.pem",
+			PrivateKey: "../test/cert/privkey-edrlab-test.pem",
+		},
+		License: License{
+			Provider: "http://edrlab.org",
+			Profile:  "http://readium.org/lcp/basic-profile",
+			HintLink: "https://www.edrlab.org/lcp-help/{license_id}",
+		},
+		Status: Status{
+			FreshLicenseLink: "https://lcp.edrlab.org/freshlicense/{license_id}",
+			RenewDefaultDays: 7,
+			RenewMaxDays:     40,
+		},
+		JWT: JWT{
+			SecretKey: "a-very-long-secret-key-used-for-testing-only",
+			Admin:     map[string]string{"laurent": "secret"},
+		},
+		CORS: CORS{
+			AllowedOrigins:   []string{"https://dashboard.edrlab.org"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowCredentials: &allow,
+		},
+	}
+}
+
+func hasProblem(problems Problems, field, severity string) bool {
+	for _, p := range problems {
+		if p.Field == field && p.Severity == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidateOK(t *testing.T) {
+
+	problems := validConfig().Validate()
+	if len(problems) != 0 {
+		t.Errorf("expected no problem, got %v", problems)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+
+	c := validConfig()
+	c.PublicBaseUrl = ""
+	c.License.HintLink = "https://www.edrlab.org/{license_id"
+	c.Status.FreshLicenseLink = "/freshlicense/{license_id}"
+	c.License.Profile = "http://readium.org/lcp/profile-3.0"
+	c.Status.RenewDefaultDays = -1
+	c.Status.MaxEvents = -1
+	c.Jobs.Concurrency = -2
+	c.V1Compat = V1Compat{Enabled: true, Prefix: "v1/"}
+	c.DataRetention = DataRetention{LicenseDays: 365, Events: "delete", Erasure: "anonymize"}
+	c.FreshLicense = FreshLicense{Enabled: true, Validity: -1}
+	c.Storage = Storage{Mode: StorageS3, S3: S3Storage{Region: "eu-west-3"}}
+	c.Dsn = "oracle://db"
+	c.CORS.AllowedOrigins = []string{"*"}
+	c.Listeners.Private = Listener{Addr: ":8989", ReadTimeout: -1}
+	c.RateLimit.Store = "redis"
+	c.RateLimit.PerDevice.Burst = -1
+	c.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "proxy"}
+	c.Localization.Messages = map[string]map[string]string{"fr": {"status.lost": "La licence est perdue"}}
+
+	problems := c.Validate()
+	if !problems.HasErrors() {
+		t.Fatal("expected errors")
+	}
+	for _, field := range []string{
+		"public_base_url",
+		"license.hint_link",
+		"status.fresh_license_link",
+		"license.profile",
+		"status.renew_default_days",
+		"status.max_events",
+		"jobs.concurrency",
+		"v1_compat.prefix",
+		"data_retention.events",
+		"data_retention.secret",
+		"data_retention.erasure",
+		"fresh_license.secret",
+		"fresh_license.validity",
+		"storage.s3.bucket",
+		"storage.secret",
+		"dsn",
+		"cors.allowed_origins[0]",
+		"listeners.private.addr",
+		"listeners.private.read_timeout",
+		"rate_limit.store",
+		"rate_limit.per_device.burst",
+		"rate_limit.trusted_proxies[1]",
+		"localization",
+	} {
+		if !hasProblem(problems, field, SeverityError) {
+			t.Errorf("expected an error on %s", field)
+		}
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+
+	c := validConfig()
+	c.TLS = TLS{
+		Cert:       "../test/cert/cert-edrlab-test.pem",
+		PrivateKey: "../test/cert/privkey-edrlab-test.pem",
+		MinVersion: "1.2",
+		ClientAuth: ClientAuthRequire,
+		ClientCA:   "../test/cert/cert-edrlab-test.pem",
+		Identities: map[string]string{"lcpencrypt": "lcpencrypt"},
+	}
+	if problems := c.Validate(); len(problems) != 0 {
+		t.Errorf("expected no problem, got %v", problems)
+	}
+
+	c.TLS.Cert = ""
+	c.TLS.MaxVersion = "1.1"
+	c.TLS.Identities = nil
+	problems := c.Validate()
+	for _, field := range []string{"tls.cert", "tls.max_version", "tls.client_auth", "tls.identities"} {
+		if !hasProblem(problems, field, SeverityError) {
+			t.Errorf("expected an error on %s", field)
+		}
+	}
+}
+
+func TestValidateInsecureDefaults(t *testing.T) {
+
+	c := validConfig()
+	c.JWT.SecretKey = DefaultJWTSecretKey
+	c.JWT.Admin = map[string]string{DefaultAdminUsername: DefaultAdminPassword}
+	c.PublicBaseUrl = "http://lcp.edrlab.org"
+
+	problems := c.Validate()
+	if problems.HasErrors() {
+		t.Errorf("expected warnings only, got %v", problems)
+	}
+	for _, field := range []string{"jwt.secret_key", "jwt.admin", "public_base_url"} {
+		if !hasProblem(problems, field, SeverityWarning) {
+			t.Errorf("expected a warning on %s", field)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The i18n package provides the localized messages of status documents and problem details.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Message keys
+const (
+	StatusReady     = "status.ready"
+	StatusActive    = "status.active"
+	StatusRevoked   = "status.revoked"
+	StatusReturned  = "status.returned"
+	StatusCancelled = "status.cancelled"
+	StatusExpired   = "status.expired" // argument: the end date of the license
+
+	ErrorInvalidRequest  = "error.invalid_request"
+	ErrorBodyTooLarge    = "error.body_too_large"
+	ErrorRender          = "error.render"
+	ErrorServer          = "error.server"
+	ErrorUnauthorized    = "error.unauthorized"
+	ErrorForbidden       = "error.forbidden"
+	ErrorTooManyRequests = "error.too_many_requests"
+	ErrorNotFound        = "error.not_found"
+	ErrorRegistration    = "error.registration"
+	ErrorRenew           = "error.renew"
+	ErrorReturn          = "error.return"
+	ErrorRevoke          = "error.revoke"
+)
+
+// messages are the built-in messages, per language; English is the reference language
+var messages = map[string]map[string]string{
+	"en": {
+		StatusReady:          "The license is in ready state",
+		StatusActive:         "The license is in active state",
+		StatusRevoked:        "The license is in revoked state",
+		StatusReturned:       "The license is in returned state",
+		StatusCancelled:      "The license is in cancelled state",
+		StatusExpired:        "The license has expired on %s",
+		ErrorInvalidRequest:  "Invalid request",
+		ErrorBodyTooLarge:    "Request body too large",
+		ErrorRender:          "Error rendering response",
+		ErrorServer:          "An unexpected error has occurred",
+		ErrorUnauthorized:    "Authentication required",
+		ErrorForbidden:       "Access forbidden",
+		ErrorTooManyRequests: "Too many requests",
+		ErrorNotFound:        "Resource not found",
+		ErrorRegistration:    "Error registering a device",
+		ErrorRenew:           "Error extending a license",
+		ErrorReturn:          "Error returning a license",
+		ErrorRevoke:          "Error revoking / cancelling a license",
+	},
+	"fr": {
+		StatusReady:          "La licence est prête",
+		StatusActive:         "La licence est active",
+		StatusRevoked:        "La licence a été révoquée",
+		StatusReturned:       "La licence a été restituée",
+		StatusCancelled:      "La licence a été annulée",
+		StatusExpired:        "La licence a expiré le %s",
+		ErrorInvalidRequest:  "Requête invalide",
+		ErrorBodyTooLarge:    "Corps de requête trop volumineux",
+		ErrorRender:          "Erreur lors de la génération de la réponse",
+		ErrorServer:          "Une erreur inattendue s'est produite",
+		ErrorUnauthorized:    "Authentification requise",
+		ErrorForbidden:       "Accès interdit",
+		ErrorTooManyRequests: "Trop de requêtes",
+		ErrorNotFound:        "Ressource introuvable",
+		ErrorRegistration:    "Erreur lors de l'enregistrement d'un appareil",
+		ErrorRenew:           "Erreur lors de la prolongation d'une licence",
+		ErrorReturn:          "Erreur lors de la restitution d'une licence",
+		ErrorRevoke:          "Erreur lors de la révocation / l'annulation d'une licence",
+	},
+	"de": {
+		StatusReady:          "Die Lizenz ist bereit",
+		StatusActive:         "Die Lizenz ist aktiv",
+		StatusRevoked:        "Die Lizenz wurde widerrufen",
+		StatusReturned:       "Die Lizenz wurde zurückgegeben",
+		StatusCancelled:      "Die Lizenz wurde storniert",
+		StatusExpired:        "Die Lizenz ist am %s abgelaufen",
+		ErrorInvalidRequest:  "Ungültige Anfrage",
+		ErrorBodyTooLarge:    "Anfrage zu groß",
+		ErrorRender:          "Fehler beim Erstellen der Antwort",
+		ErrorServer:          "Ein unerwarteter Fehler ist aufgetreten",
+		ErrorUnauthorized:    "Authentifizierung erforderlich",
+		ErrorForbidden:       "Zugriff verweigert",
+		ErrorTooManyRequests: "Zu viele Anfragen",
+		ErrorNotFound:        "Ressource nicht gefunden",
+		ErrorRegistration:    "Fehler bei der Registrierung eines Geräts",
+		ErrorRenew:           "Fehler bei der Verlängerung einer Lizenz",
+		ErrorReturn:          "Fehler bei der Rückgabe einer Lizenz",
+		ErrorRevoke:          "Fehler beim Widerruf / der Stornierung einer Lizenz",
+	},
+	"es": {
+		StatusReady:          "La licencia está lista",
+		StatusActive:         "La licencia está activa",
+		StatusRevoked:        "La licencia ha sido revocada",
+		StatusReturned:       "La licencia ha sido devuelta",
+		StatusCancelled:      "La licencia ha sido cancelada",
+		StatusExpired:        "La licencia caducó el %s",
+		ErrorInvalidRequest:  "Solicitud no válida",
+		ErrorBodyTooLarge:    "Cuerpo de la solicitud demasiado grande",
+		ErrorRender:          "Error al generar la respuesta",
+		ErrorServer:          "Se ha producido un error inesperado",
+		ErrorUnauthorized:    "Autenticación requerida",
+		ErrorForbidden:       "Acceso prohibido",
+		ErrorTooManyRequests: "Demasiadas solicitudes",
+		ErrorNotFound:        "Recurso no encontrado",
+		ErrorRegistration:    "Error al registrar un dispositivo",
+		ErrorRenew:           "Error al prolongar una licencia",
+		ErrorReturn:          "Error al devolver una licencia",
+		ErrorRevoke:          "Error al revocar / cancelar una licencia",
+	},
+}
+
+// IsKey returns true if key identifies a message.
+func IsKey(key string) bool {
+	_, ok := messages["en"][key]
+	return ok
+}
+
+// Localizer selects the language of the messages and formats them.
+type Localizer struct {
+	catalog *catalog.Builder
+	tags    []language.Tag
+	matcher language.Matcher
+}
+
+// New returns a localizer using the built-in messages, completed or replaced by the overrides,
+// which are indexed by language then by message key. The default language is used when
+// no language requested by the client is available; English is used if it is not set.
+func New(defaultLang string, overrides map[string]map[string]string) (*Localizer, error) {
+
+	def := language.English
+	if defaultLang != "" {
+		var err error
+		if def, err = language.Parse(defaultLang); err != nil {
+			return nil, fmt.Errorf("invalid default language %q: %w", defaultLang, err)
+		}
+	}
+
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	tags := []language.Tag{def}
+	seen := map[language.Tag]bool{def: true}
+
+	add := func(lang string, msgs map[string]string) error {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return fmt.Errorf("invalid language %q: %w", lang, err)
+		}
+		for key, msg := range msgs {
+			if !IsKey(key) {
+				return fmt.Errorf("unknown message key %q", key)
+			}
+			if err := b.SetString(tag, key, msg); err != nil {
+				return err
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		return nil
+	}
+
+	for _, lang := range sortedKeys(messages) {
+		if err := add(lang, messages[lang]); err != nil {
+			return nil, err
+		}
+	}
+	for _, lang := range sortedKeys(overrides) {
+		if err := add(lang, overrides[lang]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Localizer{
+		catalog: b,
+		tags:    tags,
+		matcher: language.NewMatcher(tags),
+	}, nil
+}
+
+func sortedKeys(m map[string]map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Match returns the available language which best matches an Accept-Language header value.
+func (l *Localizer) Match(acceptLanguage string) language.Tag {
+	requested, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(requested) == 0 {
+		return l.tags[0]
+	}
+	_, index, confidence := l.matcher.Match(requested...)
+	if confidence == language.No {
+		return l.tags[0]
+	}
+	return l.tags[index]
+}
+
+// Printer returns a printer of the messages in a given language.
+func (l *Localizer) Printer(tag language.Tag) *Printer {
+	return &Printer{
+		Tag:      tag,
+		p:        message.NewPrinter(tag, message.Catalog(l.catalog)),
+		fallback: message.NewPrinter(language.English, message.Catalog(l.catalog)),
+	}
+}
+
+// Printer formats messages in a given language.
+type Printer struct {
+	Tag      language.Tag
+	p        *message.Printer
+	fallback *message.Printer
+}
+
+// Sprintf formats the message identified by key.
+// The English message is used when the message is missing in the language of the printer.
+func (p *Printer) Sprintf(key string, args ...interface{}) string {
+	if msg := p.p.Sprintf(key, args...); msg != key {
+		return msg
+	}
+	return p.fallback.Sprintf(key, args...)
+}
+
+// English formats messages in English, the reference language.
+var English *Printer
+
+func init() {
+	l, err := New("", nil)
+	if err != nil {
+		panic(err)
+	}
+	English = l.Printer(language.English)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying a printer.
+func NewContext(ctx context.Context, p *Printer) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the printer stored in the context, or nil.
+func FromContext(ctx context.Context) *Printer {
+	p, _ := ctx.Value(contextKey{}).(*Printer)
+	return p
+}
@@ -0,0 +1,66 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package i18n
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func TestMessagesComplete(t *testing.T) {
+
+	for lang, msgs := range messages {
+		for key := range messages["en"] {
+			if _, ok := msgs[key]; !ok {
+				t.Errorf("missing message %s in %s", key, lang)
+			}
+		}
+	}
+}
+
+func TestLocalizer(t *testing.T) {
+
+	l, err := New("en", map[string]map[string]string{
+		"fr": {StatusActive: "Votre licence est active"},
+		"it": {StatusActive: "La licenza è attiva"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		accept string
+		lang   language.Tag
+		msg    string
+	}{
+		{"", language.English, "The license is in active state"},
+		{"fr-CA, en;q=0.5", language.French, "Votre licence est active"},
+		{"de-DE", language.German, "Die Lizenz ist aktiv"},
+		{"it", language.Italian, "La licenza è attiva"},
+		{"ja", language.English, "The license is in active state"},
+	}
+	for _, c := range cases {
+		tag := l.Match(c.accept)
+		if tag != c.lang {
+			t.Errorf("%q: expected %s, got %s", c.accept, c.lang, tag)
+		}
+		if msg := l.Printer(tag).Sprintf(StatusActive); msg != c.msg {
+			t.Errorf("%q: expected %q, got %q", c.accept, c.msg, msg)
+		}
+	}
+
+	// a message missing in a language falls back to English
+	if msg := l.Printer(language.Italian).Sprintf(ErrorNotFound); msg != "Resource not found" {
+		t.Errorf("unexpected fallback %q", msg)
+	}
+	if msg := l.Printer(language.French).Sprintf(StatusExpired, "01 Jan 26 00:00 UTC"); msg != "La licence a expiré le 01 Jan 26 00:00 UTC" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	if _, err := New("en", map[string]map[string]string{"fr": {"status.unknown": "x"}}); err == nil {
+		t.Error("expected an error on an unknown key")
+	}
+}
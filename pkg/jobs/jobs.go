@@ -0,0 +1,149 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The jobs package tracks long running tasks executed in the background, e.g. batches of licenses.
+// Jobs are kept in memory: they are only visible from the server instance which runs them,
+// and are lost when the server restarts.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job status values
+const (
+	StatusRunning = "running"
+	StatusDone    = "done"
+)
+
+// Info is a snapshot of the state of a job.
+type Info struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	Dropped    int        `json:"dropped,omitempty"` // results not kept, beyond the maximum number of results of a job
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Job is a task running in the background, which records a result per processed item.
+type Job struct {
+	mu         sync.Mutex
+	info       Info
+	results    [][]byte
+	maxResults int
+}
+
+// Record adds the result of an item, as a JSON object.
+// Once the maximum number of results is reached, the item is counted but its result is not kept.
+func (j *Job) Record(ok bool, result []byte) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Processed++
+	if ok {
+		j.info.Succeeded++
+	} else {
+		j.info.Failed++
+	}
+	if j.maxResults > 0 && len(j.results) >= j.maxResults {
+		j.info.Dropped++
+		return
+	}
+	j.results = append(j.results, result)
+}
+
+// Info returns the current state of the job.
+func (j *Job) Info() Info {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// Results returns the results recorded so far, in the order of processing.
+func (j *Job) Results() [][]byte {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.results[:len(j.results):len(j.results)]
+}
+
+func (j *Job) finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.info.Status = StatusDone
+	j.info.FinishedAt = &now
+	if err != nil {
+		j.info.Error = err.Error()
+	}
+}
+
+// Tracker runs jobs and keeps them until their retention delay has passed.
+type Tracker struct {
+	mu         sync.Mutex
+	jobs       map[string]*Job
+	retention  func() time.Duration
+	maxResults func() int
+}
+
+// NewTracker returns a tracker; the retention delay of finished jobs is read on each purge,
+// and the maximum number of results kept per job (0 for no maximum) when a job starts,
+// so that they follow configuration changes.
+func NewTracker(retention func() time.Duration, maxResults func() int) *Tracker {
+	return &Tracker{
+		jobs:       make(map[string]*Job),
+		retention:  retention,
+		maxResults: maxResults,
+	}
+}
+
+// Start runs a job of a given kind in the background; total is the number of items to process, if known.
+// The function returns an error if the job fails as a whole.
+func (t *Tracker) Start(kind string, total int, run func(ctx context.Context, j *Job) error) *Job {
+	j := &Job{info: Info{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Status:    StatusRunning,
+		Total:     total,
+		CreatedAt: time.Now(),
+	}, maxResults: t.maxResults()}
+
+	t.mu.Lock()
+	t.purge()
+	t.jobs[j.info.ID] = j
+	t.mu.Unlock()
+
+	go func() {
+		j.finish(run(context.Background(), j))
+	}()
+	return j
+}
+
+// Get returns a job, if it is still tracked.
+func (t *Tracker) Get(id string) (*Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.purge()
+	j, ok := t.jobs[id]
+	return j, ok
+}
+
+// purge forgets the jobs finished since longer than the retention delay; the caller holds the lock.
+func (t *Tracker) purge() {
+	retention := t.retention()
+	for id, j := range t.jobs {
+		info := j.Info()
+		if info.FinishedAt != nil && time.Since(*info.FinishedAt) > retention {
+			delete(t.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func waitDone(t *testing.T, j *Job) Info {
+	for i := 0; i < 100; i++ {
+		if info := j.Info(); info.Status == StatusDone {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job not finished")
+	return Info{}
+}
+
+func TestTracker(t *testing.T) {
+
+	retention := time.Hour
+	tr := NewTracker(func() time.Duration { return retention }, func() int { return 0 })
+
+	j := tr.Start("test", 3, func(ctx context.Context, j *Job) error {
+		j.Record(true, []byte(`{"index":0}`))
+		j.Record(false, []byte(`{"index":1}`))
+		j.Record(true, []byte(`{"index":2}`))
+		return nil
+	})
+	info := waitDone(t, j)
+	if info.Kind != "test" || info.Total != 3 || info.Processed != 3 || info.Succeeded != 2 || info.Failed != 1 {
+		t.Errorf("unexpected job info %+v", info)
+	}
+	if len(j.Results()) != 3 {
+		t.Errorf("expected 3 results, got %d", len(j.Results()))
+	}
+	if got, ok := tr.Get(info.ID); !ok || got != j {
+		t.Error("job not found")
+	}
+
+	// a failed job
+	failed := tr.Start("test", 0, func(ctx context.Context, j *Job) error {
+		return errors.New("boom")
+	})
+	if info := waitDone(t, failed); info.Error != "boom" {
+		t.Errorf("expected an error, got %q", info.Error)
+	}
+
+	// finished jobs are forgotten after the retention delay
+	retention = 0
+	time.Sleep(time.Millisecond)
+	if _, ok := tr.Get(info.ID); ok {
+		t.Error("job should have been purged")
+	}
+}
+
+func TestMaxResults(t *testing.T) {
+
+	tr := NewTracker(func() time.Duration { return time.Hour }, func() int { return 2 })
+
+	j := tr.Start("test", 3, func(ctx context.Context, j *Job) error {
+		j.Record(true, []byte(`{"index":0}`))
+		j.Record(true, []byte(`{"index":1}`))
+		j.Record(false, []byte(`{"index":2}`))
+		return nil
+	})
+	info := waitDone(t, j)
+	if info.Processed != 3 || info.Failed != 1 || info.Dropped != 1 {
+		t.Errorf("unexpected job info %+v", info)
+	}
+	if results := j.Results(); len(results) != 2 || string(results[1]) != `{"index":1}` {
+		t.Errorf("unexpected results %q", results)
+	}
+}
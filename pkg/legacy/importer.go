@@ -0,0 +1,454 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package legacy
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Import steps, in order of execution
+const (
+	StepPublications = "publications"
+	StepLicenses     = "licenses"
+	StepEvents       = "events"
+)
+
+// DefaultBatchSize is the default number of rows imported per transaction
+const DefaultBatchSize = 500
+
+// maxSample is the maximum number of identifiers or problems listed per step in a report
+const maxSample = 100
+
+// Options are the options of an import.
+type Options struct {
+	ContentURL string // base url of the contents whose location is a file name
+	BatchSize  int
+	Restart    bool // ignore the checkpoints of a previous import
+}
+
+// Checkpoint records the last key imported by a step, so that an interrupted import can be resumed.
+// It is saved in the target database, in the transaction of each batch.
+type Checkpoint struct {
+	Step      string `gorm:"primaryKey;type:varchar(20)"`
+	LastKey   string `gorm:"type:varchar(255)"`
+	UpdatedAt time.Time
+}
+
+func (Checkpoint) TableName() string { return "import_v1_checkpoints" }
+
+// Report is the reconciliation report of an import.
+type Report struct {
+	Publications StepReport             `json:"publications"`
+	Licenses     StepReport             `json:"licenses"`
+	Events       StepReport             `json:"events"`
+	Statuses     map[string]StatusCount `json:"statuses"`
+}
+
+// StepReport counts the rows processed by a step. Read, Imported, Existing and Skipped relate to this run;
+// Source and Target relate to the whole databases.
+type StepReport struct {
+	Source   int64    `json:"source"`             // rows of the v1 table
+	Target   int64    `json:"target"`             // rows of the v1 table found in the target database; all events for events
+	Read     int      `json:"read"`               // rows read by this run
+	Imported int      `json:"imported"`           // rows created by this run
+	Existing int      `json:"existing"`           // rows already present in the target database
+	Skipped  int      `json:"skipped"`            // rows which cannot be imported, see problems
+	Missing  []string `json:"missing,omitempty"`  // sample of the identifiers missing from the target database
+	Problems []string `json:"problems,omitempty"` // sample of the problems
+}
+
+// StatusCount compares the number of licenses per status; target counts include the licenses created by the server.
+type StatusCount struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+}
+
+func (r *StepReport) problem(format string, args ...interface{}) {
+	if len(r.Problems) < maxSample {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// Importer imports a v1 deployment into the target database.
+// The LCP and LSD databases may be the same database.
+type Importer struct {
+	lcp, lsd, target *gorm.DB
+	opts             Options
+	report           *Report
+}
+
+// NewImporter returns an importer; the target database must have been migrated.
+func NewImporter(lcp, lsd, target *gorm.DB, opts Options) *Importer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Importer{lcp: lcp, lsd: lsd, target: target, opts: opts}
+}
+
+// Run imports publications, licenses and events, resuming from the checkpoints of a previous run,
+// then reconciles the source and target databases.
+func (im *Importer) Run() (*Report, error) {
+
+	im.report = &Report{Statuses: make(map[string]StatusCount)}
+	if err := im.target.AutoMigrate(&Checkpoint{}); err != nil {
+		return nil, err
+	}
+	if im.opts.Restart {
+		if err := im.target.Where("1 = 1").Delete(&Checkpoint{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := im.importPublications(); err != nil {
+		return im.report, fmt.Errorf("%s: %w", StepPublications, err)
+	}
+	if err := im.importLicenses(); err != nil {
+		return im.report, fmt.Errorf("%s: %w", StepLicenses, err)
+	}
+	if err := im.importEvents(); err != nil {
+		return im.report, fmt.Errorf("%s: %w", StepEvents, err)
+	}
+	if err := im.reconcile(); err != nil {
+		return im.report, fmt.Errorf("reconciliation: %w", err)
+	}
+	return im.report, nil
+}
+
+// checkpoint returns the last key imported by a step, or an empty string.
+func (im *Importer) checkpoint(step string) (string, error) {
+	var cp Checkpoint
+	err := im.target.Where("step = ?", step).Limit(1).Find(&cp).Error
+	return cp.LastKey, err
+}
+
+func saveCheckpoint(tx *gorm.DB, step, lastKey string) error {
+	return tx.Save(&Checkpoint{Step: step, LastKey: lastKey}).Error
+}
+
+func (im *Importer) importPublications() error {
+
+	rep := &im.report.Publications
+	last, err := im.checkpoint(StepPublications)
+	if err != nil {
+		return err
+	}
+	for {
+		var rows []v1Content
+		q := im.lcp.Order("id").Limit(im.opts.BatchSize)
+		if last != "" {
+			q = q.Where("id > ?", last)
+		}
+		if err := q.Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		rep.Read += len(rows)
+
+		pubs := make([]stor.Publication, 0, len(rows))
+		for i := range rows {
+			pub := rows[i].publication(im.opts.ContentURL)
+			if u, err := url.Parse(pub.Href); err != nil || u.Scheme == "" {
+				// imported anyway, the href can be fixed by an update of the publication
+				rep.problem("content %s: the location %q is not a URL, set the content url", pub.UUID, pub.Href)
+			}
+			pubs = append(pubs, *pub)
+		}
+		last = rows[len(rows)-1].ID
+
+		var created int
+		err := im.target.Transaction(func(tx *gorm.DB) error {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&pubs)
+			if res.Error != nil {
+				return res.Error
+			}
+			created = int(res.RowsAffected)
+			return saveCheckpoint(tx, StepPublications, last)
+		})
+		if err != nil {
+			return err
+		}
+		rep.Imported += created
+		rep.Existing += len(pubs) - created
+		log.Infof("Import v1: %d publications read, %d imported", rep.Read, rep.Imported)
+	}
+}
+
+func (im *Importer) importLicenses() error {
+
+	rep := &im.report.Licenses
+	last, err := im.checkpoint(StepLicenses)
+	if err != nil {
+		return err
+	}
+	for {
+		var rows []v1License
+		q := im.lcp.Order("id").Limit(im.opts.BatchSize)
+		if last != "" {
+			q = q.Where("id > ?", last)
+		}
+		if err := q.Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		rep.Read += len(rows)
+
+		ids := make([]string, len(rows))
+		contentIDs := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+			contentIDs[i] = row.ContentFk
+		}
+		// status documents of the licenses; the latest one is retained
+		var statusRows []v1LicenseStatus
+		if err := im.lsd.Where("license_ref IN ?", ids).Order("id").Find(&statusRows).Error; err != nil {
+			return err
+		}
+		statuses := make(map[string]*v1LicenseStatus, len(statusRows))
+		for i := range statusRows {
+			statuses[statusRows[i].LicenseRef] = &statusRows[i]
+		}
+		// licenses refer to publications, which must have been imported
+		var found []string
+		if err := im.target.Unscoped().Model(&stor.Publication{}).Where("uuid IN ?", contentIDs).Pluck("uuid", &found).Error; err != nil {
+			return err
+		}
+		pubs := make(map[string]bool, len(found))
+		for _, id := range found {
+			pubs[id] = true
+		}
+
+		licenses := make([]stor.LicenseInfo, 0, len(rows))
+		for i := range rows {
+			if !pubs[rows[i].ContentFk] {
+				rep.Skipped++
+				rep.problem("license %s: unknown content %s", rows[i].ID, rows[i].ContentFk)
+				continue
+			}
+			info, ok := rows[i].licenseInfo(statuses[rows[i].ID])
+			if !ok {
+				rep.Skipped++
+				rep.problem("license %s: unknown status", rows[i].ID)
+				continue
+			}
+			licenses = append(licenses, *info)
+		}
+		last = rows[len(rows)-1].ID
+
+		var created int
+		err := im.target.Transaction(func(tx *gorm.DB) error {
+			if len(licenses) > 0 {
+				res := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(&licenses)
+				if res.Error != nil {
+					return res.Error
+				}
+				created = int(res.RowsAffected)
+			}
+			return saveCheckpoint(tx, StepLicenses, last)
+		})
+		if err != nil {
+			return err
+		}
+		rep.Imported += created
+		rep.Existing += len(licenses) - created
+		log.Infof("Import v1: %d licenses read, %d imported", rep.Read, rep.Imported)
+	}
+}
+
+// importEvents imports the events of the imported licenses.
+// Events have no identifier in the target database: an event is considered present
+// if the license has an event of the same type, device and timestamp.
+func (im *Importer) importEvents() error {
+
+	rep := &im.report.Events
+	last, err := im.checkpoint(StepEvents)
+	if err != nil {
+		return err
+	}
+	lastID, _ := strconv.Atoi(last)
+	for {
+		var rows []v1Event
+		if err := im.lsd.Where("id > ?", lastID).Order("id").Limit(im.opts.BatchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		rep.Read += len(rows)
+
+		// events refer to license statuses, which refer to licenses
+		fks := make([]int, len(rows))
+		for i, row := range rows {
+			fks[i] = row.LicenseStatusFk
+		}
+		var statusRows []v1LicenseStatus
+		if err := im.lsd.Where("id IN ?", fks).Find(&statusRows).Error; err != nil {
+			return err
+		}
+		licenseRefs := make(map[int]string, len(statusRows))
+		refs := make([]string, 0, len(statusRows))
+		for _, ls := range statusRows {
+			licenseRefs[ls.ID] = ls.LicenseRef
+			refs = append(refs, ls.LicenseRef)
+		}
+		var found []string
+		if err := im.target.Model(&stor.LicenseInfo{}).Where("uuid IN ?", refs).Pluck("uuid", &found).Error; err != nil {
+			return err
+		}
+		licenses := make(map[string]bool, len(found))
+		for _, id := range found {
+			licenses[id] = true
+		}
+
+		// events imported by a restarted run are already present
+		var existing []stor.Event
+		if err := im.target.Where("license_id IN ?", found).Find(&existing).Error; err != nil {
+			return err
+		}
+		present := make(map[string]bool, len(existing))
+		for i := range existing {
+			present[eventKey(&existing[i])] = true
+		}
+
+		events := make([]stor.Event, 0, len(rows))
+		for i := range rows {
+			licenseID := licenseRefs[rows[i].LicenseStatusFk]
+			if !licenses[licenseID] {
+				rep.Skipped++
+				rep.problem("event %d: unknown license status %d", rows[i].ID, rows[i].LicenseStatusFk)
+				continue
+			}
+			event, ok := rows[i].event(licenseID)
+			if !ok {
+				rep.Skipped++
+				rep.problem("event %d: unsupported type %d", rows[i].ID, rows[i].Type)
+				continue
+			}
+			if present[eventKey(event)] {
+				rep.Existing++
+				continue
+			}
+			events = append(events, *event)
+		}
+		lastID = rows[len(rows)-1].ID
+
+		err := im.target.Transaction(func(tx *gorm.DB) error {
+			if len(events) > 0 {
+				if err := tx.Omit(clause.Associations).Create(&events).Error; err != nil {
+					return err
+				}
+			}
+			return saveCheckpoint(tx, StepEvents, strconv.Itoa(lastID))
+		})
+		if err != nil {
+			return err
+		}
+		rep.Imported += len(events)
+		log.Infof("Import v1: %d events read, %d imported", rep.Read, rep.Imported)
+	}
+}
+
+// eventKey identifies an event of a license
+func eventKey(e *stor.Event) string {
+	return fmt.Sprintf("%s|%s|%s|%d", e.LicenseID, e.Type, e.DeviceID, e.Timestamp.UnixMilli())
+}
+
+// reconcile counts the source rows found in the target database, and compares the licenses per status.
+func (im *Importer) reconcile() error {
+
+	var err error
+	rep := im.report
+	if rep.Publications.Source, rep.Publications.Target, rep.Publications.Missing, err = im.reconcileKeys(&v1Content{}, &stor.Publication{}); err != nil {
+		return err
+	}
+	if rep.Licenses.Source, rep.Licenses.Target, rep.Licenses.Missing, err = im.reconcileKeys(&v1License{}, &stor.LicenseInfo{}); err != nil {
+		return err
+	}
+	if err = im.lsd.Model(&v1Event{}).Count(&rep.Events.Source).Error; err != nil {
+		return err
+	}
+	if err = im.target.Model(&stor.Event{}).Count(&rep.Events.Target).Error; err != nil {
+		return err
+	}
+
+	var source []struct {
+		Status int64
+		Count  int64
+	}
+	if err = im.lsd.Model(&v1LicenseStatus{}).Select("status, count(*) as count").Group("status").Scan(&source).Error; err != nil {
+		return err
+	}
+	for _, s := range source {
+		name, ok := v1Statuses[s.Status]
+		if !ok {
+			name = "unknown:" + strconv.FormatInt(s.Status, 10)
+		}
+		c := rep.Statuses[name]
+		c.Source += s.Count
+		rep.Statuses[name] = c
+	}
+	var target []struct {
+		Status string
+		Count  int64
+	}
+	if err = im.target.Model(&stor.LicenseInfo{}).Select("status, count(*) as count").Group("status").Scan(&target).Error; err != nil {
+		return err
+	}
+	for _, t := range target {
+		c := rep.Statuses[t.Status]
+		c.Target += t.Count
+		rep.Statuses[t.Status] = c
+	}
+	return nil
+}
+
+// reconcileKeys counts the rows of a v1 table and those found in the target table, by identifier,
+// and returns a sample of the missing identifiers.
+func (im *Importer) reconcileKeys(source, target interface{}) (total, found int64, missing []string, err error) {
+
+	last := ""
+	for {
+		var ids []string
+		q := im.lcp.Model(source).Order("id").Limit(im.opts.BatchSize)
+		if last != "" {
+			q = q.Where("id > ?", last)
+		}
+		if err = q.Pluck("id", &ids).Error; err != nil {
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+		total += int64(len(ids))
+		last = ids[len(ids)-1]
+
+		var present []string
+		if err = im.target.Unscoped().Model(target).Where("uuid IN ?", ids).Pluck("uuid", &present).Error; err != nil {
+			return
+		}
+		found += int64(len(present))
+		if len(present) < len(ids) && len(missing) < maxSample {
+			set := make(map[string]bool, len(present))
+			for _, id := range present {
+				set[id] = true
+			}
+			for _, id := range ids {
+				if !set[id] && len(missing) < maxSample {
+					missing = append(missing, id)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package legacy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"github.com/readium/readium-lcp-server/status"
+	"gorm.io/gorm"
+)
+
+// v1 table definitions, as created by readium-lcp-server v1
+var v1LCPSchema = []string{
+	`CREATE TABLE content (id varchar(255) PRIMARY KEY, encryption_key varchar(64) NOT NULL, location text NOT NULL,
+	length bigint, sha256 varchar(64), "type" varchar(255) NOT NULL default 'application/epub+zip')`,
+	`CREATE TABLE license (id varchar(255) PRIMARY KEY, user_id varchar(255) NOT NULL, provider varchar(255) NOT NULL,
+	issued datetime NOT NULL, updated datetime DEFAULT NULL, rights_print int(11) DEFAULT NULL, rights_copy int(11) DEFAULT NULL,
+	rights_start datetime DEFAULT NULL, rights_end datetime DEFAULT NULL, content_fk varchar(255) NOT NULL,
+	lsd_status integer default 0, FOREIGN KEY(content_fk) REFERENCES content(id))`,
+}
+
+var v1LSDSchema = []string{
+	`CREATE TABLE license_status (id INTEGER PRIMARY KEY, status int(11) NOT NULL, license_updated datetime NOT NULL,
+	status_updated datetime NOT NULL, device_count int(11) DEFAULT NULL, potential_rights_end datetime DEFAULT NULL,
+	license_ref varchar(255) NOT NULL, rights_end datetime DEFAULT NULL)`,
+	`CREATE TABLE event (id integer PRIMARY KEY, device_name varchar(255) DEFAULT NULL, timestamp datetime NOT NULL,
+	type int NOT NULL, device_id varchar(255) DEFAULT NULL, license_status_fk int NOT NULL)`,
+}
+
+const (
+	content1 = "6f8a0e4c-1b2d-4c3e-9f5a-7b8c9d0e1f2a"
+	content2 = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+	license1 = "11111111-2222-4333-8444-555555555555"
+	license2 = "22222222-3333-4444-8555-666666666666"
+	license3 = "33333333-4444-4555-8666-777777777777"
+	license4 = "44444444-5555-4666-8777-888888888888"
+)
+
+func v1Database(t *testing.T, path string, schema []string, rows []string) *gorm.DB {
+	db, err := stor.Connect("sqlite3://file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range append(schema, rows...) {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	return db
+}
+
+func TestImport(t *testing.T) {
+
+	dir := t.TempDir()
+	lcp := v1Database(t, filepath.Join(dir, "lcp.sqlite"), v1LCPSchema, []string{
+		`INSERT INTO content VALUES ('` + content1 + `', x'00112233445566778899aabbccddeeff', 'https://cdn.example.com/book1.epub', 1024, 'abcd', 'application/epub+zip')`,
+		`INSERT INTO content VALUES ('` + content2 + `', x'00112233445566778899aabbccddeeff', 'book2.pdf', 2048, 'efgh', 'application/pdf+lcp')`,
+		`INSERT INTO license VALUES ('` + license1 + `', 'user1', 'https://provider.example.com', '2020-01-01 10:00:00', NULL, 10, 2000, NULL, '2020-02-01 10:00:00', '` + content1 + `', 201)`,
+		`INSERT INTO license VALUES ('` + license2 + `', 'user2', 'https://provider.example.com', '2020-01-02 10:00:00', NULL, NULL, NULL, NULL, NULL, '` + content2 + `', 201)`,
+		`INSERT INTO license VALUES ('` + license3 + `', 'user3', 'https://provider.example.com', '2020-01-03 10:00:00', NULL, NULL, NULL, NULL, NULL, '` + content1 + `', 201)`,
+		`INSERT INTO license VALUES ('` + license4 + `', 'user4', 'https://provider.example.com', '2020-01-04 10:00:00', NULL, NULL, NULL, NULL, NULL, '` + content1 + `', -1)`,
+	})
+	lsd := v1Database(t, filepath.Join(dir, "lsd.sqlite"), v1LSDSchema, []string{
+		// status bit fields, as written by v1: active (2), renewed, with a device
+		`INSERT INTO license_status VALUES (1, 2, '2020-01-01 10:00:00', '2020-01-05 10:00:00', 1, '2020-06-01 10:00:00', '` + license1 + `', '2020-03-01 10:00:00')`,
+		// returned (8)
+		`INSERT INTO license_status VALUES (2, 8, '2020-01-02 10:00:00', '2020-01-06 10:00:00', 1, NULL, '` + license2 + `', NULL)`,
+		// invalid status, several bits set
+		`INSERT INTO license_status VALUES (3, 3, '2020-01-03 10:00:00', '2020-01-06 10:00:00', 0, NULL, '` + license3 + `', NULL)`,
+		// license4 has no status document, the notification of the status server failed (lsd_status -1)
+		`INSERT INTO event VALUES (1, 'phone', '2020-01-05 10:00:00', 1, 'device1', 1)`,
+		`INSERT INTO event VALUES (2, 'phone', '2020-01-06 10:00:00', 6, 'device1', 1)`,
+		`INSERT INTO event VALUES (3, 'tablet', '2020-01-06 10:00:00', 1, 'device2', 2)`,
+		`INSERT INTO event VALUES (4, 'tablet', '2020-01-07 10:00:00', 3, 'device2', 2)`,
+		`INSERT INTO event VALUES (5, NULL, '2020-01-08 10:00:00', 5, NULL, 2)`,
+		`INSERT INTO event VALUES (6, 'reader', '2020-01-08 10:00:00', 1, 'device3', 3)`,
+	})
+	target, err := stor.Open("sqlite3://file:" + filepath.Join(dir, "target.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// small batches, to go through several transactions
+	im := NewImporter(lcp, lsd, target, Options{ContentURL: "https://storage.example.com/files/", BatchSize: 2})
+	report, err := im.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := report.Publications; r.Imported != 2 || r.Source != 2 || r.Target != 2 {
+		t.Errorf("unexpected publication report %+v", r)
+	}
+	if r := report.Licenses; r.Imported != 3 || r.Skipped != 1 || r.Source != 4 || r.Target != 3 || len(r.Missing) != 1 || r.Missing[0] != license3 {
+		t.Errorf("unexpected license report %+v", r)
+	}
+	// the event of the license with an invalid status and the expiration event are skipped
+	if r := report.Events; r.Imported != 4 || r.Skipped != 2 || r.Source != 6 || r.Target != 4 {
+		t.Errorf("unexpected event report %+v", r)
+	}
+	if c := report.Statuses[stor.STATUS_ACTIVE]; c.Source != 1 || c.Target != 1 {
+		t.Errorf("unexpected active count %+v", c)
+	}
+
+	// identifiers, hrefs and statuses are preserved
+	var pub stor.Publication
+	target.Where("uuid = ?", content2).First(&pub)
+	if pub.Href != "https://storage.example.com/files/"+content2 || pub.Size != 2048 || pub.Version != 1 {
+		t.Errorf("unexpected publication %+v", pub)
+	}
+	var info stor.LicenseInfo
+	target.Where("uuid = ?", license1).First(&info)
+	if info.Status != stor.STATUS_ACTIVE || info.DeviceCount != 1 || info.Print != 10 || info.End == nil || info.End.Month() != 3 || info.MaxEnd == nil {
+		t.Errorf("unexpected license %+v", info)
+	}
+	var returned, ready stor.LicenseInfo
+	target.Where("uuid = ?", license2).First(&returned)
+	if returned.Status != stor.STATUS_RETURNED {
+		t.Errorf("expected a returned license, got %s", returned.Status)
+	}
+	target.Where("uuid = ?", license4).First(&ready)
+	if ready.Status != stor.STATUS_READY {
+		t.Errorf("expected a ready license, got %s", ready.Status)
+	}
+	var events []stor.Event
+	target.Where("license_id = ?", license1).Order("id").Find(&events)
+	if len(events) != 2 || events[0].Type != stor.EVENT_REGISTER || events[1].Type != stor.EVENT_RENEW || events[0].DeviceID != "device1" {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	// a second run resumes after the checkpoints: nothing is read again
+	report, err = NewImporter(lcp, lsd, target, Options{BatchSize: 2}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Publications.Read != 0 || report.Licenses.Read != 0 || report.Events.Read != 0 || report.Events.Target != 4 {
+		t.Errorf("unexpected report of a resumed import %+v", report)
+	}
+
+	// a restarted run finds existing rows, but duplicates no event
+	report, err = NewImporter(lcp, lsd, target, Options{BatchSize: 2, Restart: true}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Licenses.Existing != 3 || report.Licenses.Imported != 0 {
+		t.Errorf("unexpected license report of a restarted import %+v", report.Licenses)
+	}
+	if report.Events.Existing != 4 || report.Events.Imported != 0 || report.Events.Target != 4 {
+		t.Errorf("unexpected event report of a restarted import %+v", report.Events)
+	}
+}
+
+// TestV1Statuses checks the status encoding against the one of readium-lcp-server v1.
+func TestV1Statuses(t *testing.T) {
+
+	if len(v1Statuses) != len(status.StatusValues) {
+		t.Fatalf("expected %d statuses, got %d", len(status.StatusValues), len(v1Statuses))
+	}
+	for _, name := range status.StatusValues {
+		value, err := status.SetStatus(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v1Statuses[value] != name {
+			t.Errorf("v1 status %d is %s, mapped to %q", value, name, v1Statuses[value])
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The legacy package imports the data of a readium-lcp-server v1 deployment:
+// the content and license tables of the LCP database, the license_status and event tables of the LSD database.
+package legacy
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+// v1Content is a row of the v1 content table (LCP database).
+type v1Content struct {
+	ID            string
+	EncryptionKey []byte
+	Location      string
+	Length        *int64
+	Sha256        *string
+	Type          string
+}
+
+func (v1Content) TableName() string { return "content" }
+
+// v1License is a row of the v1 license table (LCP database).
+type v1License struct {
+	ID          string
+	UserID      string
+	Provider    string
+	Issued      time.Time
+	Updated     *time.Time
+	RightsPrint *int32
+	RightsCopy  *int32
+	RightsStart *time.Time
+	RightsEnd   *time.Time
+	ContentFk   string
+}
+
+func (v1License) TableName() string { return "license" }
+
+// v1LicenseStatus is a row of the v1 license_status table (LSD database).
+type v1LicenseStatus struct {
+	ID                 int
+	Status             int64
+	LicenseUpdated     time.Time
+	StatusUpdated      time.Time
+	DeviceCount        *int
+	PotentialRightsEnd *time.Time
+	LicenseRef         string
+	RightsEnd          *time.Time
+}
+
+func (v1LicenseStatus) TableName() string { return "license_status" }
+
+// v1Event is a row of the v1 event table (LSD database).
+type v1Event struct {
+	ID              int
+	DeviceName      *string
+	Timestamp       time.Time
+	Type            int
+	DeviceID        *string
+	LicenseStatusFk int
+}
+
+func (v1Event) TableName() string { return "event" }
+
+// v1 statuses are stored as bit fields, the position of the bit being the index of the status
+// (see status.SetStatus in readium-lcp-server v1): ready is 1, active 2, revoked 4, etc.
+var v1Statuses = map[int64]string{
+	1 << 0: stor.STATUS_READY,
+	1 << 1: stor.STATUS_ACTIVE,
+	1 << 2: stor.STATUS_REVOKED,
+	1 << 3: stor.STATUS_RETURNED,
+	1 << 4: stor.STATUS_CANCELLED,
+	1 << 5: stor.STATUS_EXPIRED,
+}
+
+// v1 event types; v1 also records the expiration of licenses (5), which has no equivalent
+var v1EventTypes = map[int]string{
+	1: stor.EVENT_REGISTER,
+	2: stor.EVENT_REVOKE,
+	3: stor.EVENT_RETURN,
+	4: stor.EVENT_CANCEL,
+	6: stor.EVENT_RENEW,
+}
+
+// publication maps a v1 content to a publication.
+// The href is the location of the content if it is a URL, else the content url followed by the content id,
+// as the v1 file system storage serves the content.
+func (c *v1Content) publication(contentURL string) *stor.Publication {
+	pub := &stor.Publication{
+		UUID:          c.ID,
+		ContentType:   c.Type,
+		Title:         path.Base(c.Location),
+		EncryptionKey: c.EncryptionKey,
+		Href:          c.Location,
+		Version:       1,
+	}
+	if u, err := url.Parse(c.Location); err != nil || u.Scheme == "" {
+		if contentURL != "" {
+			pub.Href = strings.TrimSuffix(contentURL, "/") + "/" + c.ID
+		}
+	}
+	if c.Length != nil {
+		pub.Size = uint32(*c.Length)
+	}
+	if c.Sha256 != nil {
+		pub.Checksum = *c.Sha256
+	}
+	return pub
+}
+
+// licenseInfo maps a v1 license and its status to license info.
+// A license without status document is ready: the lsd_status column of the license table
+// only records the http status of the notification of the status server, not a license status.
+func (l *v1License) licenseInfo(ls *v1LicenseStatus) (*stor.LicenseInfo, bool) {
+	info := &stor.LicenseInfo{
+		UUID:          l.ID,
+		Provider:      l.Provider,
+		UserID:        l.UserID,
+		Start:         l.RightsStart,
+		End:           l.RightsEnd,
+		Updated:       l.Updated,
+		PublicationID: l.ContentFk,
+		Status:        stor.STATUS_READY,
+	}
+	info.CreatedAt = l.Issued
+	if l.RightsPrint != nil {
+		info.Print = *l.RightsPrint
+	}
+	if l.RightsCopy != nil {
+		info.Copy = *l.RightsCopy
+	}
+	if ls == nil {
+		return info, true
+	}
+	status, ok := v1Statuses[ls.Status]
+	info.Status = status
+	statusUpdated := ls.StatusUpdated
+	info.StatusUpdated = &statusUpdated
+	info.MaxEnd = ls.PotentialRightsEnd
+	if ls.DeviceCount != nil {
+		info.DeviceCount = *ls.DeviceCount
+	}
+	// the end date is updated by the status server on renew
+	if ls.RightsEnd != nil {
+		info.End = ls.RightsEnd
+	}
+	return info, ok
+}
+
+// event maps a v1 event to an event of a license.
+func (e *v1Event) event(licenseID string) (*stor.Event, bool) {
+	eventType, ok := v1EventTypes[e.Type]
+	if !ok {
+		return nil, false
+	}
+	event := &stor.Event{
+		Timestamp: e.Timestamp,
+		Type:      eventType,
+		LicenseID: licenseID,
+	}
+	if e.DeviceName != nil {
+		event.DeviceName = *e.DeviceName
+	}
+	if e.DeviceID != nil {
+		event.DeviceID = *e.DeviceID
+	}
+	return event, true
+}
@@ -0,0 +1,160 @@
+// Copyright 2025 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package lic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Custom properties can be added to the license, to its user and rights objects.
+// Each one is identified by an absolute URI, so that it can't collide
+// with the properties defined by the LCP specification.
+
+// standard properties of the objects which can be extended
+var (
+	licenseStdFields = map[string]bool{"provider": true, "id": true, "issued": true, "updated": true, "encryption": true, "links": true, "user": true, "rights": true, "signature": true}
+	userStdFields    = map[string]bool{"id": true, "email": true, "name": true, "encrypted": true}
+	rightsStdFields  = map[string]bool{"start": true, "end": true, "print": true, "copy": true}
+)
+
+// ValidateLicenseExtensions checks custom properties added at the root of a license.
+func ValidateLicenseExtensions(extensions map[string]interface{}) error {
+	return validateExtensions("license", extensions, licenseStdFields)
+}
+
+// ValidateUserExtensions checks custom user properties.
+func ValidateUserExtensions(extensions map[string]interface{}) error {
+	return validateExtensions("user", extensions, userStdFields)
+}
+
+// ValidateRightsExtensions checks custom rights.
+func ValidateRightsExtensions(extensions map[string]interface{}) error {
+	return validateExtensions("rights", extensions, rightsStdFields)
+}
+
+func validateExtensions(object string, extensions map[string]interface{}, stdFields map[string]bool) error {
+
+	for key := range extensions {
+		if stdFields[key] {
+			return fmt.Errorf("%s property %s is a standard property", object, key)
+		}
+		u, err := url.Parse(key)
+		if err != nil || !u.IsAbs() {
+			return fmt.Errorf("%s property %s must be identified by an absolute URI", object, key)
+		}
+	}
+	return nil
+}
+
+// marshalExtensions marshals a structure and adds custom properties to the resulting json object.
+func marshalExtensions(std interface{}, extensions map[string]interface{}, stdFields map[string]bool) ([]byte, error) {
+
+	b, err := json.Marshal(std)
+	if err != nil || len(extensions) == 0 {
+		return b, err
+	}
+	props := make(map[string]interface{})
+	if err := json.Unmarshal(b, &props); err != nil {
+		return nil, err
+	}
+	for key, value := range extensions {
+		if !stdFields[key] {
+			props[key] = value
+		}
+	}
+	return json.Marshal(props)
+}
+
+// unmarshalExtensions returns the properties of a json object which are not standard, nil if there is none.
+func unmarshalExtensions(data []byte, stdFields map[string]bool) (map[string]interface{}, error) {
+
+	var props map[string]interface{}
+	if err := json.Unmarshal(data, &props); err != nil {
+		return nil, err
+	}
+	var extensions map[string]interface{}
+	for key, value := range props {
+		if stdFields[key] {
+			continue
+		}
+		if extensions == nil {
+			extensions = make(map[string]interface{})
+		}
+		extensions[key] = value
+	}
+	return extensions, nil
+}
+
+// MarshalJSON emits license extensions alongside the standard properties,
+// so that the signature covers them.
+func (l License) MarshalJSON() ([]byte, error) {
+	type stdLicense License // prevents recursion
+	return marshalExtensions(stdLicense(l), l.Extensions, licenseStdFields)
+}
+
+// UnmarshalJSON collects the properties which are not standard as license extensions.
+func (l *License) UnmarshalJSON(data []byte) error {
+
+	type stdLicense License // prevents recursion
+	var std stdLicense
+	if err := json.Unmarshal(data, &std); err != nil {
+		return err
+	}
+	extensions, err := unmarshalExtensions(data, licenseStdFields)
+	if err != nil {
+		return err
+	}
+	std.Extensions = extensions
+	*l = License(std)
+	return nil
+}
+
+// MarshalJSON emits custom user properties alongside the standard ones.
+func (u UserInfo) MarshalJSON() ([]byte, error) {
+	type stdUserInfo UserInfo // prevents recursion
+	return marshalExtensions(stdUserInfo(u), u.Extensions, userStdFields)
+}
+
+// UnmarshalJSON collects the properties which are not standard as custom user properties.
+func (u *UserInfo) UnmarshalJSON(data []byte) error {
+
+	type stdUserInfo UserInfo // prevents recursion
+	var std stdUserInfo
+	if err := json.Unmarshal(data, &std); err != nil {
+		return err
+	}
+	extensions, err := unmarshalExtensions(data, userStdFields)
+	if err != nil {
+		return err
+	}
+	std.Extensions = extensions
+	*u = UserInfo(std)
+	return nil
+}
+
+// MarshalJSON emits custom rights alongside the standard ones.
+func (r UserRights) MarshalJSON() ([]byte, error) {
+	type stdUserRights UserRights // prevents recursion
+	return marshalExtensions(stdUserRights(r), r.Extensions, rightsStdFields)
+}
+
+// UnmarshalJSON collects the properties which are not standard as custom rights.
+func (r *UserRights) UnmarshalJSON(data []byte) error {
+
+	type stdUserRights UserRights // prevents recursion
+	var std stdUserRights
+	if err := json.Unmarshal(data, &std); err != nil {
+		return err
+	}
+	extensions, err := unmarshalExtensions(data, rightsStdFields)
+	if err != nil {
+		return err
+	}
+	std.Extensions = extensions
+	*r = UserRights(std)
+	return nil
+}
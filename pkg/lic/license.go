@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"math/big"
 	"net/url"
 	"reflect"
@@ -47,15 +48,16 @@ const (
 // note: a signature is nil when a license is canonicalized before being signed
 
 type License struct {
-	Provider   string          `json:"provider"`
-	UUID       string          `json:"id"`
-	Issued     time.Time       `json:"issued"`
-	Updated    *time.Time      `json:"updated,omitempty"`
-	Encryption Encryption      `json:"encryption"`
-	Links      []Link          `json:"links,omitempty"`
-	User       UserInfo        `json:"user"`
-	Rights     UserRights      `json:"rights"`
-	Signature  *sign.Signature `json:"signature,omitempty"`
+	Provider   string                 `json:"provider"`
+	UUID       string                 `json:"id"`
+	Issued     time.Time              `json:"issued"`
+	Updated    *time.Time             `json:"updated,omitempty"`
+	Encryption Encryption             `json:"encryption"`
+	Links      []Link                 `json:"links,omitempty"`
+	User       UserInfo               `json:"user"`
+	Rights     UserRights             `json:"rights"`
+	Signature  *sign.Signature        `json:"signature,omitempty"`
+	Extensions map[string]interface{} `json:"-"` // custom properties, keyed by a URI; see extensions.go
 }
 
 type Encryption struct { // Used for license generation
@@ -76,17 +78,19 @@ type Link struct {
 }
 
 type UserInfo struct { // Used for license generation
-	ID        string   `json:"id"`
-	Email     string   `json:"email,omitempty"`
-	Name      string   `json:"name,omitempty"`
-	Encrypted []string `json:"encrypted,omitempty"`
+	ID         string                 `json:"id"`
+	Email      string                 `json:"email,omitempty"`
+	Name       string                 `json:"name,omitempty"`
+	Encrypted  []string               `json:"encrypted,omitempty"`
+	Extensions map[string]interface{} `json:"-"` // custom properties, keyed by a URI; see extensions.go
 }
 
 type UserRights struct { // Used for license generation
-	Start *time.Time `json:"start,omitempty"`
-	End   *time.Time `json:"end,omitempty"`
-	Print *int32     `json:"print,omitempty"`
-	Copy  *int32     `json:"copy,omitempty"`
+	Start      *time.Time             `json:"start,omitempty"`
+	End        *time.Time             `json:"end,omitempty"`
+	Print      *int32                 `json:"print,omitempty"`
+	Copy       *int32                 `json:"copy,omitempty"`
+	Extensions map[string]interface{} `json:"-"` // custom rights, keyed by a URI; see extensions.go
 }
 
 type ContentKey struct {
@@ -129,7 +133,7 @@ func NewLicense(config *conf.Config, cert *tls.Certificate, pubInfo *stor.Public
 	}
 
 	//rights
-	err = setRights(l, licInfo)
+	err = setRights(l, licInfo, pubInfo)
 	if err != nil {
 		return nil, err
 	}
@@ -279,7 +283,22 @@ func encryptFields(encrypter crypto.Encrypter, userInfo *UserInfo, key []byte) e
 
 	for _, toEncrypt := range userInfo.Encrypted {
 		var out bytes.Buffer
+		// custom properties are encrypted like standard fields
+		if value, ok := userInfo.Extensions[toEncrypt]; ok {
+			str, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("user property %s is not a string, it can't be encrypted", toEncrypt)
+			}
+			if err := encrypter.Encrypt(key[:], bytes.NewBufferString(str), &out); err != nil {
+				return err
+			}
+			userInfo.Extensions[toEncrypt] = base64.StdEncoding.EncodeToString(out.Bytes())
+			continue
+		}
 		field := getField(userInfo, toEncrypt)
+		if !field.IsValid() || field.Kind() != reflect.String {
+			return fmt.Errorf("unknown user property %s, it can't be encrypted", toEncrypt)
+		}
 		err := encrypter.Encrypt(key[:], bytes.NewBufferString(field.String()), &out)
 		if err != nil {
 			return err
@@ -296,8 +315,9 @@ func getField(u *UserInfo, field string) reflect.Value {
 	return v.FieldByName(c.String(field))
 }
 
-// setRights sets the rights structure in the license
-func setRights(l *License, licInfo *stor.LicenseInfo) error {
+// setRights sets the rights structure in the license, and the license extensions.
+// Custom rights and extensions set on the license info override the defaults of the publication.
+func setRights(l *License, licInfo *stor.LicenseInfo, pubInfo *stor.Publication) error {
 
 	l.Rights.Start = licInfo.Start
 	l.Rights.End = licInfo.End
@@ -307,7 +327,29 @@ func setRights(l *License, licInfo *stor.LicenseInfo) error {
 	if licInfo.Copy != -1 {
 		l.Rights.Copy = &licInfo.Copy
 	}
-	return nil
+
+	l.Rights.Extensions = mergeExtensions(pubInfo.LicenseRights, licInfo.Rights)
+	if err := ValidateRightsExtensions(l.Rights.Extensions); err != nil {
+		return err
+	}
+	l.Extensions = mergeExtensions(pubInfo.LicenseExtensions, licInfo.Extensions)
+	return ValidateLicenseExtensions(l.Extensions)
+}
+
+// mergeExtensions returns the union of default and specific properties, nil if there is none.
+func mergeExtensions(defaults, specific map[string]interface{}) map[string]interface{} {
+
+	if len(defaults) == 0 && len(specific) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(defaults)+len(specific))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range specific {
+		merged[key] = value
+	}
+	return merged
 }
 
 // setSignature sets the signature of the license
@@ -7,6 +7,7 @@ package lic
 import (
 	"crypto/rand"
 	"crypto/tls"
+	"encoding/json"
 	"os"
 	"testing"
 	"time"
@@ -41,7 +42,7 @@ func setConfig() *conf.Config {
 
 func TestMain(m *testing.M) {
 
-	LicCt.Config = setConfig()
+	LicCt.Live = conf.NewLive(setConfig())
 
 	// Create / open an sqlite db in memory
 	dsn := "sqlite3://file::memory:?cache=shared"
@@ -85,7 +86,7 @@ func TestMain(m *testing.M) {
 func TestLicense(t *testing.T) {
 
 	// cert
-	cert, err := tls.LoadX509KeyPair(LicCt.Config.Certificate.Cert, LicCt.Config.Certificate.PrivateKey)
+	cert, err := tls.LoadX509KeyPair(LicCt.Config().Certificate.Cert, LicCt.Config().Certificate.PrivateKey)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -108,7 +109,7 @@ func TestLicense(t *testing.T) {
 
 	passhash := "FAEB00CA518BEA7CB11A7EF31FB6183B489B1B6EADB792BEC64A03B3F6FF80A8"
 
-	license, err := NewLicense(LicCt.Config, &cert, &Pub, &LicInfo, &userInfo, &encryption, passhash)
+	license, err := NewLicense(LicCt.Config(), &cert, &Pub, &LicInfo, &userInfo, &encryption, passhash)
 
 	if err != nil {
 		t.Log(err)
@@ -131,3 +132,125 @@ func TestLicense(t *testing.T) {
 	*/
 
 }
+
+func TestLicenseUserExtensions(t *testing.T) {
+
+	cert, err := tls.LoadX509KeyPair(LicCt.Config().Certificate.Cert, LicCt.Config().Certificate.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := "https://example.com/lcp#group"
+	school := "https://example.com/lcp#school"
+	userInfo := UserInfo{
+		ID:        uuid.New().String(),
+		Encrypted: []string{school},
+		Extensions: map[string]interface{}{
+			group:  "Grade 5",
+			school: "Lincoln",
+		},
+	}
+	if err := ValidateUserExtensions(userInfo.Extensions); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateUserExtensions(map[string]interface{}{"group": "Grade 5"}); err == nil {
+		t.Fatal("expected an error for a property not identified by a URI")
+	}
+
+	encryption := Encryption{
+		Profile: LCP_Basic_Profile,
+		UserKey: UserKey{
+			TextHint: "A textual hint for your passphrase.",
+		},
+	}
+	passhash := "FAEB00CA518BEA7CB11A7EF31FB6183B489B1B6EADB792BEC64A03B3F6FF80A8"
+
+	license, err := NewLicense(LicCt.Config(), &cert, &Pub, &LicInfo, &userInfo, &encryption, passhash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(license)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed License
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.User.ID != userInfo.ID {
+		t.Fatalf("expected user id %s, got %s", userInfo.ID, parsed.User.ID)
+	}
+	if parsed.User.Extensions[group] != "Grade 5" {
+		t.Fatalf("expected clear custom property, got %v", parsed.User.Extensions[group])
+	}
+	if value, _ := parsed.User.Extensions[school].(string); value == "" || value == "Lincoln" {
+		t.Fatalf("expected encrypted custom property, got %v", parsed.User.Extensions[school])
+	}
+
+	// only string properties can be encrypted
+	userInfo = UserInfo{
+		ID:         uuid.New().String(),
+		Encrypted:  []string{group},
+		Extensions: map[string]interface{}{group: 5},
+	}
+	if _, err := NewLicense(LicCt.Config(), &cert, &Pub, &LicInfo, &userInfo, &encryption, passhash); err == nil {
+		t.Fatal("expected an error when encrypting a property which is not a string")
+	}
+}
+
+func TestLicenseRightsExtensions(t *testing.T) {
+
+	cert, err := tls.LoadX509KeyPair(LicCt.Config().Certificate.Cert, LicCt.Config().Certificate.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tts := "https://example.com/lcp/rights#tts"
+	ext := "https://example.com/lcp#provider-data"
+	pub := Pub
+	pub.LicenseRights = map[string]interface{}{tts: false}
+	pub.LicenseExtensions = map[string]interface{}{ext: "default"}
+	licInfo := LicInfo
+	licInfo.Rights = map[string]interface{}{tts: true}
+
+	userInfo := UserInfo{ID: uuid.New().String()}
+	encryption := Encryption{
+		Profile: LCP_Basic_Profile,
+		UserKey: UserKey{
+			TextHint: "A textual hint for your passphrase.",
+		},
+	}
+	passhash := "FAEB00CA518BEA7CB11A7EF31FB6183B489B1B6EADB792BEC64A03B3F6FF80A8"
+
+	license, err := NewLicense(LicCt.Config(), &cert, &pub, &licInfo, &userInfo, &encryption, passhash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(license)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed License
+	if err := json.Unmarshal(b, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Rights.Extensions[tts] != true {
+		t.Fatalf("expected the right of the license to override the default, got %v", parsed.Rights.Extensions[tts])
+	}
+	if parsed.Extensions[ext] != "default" {
+		t.Fatalf("expected the extension of the publication, got %v", parsed.Extensions[ext])
+	}
+	if parsed.Signature == nil {
+		t.Fatal("expected a signature")
+	}
+
+	// standard fields can't be overridden
+	if err := ValidateRightsExtensions(map[string]interface{}{"print": 10}); err == nil {
+		t.Fatal("expected an error for a standard right")
+	}
+	if err := ValidateLicenseExtensions(map[string]interface{}{"id": "x"}); err == nil {
+		t.Fatal("expected an error for a standard license property")
+	}
+}
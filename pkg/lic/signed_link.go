@@ -0,0 +1,76 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package lic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrLinkExpired   = errors.New("the link has expired")
+	ErrLinkSignature = errors.New("invalid signature of the link")
+)
+
+// LinkExpiry returns the expiration date of the signed links issued at a given time.
+// Links are issued per window of the validity period and expire at the end of the next window,
+// so that a link stays the same during a window, whatever the number of status documents served,
+// and is valid for at least the validity period.
+func LinkExpiry(validity time.Duration, now time.Time) time.Time {
+	return now.Truncate(validity).Add(2 * validity)
+}
+
+// SignedFreshLink returns the link to the fresh license route of the server for a license,
+// signed with the secret and valid until the expiration date.
+func SignedFreshLink(publicBaseUrl, secret, licenseID string, expires time.Time) string {
+
+	href, err := url.JoinPath(publicBaseUrl, "fresh", licenseID)
+	if err != nil {
+		href = publicBaseUrl + "/fresh/" + licenseID // fallback
+	}
+	return SignLink(href, secret, licenseID, expires)
+}
+
+// CheckFreshLink verifies the expiration date and the signature of a fresh license link.
+func CheckFreshLink(secret, licenseID, expires, signature string, now time.Time) error {
+	return CheckLink(secret, licenseID, expires, signature, now)
+}
+
+// SignLink adds to an href the expiration date of the link and a signature
+// of the resource it gives access to.
+func SignLink(href, secret, resource string, expires time.Time) string {
+
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return href + "?expires=" + exp + "&sig=" + linkSignature(secret, resource, exp)
+}
+
+// CheckLink verifies the expiration date and the signature of a link giving access to a resource.
+func CheckLink(secret, resource, expires, signature string, now time.Time) error {
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrLinkSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(linkSignature(secret, resource, expires))) {
+		return ErrLinkSignature
+	}
+	if now.After(time.Unix(exp, 0)) {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+// linkSignature returns the base64url encoded HMAC-SHA256 of a resource and an expiration date.
+func linkSignature(secret, resource, expires string) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource + "|" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -5,10 +5,14 @@
 package lic
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/edrlab/lcp-server/pkg/conf"
+	"github.com/edrlab/lcp-server/pkg/i18n"
 	"github.com/edrlab/lcp-server/pkg/stor"
 	"github.com/jtacoma/uritemplates"
 	log "github.com/sirupsen/logrus"
@@ -48,8 +52,10 @@ type (
 	}
 
 	LicenseCtrl struct {
-		*conf.Config // TODO: change for an interface (dependency)
+		*conf.Live // TODO: change for an interface (dependency)
 		stor.Store
+		Log     *log.Entry    // optional, request scoped logger
+		Printer *i18n.Printer // optional, localized messages
 	}
 
 	DeviceInfo struct {
@@ -58,10 +64,10 @@ type (
 	}
 )
 
-func NewLicenseCtrl(cf *conf.Config, st stor.Store) *LicenseCtrl {
+func NewLicenseCtrl(cf *conf.Live, st stor.Store) *LicenseCtrl {
 	return &LicenseCtrl{
-		Config: cf,
-		Store:  st,
+		Live:  cf,
+		Store: st,
 	}
 }
 
@@ -70,41 +76,31 @@ func NewLicenseCtrl(cf *conf.Config, st stor.Store) *LicenseCtrl {
 // NewStatusDoc returns a Status Document
 func (lc *LicenseCtrl) NewStatusDoc(license *stor.LicenseInfo) *StatusDoc {
 
-	// TODO: Study if an update of the publication could be conveyed to the user,
-	// so that the client can ask the user if the new version of the publication 
-	// has to be downloaded. 
-	// The status document could include an additional field with the 
-	// publication update date, and the client could compare it with cached data.
+	// Note: when the content of the publication changes, the update date of the license is set
+	// (see PublicationRepository.UpdateContent), therefore the client fetches a fresh license
+	// which points to the new content.
 
 	// set license updated
-	var licUpdated, statUpdated time.Time
-	if license.Updated != nil {
-		licUpdated = *license.Updated
-	} else {
-		licUpdated = license.CreatedAt
-	}
-	if license.StatusUpdated != nil {
-		statUpdated = *license.StatusUpdated
-	} else {
-		statUpdated = licUpdated
-	}
+	licUpdated, statUpdated := updatedDates(license)
 
 	// set the status document
 	statusDoc := &StatusDoc{
 		ID:      license.UUID,
 		Status:  license.Status,
-		Message: "The license is in " + license.Status + " state", // TODO: make flexible, localize
+		Message: lc.statusMessage(license.Status),
 		Updated: Updated{
 			License: licUpdated,
 			Status:  statUpdated,
 		},
 	}
 
+	// use a consistent configuration for the whole document
+	cfg := lc.Config()
+
 	// check if the license has expired
-	now := time.Now().Truncate(time.Second)
-	if (license.Status == stor.STATUS_READY || license.Status == stor.STATUS_ACTIVE) && license.End != nil && now.After(*license.End) {
+	if isExpired(license, time.Now()) {
 		statusDoc.Status = stor.STATUS_EXPIRED
-		statusDoc.Message = "The license has expired on " + license.End.Format(time.RFC822)
+		statusDoc.Message = lc.printer().Sprintf(i18n.StatusExpired, license.End.Format(time.RFC822))
 	}
 
 	// we don't need to return a max end date if the license is not ready or active
@@ -113,7 +109,7 @@ func (lc *LicenseCtrl) NewStatusDoc(license *stor.LicenseInfo) *StatusDoc {
 	}
 
 	// set the max end date if renew is supported
-	if license.MaxEnd != nil && lc.Config.Status.RenewMaxDays != 0 {
+	if license.MaxEnd != nil && cfg.Status.RenewMaxDays != 0 {
 		potentialRights := &PotentialRights{
 			End: license.MaxEnd,
 		}
@@ -121,14 +117,123 @@ func (lc *LicenseCtrl) NewStatusDoc(license *stor.LicenseInfo) *StatusDoc {
 	}
 
 	// set links
-	setStatusLinks(statusDoc, lc.Config.PublicBaseUrl, lc.Config.Status.FreshLicenseLink, lc.Config.Status.RenewMaxDays, lc.Config.Status.RenewLink)
+	setStatusLinks(statusDoc, cfg.PublicBaseUrl, FreshLicenseLink(cfg, license.UUID, time.Now()), cfg.Status.RenewMaxDays, cfg.Status.RenewLink)
 
 	// set events
-	setEvents(lc.Store, statusDoc)
+	setEvents(lc.Store, statusDoc, cfg.Status.MaxEvents, cfg.PublicBaseUrl)
 
 	return statusDoc
 }
 
+// FreshLicenseLink returns the fresh license link of a license: a signed link to the fresh license
+// route of the server if it serves fresh licenses, the link of the provider otherwise.
+func FreshLicenseLink(cfg *conf.Config, licenseID string, now time.Time) string {
+	if !cfg.FreshLicense.Enabled {
+		return cfg.Status.FreshLicenseLink
+	}
+	validity := time.Duration(cfg.FreshLicense.Validity) * time.Minute
+	return SignedFreshLink(cfg.PublicBaseUrl, cfg.FreshLicense.Secret, licenseID, LinkExpiry(validity, now))
+}
+
+// updatedDates returns the last update of the license and of its status
+func updatedDates(license *stor.LicenseInfo) (licUpdated, statUpdated time.Time) {
+	if license.Updated != nil {
+		licUpdated = *license.Updated
+	} else {
+		licUpdated = license.CreatedAt
+	}
+	if license.StatusUpdated != nil {
+		statUpdated = *license.StatusUpdated
+	} else {
+		statUpdated = licUpdated
+	}
+	return
+}
+
+// isExpired returns true if a ready or active license has passed its end date
+func isExpired(license *stor.LicenseInfo, now time.Time) bool {
+	now = now.Truncate(time.Second)
+	return (license.Status == stor.STATUS_READY || license.Status == stor.STATUS_ACTIVE) && license.End != nil && now.After(*license.End)
+}
+
+// StatusValidators returns the http cache validators of the status document of a license:
+// a strong entity tag and a last modification date. They are computed without loading the events,
+// and change on every license transition, including an expiration, and on every change of the configuration
+// affecting the document.
+func (lc *LicenseCtrl) StatusValidators(license *stor.LicenseInfo) (string, time.Time) {
+
+	cfg := lc.Config()
+	now := time.Now()
+	licUpdated, statUpdated := updatedDates(license)
+	status := license.Status
+	lastModified := licUpdated
+	if statUpdated.After(lastModified) {
+		lastModified = statUpdated
+	}
+	if isExpired(license, now) {
+		status = stor.STATUS_EXPIRED
+		if license.End.After(lastModified) {
+			lastModified = *license.End
+		}
+	}
+
+	// the message depends on the language and on the configuration
+	message := lc.statusMessage(status)
+	if status == stor.STATUS_EXPIRED {
+		message = lc.printer().Sprintf(i18n.StatusExpired, license.End.Format(time.RFC822))
+	}
+
+	// signed fresh license links change at each validity window
+	freshLink := FreshLicenseLink(cfg, license.UUID, now)
+	if cfg.FreshLicense.Enabled {
+		window := now.Truncate(time.Duration(cfg.FreshLicense.Validity) * time.Minute)
+		if window.After(lastModified) {
+			lastModified = window
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%d|%d|%d|%s|%s", license.UUID, status, message,
+		licUpdated.UnixNano(), statUpdated.UnixNano(), license.DeviceCount,
+		formatOptionalTime(license.End), formatOptionalTime(license.MaxEnd))
+	fmt.Fprintf(h, "|%s|%s|%d|%s|%d", cfg.PublicBaseUrl, freshLink, cfg.Status.RenewMaxDays, cfg.Status.RenewLink, cfg.Status.MaxEvents)
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+	return etag, lastModified
+}
+
+// statusMessages maps each license status to the key of its message
+var statusMessages = map[string]string{
+	stor.STATUS_READY:     i18n.StatusReady,
+	stor.STATUS_ACTIVE:    i18n.StatusActive,
+	stor.STATUS_REVOKED:   i18n.StatusRevoked,
+	stor.STATUS_RETURNED:  i18n.StatusReturned,
+	stor.STATUS_CANCELLED: i18n.StatusCancelled,
+}
+
+// statusMessage returns the localized message associated with a license status
+func (lc *LicenseCtrl) statusMessage(status string) string {
+	if key, ok := statusMessages[status]; ok {
+		return lc.printer().Sprintf(key)
+	}
+	return "The license is in " + status + " state"
+}
+
+// printer returns the printer of localized messages, English by default
+func (lc *LicenseCtrl) printer() *i18n.Printer {
+	if lc.Printer != nil {
+		return lc.Printer
+	}
+	return i18n.English
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
 // Set status links
 func setStatusLinks(statusDoc *StatusDoc, publicBaseUrl, freshLicenseLink string, renewMaxDays int, renewLink string) error {
 	var links []Link
@@ -190,20 +295,39 @@ func setStatusLinks(statusDoc *StatusDoc, publicBaseUrl, freshLicenseLink string
 	return nil
 }
 
+// statusDocEvents is the maximum number of events included in a status document (security limit)
+const statusDocEvents = 1000
+
 // Set events
-func setEvents(store stor.Store, statusDoc *StatusDoc) error {
+// If maxEvents is positive, only the most recent events are included; otherwise every event is included,
+// up to the security limit. The status document links to the full history of the license if events are left out.
+func setEvents(store stor.Store, statusDoc *StatusDoc, maxEvents int, publicBaseUrl string) error {
 
-	events, err := store.Event().List(statusDoc.ID)
+	n := statusDocEvents
+	if maxEvents > 0 {
+		n = maxEvents
+	}
+	events, err := store.Event().Latest(statusDoc.ID, n)
 	if err != nil {
 		return err
 	}
 	statusDoc.Events = *events
+	if maxEvents > 0 || len(*events) == statusDocEvents {
+		statusDoc.Links = append(statusDoc.Links, Link{
+			Href:      publicBaseUrl + "/status/" + statusDoc.ID + "/events{?page,per_page}",
+			Rel:       "events",
+			Type:      "application/json",
+			Templated: true,
+		})
+	}
 	return nil
 }
 
 // Register records that a new device is using a license
 func (lc *LicenseCtrl) Register(licenseID string, device *DeviceInfo) (*StatusDoc, error) {
 
+	logger := lc.logger(licenseID).WithField("device_id", device.ID)
+
 	// Get license info
 	license, err := lc.Store.License().Get(licenseID)
 	if err != nil {
@@ -218,7 +342,7 @@ func (lc *LicenseCtrl) Register(licenseID string, device *DeviceInfo) (*StatusDo
 	// check that the device has not already been registered for this license
 	_, err = lc.Store.Event().GetRegisterByDevice(license.UUID, device.ID)
 	if err == nil {
-		log.Warningf("Registration halted: the device %s is already registered", device.ID)
+		logger.Warningf("Registration halted: the device %s is already registered", device.ID)
 		statusDoc := lc.NewStatusDoc(license)
 		return statusDoc, nil
 	}
@@ -243,7 +367,7 @@ func (lc *LicenseCtrl) Register(licenseID string, device *DeviceInfo) (*StatusDo
 
 	err = lc.Store.Event().Create(event)
 	if err != nil {
-		log.Errorf("Failed to create an event: %v", err)
+		logger.Errorf("Failed to create an event: %v", err)
 		return nil, err
 	}
 
@@ -254,6 +378,8 @@ func (lc *LicenseCtrl) Register(licenseID string, device *DeviceInfo) (*StatusDo
 // Renew extends the end date of a license
 func (lc *LicenseCtrl) Renew(licenseID string, device *DeviceInfo, newEnd *time.Time) (*StatusDoc, error) {
 
+	logger := lc.logger(licenseID).WithField("device_id", device.ID)
+
 	// Get license info
 	license, err := lc.Store.License().Get(licenseID)
 	if err != nil {
@@ -262,24 +388,24 @@ func (lc *LicenseCtrl) Renew(licenseID string, device *DeviceInfo, newEnd *time.
 
 	// check that the license has an end date
 	if license.End == nil {
-		log.Warning("This license has no end date, cannot be renewed")
+		logger.Warning("This license has no end date, cannot be renewed")
 		return nil, errors.New("requesting a renew on a license that has no end date")
 	}
 
 	// if the provider has explicitly allowed it, expired licenses are reactivated and extended
-	if license.Status == stor.STATUS_EXPIRED && lc.Config.Status.AllowRenewOnExpiredLicenses {
+	if license.Status == stor.STATUS_EXPIRED && lc.Config().Status.AllowRenewOnExpiredLicenses {
 		license.Status = stor.STATUS_ACTIVE
 	}
 	// check that the license is in active state
 	if license.Status != stor.STATUS_ACTIVE {
-		log.Warning("Requesting a renew on a non-active license is prohibited")
+		logger.Warning("Requesting a renew on a non-active license is prohibited")
 		return nil, errors.New("requesting a renew on a non-active license is prohibited")
 	}
 
 	// check that the device had been registered for this license
 	_, err = lc.Store.Event().GetRegisterByDevice(license.UUID, device.ID)
 	if err != nil {
-		log.Warning("Requesting a renew on a license which has not been registered by this device is prohibited")
+		logger.Warning("Requesting a renew on a license which has not been registered by this device is prohibited")
 		return nil, errors.New("requesting a renew on a license which has not been registered by this device is prohibited")
 	}
 
@@ -287,20 +413,20 @@ func (lc *LicenseCtrl) Renew(licenseID string, device *DeviceInfo, newEnd *time.
 	if newEnd != nil {
 		// consider an explicit end date
 		if license.MaxEnd != nil && newEnd.After(*license.MaxEnd) {
-			log.Println("License extension limit is ", license.MaxEnd.Format(time.RFC822))
+			logger.Println("License extension limit is ", license.MaxEnd.Format(time.RFC822))
 			license.End = license.MaxEnd
 		} else {
 			license.End = newEnd
 		}
 		// no explicit new end date; consider a default end date set in the configuration file
-	} else if lc.Config.Status.RenewDefaultDays != 0 {
+	} else if lc.Config().Status.RenewDefaultDays != 0 {
 		// the number of days of the extension is based on the current timestamp, not the current end date
-		*license.End = time.Now().AddDate(0, 0, lc.Config.Status.RenewDefaultDays)
+		*license.End = time.Now().AddDate(0, 0, lc.Config().Status.RenewDefaultDays)
 		// the ultimate default is 7 days
 	} else {
 		*license.End = time.Now().AddDate(0, 0, 7)
 	}
-	log.Println("License extension; the new end date is ", license.End.Format(time.RFC822))
+	logger.Println("License extension; the new end date is ", license.End.Format(time.RFC822))
 
 	// update the license in the db
 	now := time.Now().Truncate(time.Second)
@@ -318,7 +444,7 @@ func (lc *LicenseCtrl) Renew(licenseID string, device *DeviceInfo, newEnd *time.
 
 	err = lc.Store.Event().Create(event)
 	if err != nil {
-		log.Errorf("Failed to create an event: %v", err)
+		logger.Errorf("Failed to create an event: %v", err)
 		return nil, err
 	}
 
@@ -329,6 +455,8 @@ func (lc *LicenseCtrl) Renew(licenseID string, device *DeviceInfo, newEnd *time.
 // Return forces the expiration of a license and returns a status document.
 func (lc *LicenseCtrl) Return(licenseID string, device *DeviceInfo) (*StatusDoc, error) {
 
+	logger := lc.logger(licenseID).WithField("device_id", device.ID)
+
 	// Get license info
 	license, err := lc.Store.License().Get(licenseID)
 	if err != nil {
@@ -337,34 +465,34 @@ func (lc *LicenseCtrl) Return(licenseID string, device *DeviceInfo) (*StatusDoc,
 
 	// check that the license has an end date
 	if license.End == nil {
-		log.Warning("This license has no end date, cannot be returned")
+		logger.Warning("This license has no end date, cannot be returned")
 		return nil, errors.New("requesting a return on a license that has no end date")
 	}
 
 	// check that the license has not already expired
 	now := time.Now().Truncate(time.Second)
 	if license.End.Before(now) {
-		log.Warning("This license has already expired on ", license.End.Format(time.RFC822))
+		logger.Warning("This license has already expired on ", license.End.Format(time.RFC822))
 		return nil, errors.New("this license expired on " + license.End.Format(time.RFC822))
 	}
 
 	// check that the license is in active status
 	if license.Status != stor.STATUS_ACTIVE {
-		log.Warning("Requesting a return on a non-active license is prohibited")
+		logger.Warning("Requesting a return on a non-active license is prohibited")
 		return nil, errors.New("requesting a return on a non-active license is prohibited")
 	}
 
 	// check that the device had been registered for this license
 	_, err = lc.Store.Event().GetRegisterByDevice(license.UUID, device.ID)
 	if err != nil {
-		log.Warning("Requesting a return on a license which has not been registered by this device is prohibited")
+		logger.Warning("Requesting a return on a license which has not been registered by this device is prohibited")
 		return nil, errors.New("requesting a return on a license which has not been registered by this device is prohibited")
 	}
 
 	// set the new end date
 	license.End = &now
 
-	log.Println("License returned; the new end date is ", license.End.Format(time.RFC822))
+	logger.Println("License returned; the new end date is ", license.End.Format(time.RFC822))
 
 	// update the license and status document in the db
 	license.Updated = &now
@@ -383,7 +511,7 @@ func (lc *LicenseCtrl) Return(licenseID string, device *DeviceInfo) (*StatusDoc,
 
 	err = lc.Store.Event().Create(event)
 	if err != nil {
-		log.Errorf("Failed to create an event: %v", err)
+		logger.Errorf("Failed to create an event: %v", err)
 		return nil, err
 	}
 
@@ -394,6 +522,8 @@ func (lc *LicenseCtrl) Return(licenseID string, device *DeviceInfo) (*StatusDoc,
 // Revoke forces the expiration of a license and returns a status document.
 func (lc *LicenseCtrl) Revoke(licenseID string) (*StatusDoc, error) {
 
+	logger := lc.logger(licenseID)
+
 	// Get license info
 	license, err := lc.Store.License().Get(licenseID)
 	if err != nil {
@@ -401,7 +531,7 @@ func (lc *LicenseCtrl) Revoke(licenseID string) (*StatusDoc, error) {
 	}
 
 	if license.Status == stor.STATUS_REVOKED || license.Status == stor.STATUS_CANCELLED {
-		log.Infof("The status of the license is already %s", license.Status)
+		logger.Infof("The status of the license is already %s", license.Status)
 		statusDoc := lc.NewStatusDoc(license)
 		return statusDoc, nil
 	}
@@ -416,7 +546,7 @@ func (lc *LicenseCtrl) Revoke(licenseID string) (*StatusDoc, error) {
 	now := time.Now().Truncate(time.Second)
 	license.End = &now
 
-	log.Println("License revoked or cancelled; the new end date is ", license.End.Format(time.RFC822))
+	logger.Println("License revoked or cancelled; the new end date is ", license.End.Format(time.RFC822))
 
 	// update the license and status document in the db
 	license.Updated = &now
@@ -444,10 +574,19 @@ func (lc *LicenseCtrl) Revoke(licenseID string) (*StatusDoc, error) {
 
 	err = lc.Store.Event().Create(event)
 	if err != nil {
-		log.Errorf("Failed to create an event: %v", err)
+		logger.Errorf("Failed to create an event: %v", err)
 		return nil, err
 	}
 
 	statusDoc := lc.NewStatusDoc(license)
 	return statusDoc, nil
 }
+
+// logger returns the logger of the controller, with the license identifier as a field
+func (lc *LicenseCtrl) logger(licenseID string) *log.Entry {
+	entry := lc.Log
+	if entry == nil {
+		entry = log.NewEntry(log.StandardLogger())
+	}
+	return entry.WithField("license_id", licenseID)
+}
@@ -5,7 +5,9 @@
 package lic
 
 import (
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/edrlab/lcp-server/pkg/stor"
 )
@@ -87,3 +89,31 @@ func TestRevoke(t *testing.T) {
 	}
 
 }
+
+func TestFreshLink(t *testing.T) {
+
+	secret := "a secret used for testing purposes only"
+	validity := time.Hour
+	now := time.Now()
+
+	// the link is the same during a validity window, and valid for at least the validity period
+	expires := LinkExpiry(validity, now)
+	if expires != LinkExpiry(validity, now.Truncate(validity)) || expires.Sub(now) < validity {
+		t.Fatalf("unexpected expiration date %v", expires)
+	}
+
+	link, err := url.Parse(SignedFreshLink("https://lcp.example.com", secret, LicInfo.UUID, expires))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := link.Query()
+	if err := CheckFreshLink(secret, LicInfo.UUID, q.Get("expires"), q.Get("sig"), now); err != nil {
+		t.Fatalf("failed to check a fresh license link: %v", err)
+	}
+	if err := CheckFreshLink(secret, LicInfo.UUID, q.Get("expires"), q.Get("sig"), expires.Add(time.Second)); err != ErrLinkExpired {
+		t.Fatalf("expected an expired link, got %v", err)
+	}
+	if err := CheckFreshLink("another secret", LicInfo.UUID, q.Get("expires"), q.Get("sig"), now); err != ErrLinkSignature {
+		t.Fatalf("expected an invalid signature, got %v", err)
+	}
+}
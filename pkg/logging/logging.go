@@ -0,0 +1,159 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// Package logging sets up the structured logger shared by the LCP Server
+// and gives access to request scoped log entries.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the http header used to propagate request identifiers.
+const RequestIDHeader = "X-Request-ID"
+
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	entryKey
+)
+
+// Init sets the level and format of the standard logger.
+// An empty level defaults to info, an empty format defaults to json.
+func Init(level, format string) error {
+
+	lvl := log.InfoLevel
+	if level != "" {
+		var err error
+		if lvl, err = log.ParseLevel(level); err != nil {
+			return fmt.Errorf("invalid log level %q", level)
+		}
+	}
+	log.SetLevel(lvl)
+
+	switch format {
+	case "", FormatJSON:
+		log.SetFormatter(&log.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	case FormatText:
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+	return nil
+}
+
+// RequestID returns the request identifier stored in the context, or an empty string.
+func RequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// NewContext returns a copy of the context which carries the log entry.
+func NewContext(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, entryKey, entry)
+}
+
+// FromContext returns the log entry stored in the context.
+// The standard logger is used if no entry was stored.
+func FromContext(ctx context.Context) *log.Entry {
+	if entry, ok := ctx.Value(entryKey).(*log.Entry); ok {
+		return entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// FromRequest returns the log entry attached to the request.
+func FromRequest(r *http.Request) *log.Entry {
+	return FromContext(r.Context())
+}
+
+// RequestIDMiddleware propagates the request identifier found in the request headers,
+// or generates a new one. The identifier is stored in the context and returned as a header.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware attaches a log entry to the request, then logs the completion of the request.
+// It must be set after RequestIDMiddleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := log.WithFields(log.Fields{
+			"request_id": RequestID(r.Context()),
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"remote":     r.RemoteAddr,
+		})
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r.WithContext(NewContext(r.Context(), entry)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		fields := log.Fields{
+			"status":      status,
+			"bytes":       ww.BytesWritten(),
+			"duration_ms": time.Since(start).Milliseconds(),
+		}
+		// the route and url parameters are only known once the request has been routed
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			fields["route"] = rctx.RoutePattern()
+			if licenseID := rctx.URLParam("licenseID"); licenseID != "" {
+				fields["license_id"] = licenseID
+			}
+			if userID := rctx.URLParam("userID"); userID != "" {
+				fields["user_id"] = userID
+			}
+		}
+		entry = entry.WithFields(fields)
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("request completed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	})
+}
+
+// validRequestID checks that a propagated request identifier is safe to log and return.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
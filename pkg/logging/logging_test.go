@@ -0,0 +1,104 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestInit(t *testing.T) {
+
+	if err := Init("debug", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if log.GetLevel() != log.DebugLevel {
+		t.Errorf("expected debug level, got %s", log.GetLevel())
+	}
+	if err := Init("verbose", ""); err == nil {
+		t.Error("expected an error on an invalid level")
+	}
+	if err := Init("", "xml"); err == nil {
+		t.Error("expected an error on an invalid format")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+
+	var got string
+	h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestID(r.Context())
+	}))
+
+	// propagated identifier
+	req := httptest.NewRequest("GET", "/status/123", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got != "abc-123" {
+		t.Errorf("expected the propagated request id, got %q", got)
+	}
+	if rr.Header().Get(RequestIDHeader) != "abc-123" {
+		t.Error("expected the request id in the response headers")
+	}
+
+	// generated identifier, as the propagated one is invalid
+	req = httptest.NewRequest("GET", "/status/123", nil)
+	req.Header.Set(RequestIDHeader, "not valid")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if _, err := uuid.Parse(got); err != nil {
+		t.Errorf("expected a generated uuid, got %q", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+	Init("info", "json")
+
+	r := chi.NewRouter()
+	r.Use(RequestIDMiddleware)
+	r.Use(Middleware)
+	r.Get("/status/{licenseID}", func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).Info("in handler")
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest("GET", "/status/123", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("expected a json log line, got %s", line)
+		}
+		if entry["request_id"] != "req-1" {
+			t.Errorf("expected the request id in %s", line)
+		}
+	}
+	var last map[string]interface{}
+	json.Unmarshal(lines[1], &last)
+	if last["route"] != "/status/{licenseID}" || last["license_id"] != "123" {
+		t.Errorf("expected route and license id fields, got %s", lines[1])
+	}
+	if last["status"] != float64(http.StatusNotFound) {
+		t.Errorf("expected status 404, got %v", last["status"])
+	}
+}
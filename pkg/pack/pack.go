@@ -0,0 +1,263 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The pack package embeds a license in an encrypted publication packaged as a zip file.
+// The entries of the package are not rewritten: the license is appended after them,
+// followed by a new central directory. The resulting package is therefore built
+// without copying the original file, and supports random access.
+package pack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotAPackage = errors.New("the publication is not packaged as a zip file")
+	ErrZip64       = errors.New("zip64 packages are not supported")
+)
+
+// zip signatures and fixed sizes
+const (
+	localHeaderSig = 0x04034b50
+	centralDirSig  = 0x02014b50
+	endOfDirSig    = 0x06054b50
+	zip64LocSig    = 0x07064b50
+	localHeaderLen = 30
+	centralDirLen  = 46
+	endOfDirLen    = 22
+	zip64LocLen    = 20
+	maxCommentLen  = 0xffff
+)
+
+// LicensePath returns the path of the license in a package of a given content type,
+// an empty string if publications of this type are not packaged.
+func LicensePath(contentType string) string {
+	switch contentType {
+	case "application/epub+zip":
+		return "META-INF/license.lcpl"
+	case "application/pdf+lcp", "application/audiobook+lcp", "application/divina+lcp":
+		return "license.lcpl"
+	}
+	return ""
+}
+
+// Package is a zip package in which a license has been embedded.
+type Package struct {
+	src    io.ReaderAt
+	prefix int64  // size of the original entries, read from src
+	tail   []byte // license entry, central directory and end of central directory
+	pos    int64
+}
+
+// Embed returns the package read from src, of a given size, with the license stored at path.
+// A license already present at this path is replaced.
+func Embed(src io.ReaderAt, size int64, path string, license []byte, modTime time.Time) (*Package, error) {
+
+	eocd, eocdOff, err := findEndOfDir(src, size)
+	if err != nil {
+		return nil, err
+	}
+	entries := int(binary.LittleEndian.Uint16(eocd[10:]))
+	cdSize := int64(binary.LittleEndian.Uint32(eocd[12:]))
+	cdOff := int64(binary.LittleEndian.Uint32(eocd[16:]))
+	comment := eocd[endOfDirLen:]
+	if entries == 0xffff || cdSize == 0xffffffff || cdOff == 0xffffffff {
+		return nil, ErrZip64
+	}
+	if cdOff+cdSize > eocdOff {
+		return nil, ErrNotAPackage
+	}
+	if cdOff+int64(localHeaderLen+len(path)+len(license)) >= 0xffffffff {
+		return nil, ErrZip64
+	}
+
+	// original central directory records, except the one of a previous license
+	cd := make([]byte, cdSize)
+	if _, err := src.ReadAt(cd, cdOff); err != nil {
+		return nil, ErrNotAPackage
+	}
+	var records bytes.Buffer
+	kept := 0
+	for i := 0; i < entries; i++ {
+		if len(cd) < centralDirLen || binary.LittleEndian.Uint32(cd) != centralDirSig {
+			return nil, ErrNotAPackage
+		}
+		n := centralDirLen + int(binary.LittleEndian.Uint16(cd[28:])) + int(binary.LittleEndian.Uint16(cd[30:])) + int(binary.LittleEndian.Uint16(cd[32:]))
+		if len(cd) < n {
+			return nil, ErrNotAPackage
+		}
+		name := string(cd[centralDirLen : centralDirLen+int(binary.LittleEndian.Uint16(cd[28:]))])
+		if name != path {
+			records.Write(cd[:n])
+			kept++
+		}
+		cd = cd[n:]
+	}
+	if kept+1 > 0xfffe {
+		return nil, ErrZip64
+	}
+
+	// the license is stored without compression
+	dosTime, dosDate := dosDateTime(modTime)
+	crc := crc32.ChecksumIEEE(license)
+	var tail bytes.Buffer
+	le := func(v interface{}) { binary.Write(&tail, binary.LittleEndian, v) }
+
+	le(uint32(localHeaderSig))
+	le(uint16(20)) // version needed
+	le(uint16(0))  // flags
+	le(uint16(0))  // method: stored
+	le(dosTime)
+	le(dosDate)
+	le(crc)
+	le(uint32(len(license)))
+	le(uint32(len(license)))
+	le(uint16(len(path)))
+	le(uint16(0)) // extra length
+	tail.WriteString(path)
+	tail.Write(license)
+
+	newCDOff := tail.Len()
+	tail.Write(records.Bytes())
+	le(uint32(centralDirSig))
+	le(uint16(20)) // version made by
+	le(uint16(20)) // version needed
+	le(uint16(0))  // flags
+	le(uint16(0))  // method: stored
+	le(dosTime)
+	le(dosDate)
+	le(crc)
+	le(uint32(len(license)))
+	le(uint32(len(license)))
+	le(uint16(len(path)))
+	le(uint16(0))     // extra length
+	le(uint16(0))     // comment length
+	le(uint16(0))     // disk number
+	le(uint16(0))     // internal attributes
+	le(uint32(0))     // external attributes
+	le(uint32(cdOff)) // offset of the local header
+	tail.WriteString(path)
+	newCDSize := tail.Len() - newCDOff
+
+	le(uint32(endOfDirSig))
+	le(uint16(0)) // disk number
+	le(uint16(0)) // disk of the central directory
+	le(uint16(kept + 1))
+	le(uint16(kept + 1))
+	le(uint32(newCDSize))
+	le(uint32(cdOff + int64(newCDOff)))
+	le(uint16(len(comment)))
+	tail.Write(comment)
+
+	return &Package{src: src, prefix: cdOff, tail: tail.Bytes()}, nil
+}
+
+// findEndOfDir returns the end of central directory record of a zip file, with its comment, and its offset.
+func findEndOfDir(src io.ReaderAt, size int64) ([]byte, int64, error) {
+
+	n := int64(endOfDirLen + maxCommentLen)
+	if n > size {
+		n = size
+	}
+	if n < endOfDirLen {
+		return nil, 0, ErrNotAPackage
+	}
+	buf := make([]byte, n)
+	if _, err := src.ReadAt(buf, size-n); err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	for i := len(buf) - endOfDirLen; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) != endOfDirSig {
+			continue
+		}
+		commentLen := int(binary.LittleEndian.Uint16(buf[i+20:]))
+		if i+endOfDirLen+commentLen != len(buf) {
+			continue
+		}
+		if i >= zip64LocLen && binary.LittleEndian.Uint32(buf[i-zip64LocLen:]) == zip64LocSig {
+			return nil, 0, ErrZip64
+		}
+		return buf[i:], size - n + int64(i), nil
+	}
+	return nil, 0, ErrNotAPackage
+}
+
+// dosDateTime converts a time to the MS-DOS format used by zip files.
+func dosDateTime(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()/2),
+		uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
+
+// Size returns the size of the package.
+func (p *Package) Size() int64 {
+	return p.prefix + int64(len(p.tail))
+}
+
+// ReadAt implements io.ReaderAt.
+func (p *Package) ReadAt(b []byte, off int64) (int, error) {
+
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	n := 0
+	if off < p.prefix {
+		m := int64(len(b))
+		if off+m > p.prefix {
+			m = p.prefix - off
+		}
+		c, err := p.src.ReadAt(b[:m], off)
+		n += c
+		if err != nil && !(err == io.EOF && int64(c) == m) {
+			return n, err
+		}
+		off += int64(c)
+	}
+	if n < len(b) && off >= p.prefix && off-p.prefix < int64(len(p.tail)) {
+		n += copy(b[n:], p.tail[off-p.prefix:])
+	}
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (p *Package) Read(b []byte) (int, error) {
+	if p.pos >= p.Size() {
+		return 0, io.EOF
+	}
+	n, err := p.ReadAt(b, p.pos)
+	p.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (p *Package) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += p.pos
+	case io.SeekEnd:
+		offset += p.Size()
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	p.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package pack
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func newZip(t *testing.T, files map[string]string, comment string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	w.SetComment(comment)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readZip(t *testing.T, p *Package) map[string]string {
+	r, err := zip.NewReader(p, p.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", f.Name, err)
+		}
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func TestEmbed(t *testing.T) {
+
+	src := newZip(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": "<container/>",
+		"META-INF/license.lcpl":  "an old license",
+		"OEBPS/chapter1.xhtml":   "encrypted content",
+	}, "a comment")
+
+	license := `{"id":"123"}`
+	p, err := Embed(bytes.NewReader(src), int64(len(src)), "META-INF/license.lcpl", []byte(license), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, p)
+	if len(files) != 4 {
+		t.Errorf("Expected 4 entries, got %d", len(files))
+	}
+	if files["META-INF/license.lcpl"] != license {
+		t.Errorf("Unexpected license %q", files["META-INF/license.lcpl"])
+	}
+	if files["OEBPS/chapter1.xhtml"] != "encrypted content" {
+		t.Error("Failed to keep the entries of the package")
+	}
+
+	// sequential reading gives the same bytes as random access
+	all, err := io.ReadAll(p)
+	if err != nil || int64(len(all)) != p.Size() {
+		t.Fatalf("Failed to read the package: %v", err)
+	}
+	part := make([]byte, 100)
+	off := p.Size() - 150
+	if _, err := p.ReadAt(part, off); err != nil || !bytes.Equal(part, all[off:off+100]) {
+		t.Error("Failed to read a range of the package")
+	}
+	if !bytes.Equal(all[:100], src[:100]) {
+		t.Error("Expected the original entries first")
+	}
+}
+
+func TestEmbedNotAPackage(t *testing.T) {
+
+	src := []byte("%PDF-1.7 this is not a zip file")
+	if _, err := Embed(bytes.NewReader(src), int64(len(src)), "license.lcpl", []byte("{}"), time.Now()); err != ErrNotAPackage {
+		t.Errorf("Expected ErrNotAPackage, got %v", err)
+	}
+}
+
+func TestLicensePath(t *testing.T) {
+
+	if p := LicensePath("application/epub+zip"); p != "META-INF/license.lcpl" {
+		t.Errorf("Unexpected path %s", p)
+	}
+	if p := LicensePath("application/audiobook+lcp"); p != "license.lcpl" {
+		t.Errorf("Unexpected path %s", p)
+	}
+	if p := LicensePath("application/pdf"); p != "" {
+		t.Errorf("Unexpected path %s", p)
+	}
+}
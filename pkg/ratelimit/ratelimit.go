@@ -0,0 +1,172 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The ratelimit package implements token bucket rate limiting, using the generic cell rate algorithm.
+// Each bucket is represented by a single value, the theoretical arrival time (TAT) of the next request,
+// which makes it easy to share buckets between server instances.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+	"gorm.io/gorm"
+)
+
+// Limit defines a token bucket: PerMinute tokens are added every minute, up to Burst tokens.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled returns true if the limit must be applied.
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// interval is the time needed to add a token to the bucket
+func (l Limit) interval() time.Duration {
+	return time.Minute / time.Duration(l.PerMinute)
+}
+
+// Store keeps the state of token buckets.
+type Store interface {
+	// Take consumes a token from the bucket identified by key. If the bucket is empty,
+	// it returns false and the delay after which a token will be available.
+	Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error)
+}
+
+// gcra computes the new theoretical arrival time of a bucket after a request.
+// If the request is not allowed, the TAT is unchanged and the delay before a retry is returned.
+func gcra(now, tat time.Time, limit Limit) (time.Time, bool, time.Duration) {
+	interval := limit.interval()
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-time.Duration(burst) * interval)
+	if now.Before(allowAt) {
+		return tat, false, allowAt.Sub(now)
+	}
+	return newTAT, true, 0
+}
+
+// purgeInterval is the delay between two purges of the full buckets
+const purgeInterval = time.Minute
+
+// MemoryStore keeps the buckets in memory. It is only accurate with a single server instance.
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns an empty memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats:      make(map[string]time.Time),
+		lastPurge: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take implements Store.
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastPurge) > purgeInterval {
+		// a bucket whose TAT is in the past is full, it can be forgotten
+		for k, tat := range m.tats {
+			if tat.Before(now) {
+				delete(m.tats, k)
+			}
+		}
+		m.lastPurge = now
+	}
+	tat, ok, retryAfter := gcra(now, m.tats[key], limit)
+	if ok {
+		m.tats[key] = tat
+	}
+	return ok, retryAfter, nil
+}
+
+// SharedStore keeps the buckets in the database, so that the limits apply to every server instance.
+type SharedStore struct {
+	repo      stor.RateLimitRepository
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+// NewSharedStore returns a store using the rate limit repository of the database.
+func NewSharedStore(st stor.Store) *SharedStore {
+	return &SharedStore{
+		repo:      st.RateLimit(),
+		lastPurge: time.Now(),
+	}
+}
+
+// maxAttempts is the number of optimistic updates tried when concurrent requests modify the same bucket
+const maxAttempts = 5
+
+// ErrContention is returned when a bucket could not be updated because of concurrent updates.
+var ErrContention = errors.New("rate limit bucket contention")
+
+// Take implements Store.
+func (s *SharedStore) Take(ctx context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	s.purge()
+
+	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return false, 0, err
+		}
+		now := time.Now()
+		rl, err := s.repo.Get(key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tat, ok, retryAfter := gcra(now, time.Time{}, limit)
+			if ok {
+				// another instance may have created the bucket in the meantime, then retry
+				if err = s.repo.Create(&stor.RateLimit{ID: key, TAT: tat.UnixNano()}); err != nil {
+					continue
+				}
+			}
+			return ok, retryAfter, nil
+		}
+		if err != nil {
+			return false, 0, err
+		}
+		tat, ok, retryAfter := gcra(now, time.Unix(0, rl.TAT), limit)
+		if !ok {
+			return false, retryAfter, nil
+		}
+		swapped, err := s.repo.CompareAndSwap(key, rl.TAT, tat.UnixNano())
+		if err != nil {
+			return false, 0, err
+		}
+		if swapped {
+			return true, 0, nil
+		}
+	}
+	return false, 0, ErrContention
+}
+
+// purge deletes the full buckets from time to time, in the background
+func (s *SharedStore) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.lastPurge) < purgeInterval {
+		return
+	}
+	s.lastPurge = time.Now()
+	go s.repo.Purge(time.Now().UnixNano())
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/stor"
+)
+
+func TestMemoryStore(t *testing.T) {
+
+	m := NewMemoryStore()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	limit := Limit{PerMinute: 60, Burst: 3}
+	ctx := context.Background()
+
+	// the burst is allowed
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := m.Take(ctx, "ip:1.2.3.4", limit); !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	// then the bucket is empty
+	ok, retryAfter, _ := m.Take(ctx, "ip:1.2.3.4", limit)
+	if ok {
+		t.Fatal("request should be limited")
+	}
+	if retryAfter != time.Second {
+		t.Errorf("expected a retry after 1s, got %v", retryAfter)
+	}
+	// other keys are not impacted
+	if ok, _, _ := m.Take(ctx, "ip:5.6.7.8", limit); !ok {
+		t.Error("another key should be allowed")
+	}
+	// a token is added every second
+	now = now.Add(time.Second)
+	if ok, _, _ := m.Take(ctx, "ip:1.2.3.4", limit); !ok {
+		t.Error("request should be allowed after the retry delay")
+	}
+	if ok, _, _ := m.Take(ctx, "ip:1.2.3.4", limit); ok {
+		t.Error("request should be limited again")
+	}
+}
+
+func TestSharedStore(t *testing.T) {
+
+	st, err := stor.Init("sqlite3://file:ratelimit?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSharedStore(st)
+	limit := Limit{PerMinute: 1, Burst: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		ok, _, err := s.Take(ctx, "license:123", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	ok, retryAfter, err := s.Take(ctx, "license:123", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("request should be limited")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("unexpected retry delay %v", retryAfter)
+	}
+}
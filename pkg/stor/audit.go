@@ -0,0 +1,29 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package stor
+
+import "time"
+
+// Audit data model, the record of an administrative operation affecting many licenses or users.
+type Audit struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Timestamp time.Time `json:"timestamp" gorm:"index"`
+	Action    string    `json:"action" gorm:"type:varchar(100);index"`
+	Actor     string    `json:"actor,omitempty" gorm:"type:varchar(255)"` // identity of the caller
+	Reason    string    `json:"reason"`
+	Target    string    `json:"target"` // JSON description of the selected entities
+	Count     int64     `json:"count"`  // number of selected entities
+	JobID     string    `json:"job_id,omitempty" gorm:"type:varchar(100)"`
+}
+
+func (s auditStore) List(pageNum, pageSize int) (*[]Audit, error) {
+	audits := []Audit{}
+	// pageNum starts at 1
+	return &audits, s.db.Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("id DESC").Find(&audits).Error
+}
+
+func (s auditStore) Create(newAudit *Audit) error {
+	return s.db.Create(newAudit).Error
+}
@@ -6,6 +6,8 @@ package stor
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // Event data model
@@ -20,12 +22,82 @@ type Event struct {
 	License    LicenseInfo `json:"-" gorm:"references:UUID"`          // the event belongs to the license
 }
 
+// EventFilter selects events; empty fields are ignored.
+type EventFilter struct {
+	LicenseID string
+	Type      string
+	DeviceID  string
+	Since     *time.Time // inclusive
+	Until     *time.Time // exclusive
+}
+
+// maxEvents is the maximum number of events returned by a request
+const maxEvents = 1000
+
+// query returns a query selecting the events matching the filter
+func (s eventStore) query(f EventFilter) *gorm.DB {
+	query := s.db.Model(&Event{})
+	if f.LicenseID != "" {
+		query = query.Where("license_id = ?", f.LicenseID)
+	}
+	if f.Type != "" {
+		query = query.Where("type = ?", f.Type)
+	}
+	if f.DeviceID != "" {
+		query = query.Where("device_id = ?", f.DeviceID)
+	}
+	if f.Since != nil {
+		query = query.Where("timestamp >= ?", *f.Since)
+	}
+	if f.Until != nil {
+		query = query.Where("timestamp < ?", *f.Until)
+	}
+	return query
+}
+
 func (s eventStore) List(licenseID string) (*[]Event, error) {
 	events := []Event{}
 	// security: limited to 500 results
 	return &events, s.db.Limit(500).Where("license_id= ?", licenseID).Order("id ASC").Find(&events).Error
 }
 
+// Search returns a page of the events matching the filter, in chronological order,
+// and the total number of matching events. pageNum starts at 1; if pageNum or pageSize is 0,
+// the first events are returned, up to a security limit.
+func (s eventStore) Search(f EventFilter, pageNum, pageSize int) (*[]Event, int64, error) {
+	events := []Event{}
+	var total int64
+	if err := s.query(f).Count(&total).Error; err != nil {
+		return &events, 0, err
+	}
+	if pageNum <= 0 || pageSize <= 0 {
+		pageNum, pageSize = 1, maxEvents
+	}
+	if pageSize > maxEvents {
+		pageSize = maxEvents
+	}
+	return &events, total, s.query(f).Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("id ASC").Find(&events).Error
+}
+
+// SearchAll returns every event matching the filter, in chronological order, without the security limit.
+// It is reserved to exports.
+func (s eventStore) SearchAll(f EventFilter) (*[]Event, error) {
+	events := []Event{}
+	return &events, s.query(f).Order("id ASC").Find(&events).Error
+}
+
+// Latest returns the n most recent events of a license, in chronological order.
+func (s eventStore) Latest(licenseID string, n int) (*[]Event, error) {
+	events := []Event{}
+	if err := s.db.Limit(n).Where("license_id= ?", licenseID).Order("id DESC").Find(&events).Error; err != nil {
+		return &events, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return &events, nil
+}
+
 func (s eventStore) GetRegisterByDevice(licenseID string, deviceID string) (*Event, error) {
 	var event Event
 	return &event, s.db.Where("license_id= ? and type= 'register' and device_id= ?", licenseID, deviceID).First(&event).Error
@@ -33,7 +105,7 @@ func (s eventStore) GetRegisterByDevice(licenseID string, deviceID string) (*Eve
 
 func (s eventStore) Count(licenseID string) (int64, error) {
 	var count int64
-	return count, s.db.Model(Event{}).Count(&count).Error
+	return count, s.query(EventFilter{LicenseID: licenseID}).Count(&count).Error
 }
 
 func (s eventStore) Get(id uint) (*Event, error) {
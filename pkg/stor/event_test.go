@@ -74,8 +74,7 @@ func TestEvent(t *testing.T) {
 	}
 
 	// list events
-	var events *[]Event
-	events, err = St.Event().List(l.UUID)
+	events, err := St.Event().List(l.UUID)
 	if err != nil {
 		t.Fatalf("Failed to list events: %v", err)
 	}
@@ -83,6 +82,47 @@ func TestEvent(t *testing.T) {
 		t.Fatalf("Failed to list, expected 2 got %d", count)
 	}
 
+	// events of another license are not counted
+	other := &Event{Timestamp: now, Type: "renew", DeviceID: "Test Device ID 1", LicenseID: Licenses[1].UUID}
+	if err = St.Event().Create(other); err != nil {
+		t.Fatalf("Failed to create an event: %v", err)
+	}
+	defer St.Event().Delete(other)
+	if count, _ = St.Event().Count(l.UUID); count != 2 {
+		t.Fatalf("Failed to count the events of a license, expected 2 got %d", count)
+	}
+
+	// search events
+	events, total, err := St.Event().Search(EventFilter{LicenseID: l.UUID}, 2, 1)
+	if err != nil {
+		t.Fatalf("Failed to search events: %v", err)
+	}
+	if total != 2 || len(*events) != 1 || (*events)[0].ID != e2.ID {
+		t.Fatalf("Unexpected page of events, total %d, %d events", total, len(*events))
+	}
+	_, total, _ = St.Event().Search(EventFilter{DeviceID: "Test Device ID 1"}, 0, 0)
+	if total != 2 {
+		t.Fatalf("Failed to search events by device, expected 2 got %d", total)
+	}
+	_, total, _ = St.Event().Search(EventFilter{LicenseID: l.UUID, Type: "renew"}, 0, 0)
+	if total != 0 {
+		t.Fatalf("Failed to search events by type, expected 0 got %d", total)
+	}
+	until := now.Add(-time.Hour)
+	_, total, _ = St.Event().Search(EventFilter{LicenseID: l.UUID, Until: &until}, 0, 0)
+	if total != 0 {
+		t.Fatalf("Failed to search events by date, expected 0 got %d", total)
+	}
+
+	// get the most recent event
+	events, err = St.Event().Latest(l.UUID, 1)
+	if err != nil {
+		t.Fatalf("Failed to get the latest events: %v", err)
+	}
+	if len(*events) != 1 || (*events)[0].ID != e2.ID {
+		t.Fatal("Failed to get the latest event")
+	}
+
 	// get the register event associated with the first device
 	event, err = St.Event().GetRegisterByDevice(l.UUID, e1.DeviceID)
 	if err != nil {
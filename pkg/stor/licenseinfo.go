@@ -33,6 +33,23 @@ type LicenseInfo struct {
 	DeviceCount   int         `json:"device_count" gorm:"index"`
 	PublicationID string      `json:"publication_id" validate:"required,uuid"  gorm:"type:varchar(100);index"` // implicit foreign key to the related publication
 	Publication   Publication `gorm:"references:UUID" validate:"-"`                                            // the license belongs to the publication
+	// custom rights and extensions of the license, keyed by a URI, stored as json
+	Rights     map[string]interface{} `json:"rights,omitempty" gorm:"serializer:json"`
+	Extensions map[string]interface{} `json:"extensions,omitempty" gorm:"serializer:json"`
+	// user info captured at generation if the server serves fresh licenses, never returned by the api
+	FreshData *FreshLicenseData `json:"-" gorm:"serializer:json"`
+}
+
+// FreshLicenseData is the user info and user key material from which
+// the server regenerates a license on its fresh license route.
+type FreshLicenseData struct {
+	UserName       string                 `json:"user_name,omitempty"`
+	UserEmail      string                 `json:"user_email,omitempty"`
+	UserEncrypted  []string               `json:"user_encrypted,omitempty"`
+	UserProperties map[string]interface{} `json:"user_properties,omitempty"`
+	Profile        string                 `json:"profile,omitempty"`
+	TextHint       string                 `json:"text_hint"`
+	PassHash       string                 `json:"pass_hash"`
 }
 
 // Validate checks required fields and values
@@ -133,6 +150,45 @@ func (s licenseStore) FindByDate(dateStr string, pubInfo bool) (*[]LicenseInfo,
 	}
 }
 
+// LicenseFilter selects licenses; empty fields are ignored.
+type LicenseFilter struct {
+	UserID        string
+	PublicationID string
+	Statuses      []string
+	MinDevices    *int
+	MaxDevices    *int
+	IssuedSince   *time.Time // inclusive
+	IssuedUntil   *time.Time // exclusive
+}
+
+// FindIDs returns the identifiers of every license matching the filter, with no limit.
+func (s licenseStore) FindIDs(f LicenseFilter) ([]string, error) {
+	query := s.db.Model(&LicenseInfo{})
+	if f.UserID != "" {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.PublicationID != "" {
+		query = query.Where("publication_id = ?", f.PublicationID)
+	}
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if f.MinDevices != nil {
+		query = query.Where("device_count >= ?", *f.MinDevices)
+	}
+	if f.MaxDevices != nil {
+		query = query.Where("device_count <= ?", *f.MaxDevices)
+	}
+	if f.IssuedSince != nil {
+		query = query.Where("created_at >= ?", *f.IssuedSince)
+	}
+	if f.IssuedUntil != nil {
+		query = query.Where("created_at < ?", *f.IssuedUntil)
+	}
+	var ids []string
+	return ids, query.Order("id ASC").Pluck("uuid", &ids).Error
+}
+
 func (s licenseStore) Count() (int64, error) {
 	var count int64
 	return count, s.db.Model(LicenseInfo{}).Count(&count).Error
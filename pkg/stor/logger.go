@@ -0,0 +1,97 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package stor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger routes gorm logs through the structured logger of the application,
+// using the log entry carried by the context of a request if any.
+type gormLogger struct {
+	level         *logger.LogLevel // set by LogMode, otherwise the level follows the level of the standard logger
+	slowThreshold time.Duration
+}
+
+func newGormLogger() logger.Interface {
+	return &gormLogger{slowThreshold: time.Second}
+}
+
+// logLevel returns the level of the logger. The level of the standard logger is read on each call,
+// as it is modified when the configuration is reloaded.
+func (l *gormLogger) logLevel() logger.LogLevel {
+	if l.level != nil {
+		return *l.level
+	}
+	switch log.GetLevel() {
+	case log.DebugLevel, log.TraceLevel:
+		return logger.Info
+	case log.ErrorLevel, log.FatalLevel, log.PanicLevel:
+		return logger.Error
+	}
+	return logger.Warn
+}
+
+// entry returns the log entry of the context, e.g. the request scoped entry.
+func (l *gormLogger) entry(ctx context.Context) *log.Entry {
+	return logging.FromContext(ctx).WithField("component", "gorm")
+}
+
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	newLogger := *l
+	newLogger.level = &level
+	return &newLogger
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel() >= logger.Info {
+		l.entry(ctx).Infof(msg, data...)
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel() >= logger.Warn {
+		l.entry(ctx).Warnf(msg, data...)
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.logLevel() >= logger.Error {
+		l.entry(ctx).Errorf(msg, data...)
+	}
+}
+
+// Trace logs sql errors, slow queries and, at the info level, every query.
+// Record not found errors are ignored, as they are handled by the callers.
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	level := l.logLevel()
+	if level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	entry := func() *log.Entry {
+		sql, rows := fc()
+		return l.entry(ctx).WithFields(log.Fields{
+			"sql":         sql,
+			"rows":        rows,
+			"duration_ms": elapsed.Milliseconds(),
+		})
+	}
+	switch {
+	case err != nil && level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		entry().WithError(err).Error("sql error")
+	case elapsed > l.slowThreshold && level >= logger.Warn:
+		entry().Warn("slow sql query")
+	case level >= logger.Info:
+		entry().Debug("sql query")
+	}
+}
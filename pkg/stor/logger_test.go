@@ -0,0 +1,54 @@
+package stor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/edrlab/lcp-server/pkg/logging"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+func TestGormLogger(t *testing.T) {
+
+	var buf bytes.Buffer
+	out, level, formatter := log.StandardLogger().Out, log.GetLevel(), log.StandardLogger().Formatter
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetOutput(out)
+		log.SetLevel(level)
+		log.SetFormatter(formatter)
+	}()
+	log.SetFormatter(&log.JSONFormatter{})
+
+	// sql queries are logged with the request scoped entry
+	ctx := logging.NewContext(context.Background(), log.WithField("request_id", "req-1"))
+	log.SetLevel(log.DebugLevel)
+	if _, err := St.WithContext(ctx).License().Count(); err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(bytes.Split(buf.Bytes(), []byte("\n"))[0], &entry); err != nil {
+		t.Fatalf("expected a json log line, got %s", buf.String())
+	}
+	if entry["request_id"] != "req-1" || entry["component"] != "gorm" || entry["msg"] != "sql query" || entry["sql"] == nil {
+		t.Errorf("unexpected log entry %v", entry)
+	}
+
+	// the level follows the level of the standard logger, e.g. after a reload of the configuration
+	buf.Reset()
+	log.SetLevel(log.InfoLevel)
+	St.WithContext(ctx).License().Count()
+	if buf.Len() != 0 {
+		t.Errorf("unexpected logs at the info level: %s", buf.String())
+	}
+
+	// a level set by gorm takes precedence
+	l := newGormLogger().LogMode(logger.Silent).(*gormLogger)
+	log.SetLevel(log.DebugLevel)
+	if l.logLevel() != logger.Silent {
+		t.Errorf("expected a silent logger, got %d", l.logLevel())
+	}
+}
@@ -30,6 +30,31 @@ type Publication struct {
 	Href          string    `json:"href" validate:"required,http_url" gorm:"type:varchar(1024)"`
 	Size          uint32    `json:"size" validate:"required,number"`
 	Checksum      string    `json:"checksum" validate:"required,base64" gorm:"type:varchar(255)"`
+	// the version of the content is incremented each time the encrypted file changes,
+	// i.e. when its href, checksum or size is modified.
+	Version        int        `json:"version" gorm:"default:1"`
+	ContentUpdated *time.Time `json:"content_updated,omitempty"`
+	// takedown of the publication: policy applied to its licenses, reason and date,
+	// which may be in the future if the takedown is scheduled.
+	TakedownPolicy string     `json:"takedown_policy,omitempty" gorm:"type:varchar(20)"`
+	TakedownReason string     `json:"takedown_reason,omitempty"`
+	TakedownAt     *time.Time `json:"takedown_at,omitempty" gorm:"index"`
+	// default custom rights and extensions of the licenses of the publication,
+	// keyed by a URI, stored as json.
+	LicenseRights     map[string]interface{} `json:"license_rights,omitempty" gorm:"serializer:json"`
+	LicenseExtensions map[string]interface{} `json:"license_extensions,omitempty" gorm:"serializer:json"`
+}
+
+// Takedown policies
+const (
+	TAKEDOWN_KEEP   = "keep"   // licenses are kept, fresh licenses are still served
+	TAKEDOWN_BLOCK  = "block"  // licenses are kept, fresh licenses are refused
+	TAKEDOWN_REVOKE = "revoke" // licenses are revoked or cancelled, fresh licenses are refused
+)
+
+// BlocksFreshLicenses indicates if fresh licenses must be refused for a publication which has been taken down.
+func (p *Publication) BlocksFreshLicenses() bool {
+	return p.DeletedAt.Valid && (p.TakedownPolicy == TAKEDOWN_BLOCK || p.TakedownPolicy == TAKEDOWN_REVOKE)
 }
 
 // Validate checks required fields and values
@@ -93,6 +118,68 @@ func (s publicationStore) Update(changedPublication *Publication) error {
 	return s.db.Save(changedPublication).Error
 }
 
+// UpdateContent saves a publication whose content has changed: its version is incremented,
+// and the update date of its ready and active licenses is set, so that clients fetch a fresh license
+// pointing to the new content. It returns the number of licenses updated.
+func (s publicationStore) UpdateContent(changedPublication *Publication) (int64, error) {
+	now := time.Now()
+	prevVersion, prevUpdated := changedPublication.Version, changedPublication.ContentUpdated
+	changedPublication.Version++
+	changedPublication.ContentUpdated = &now
+
+	var count int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(changedPublication).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&LicenseInfo{}).
+			Where("publication_id = ? AND status IN ?", changedPublication.UUID, []string{STATUS_READY, STATUS_ACTIVE}).
+			Update("updated", now)
+		count = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		changedPublication.Version, changedPublication.ContentUpdated = prevVersion, prevUpdated
+		return 0, err
+	}
+	return count, nil
+}
+
 func (s publicationStore) Delete(deletedPublication *Publication) error {
 	return s.db.Delete(deletedPublication).Error
 }
+
+// ListDeleted returns the soft-deleted publications, most recently deleted first.
+func (s publicationStore) ListDeleted(pageNum, pageSize int) (*[]Publication, int64, error) {
+	publications := []Publication{}
+	var total int64
+	q := s.db.Unscoped().Model(&Publication{}).Where("deleted_at IS NOT NULL")
+	if err := q.Count(&total).Error; err != nil {
+		return &publications, 0, err
+	}
+	// pageNum starts at 1
+	return &publications, total, q.Offset((pageNum - 1) * pageSize).Limit(pageSize).Order("deleted_at DESC, id DESC").Find(&publications).Error
+}
+
+// FindScheduledTakedowns returns the publications whose takedown is scheduled before a given date.
+func (s publicationStore) FindScheduledTakedowns(before time.Time) (*[]Publication, error) {
+	publications := []Publication{}
+	return &publications, s.db.Where("takedown_policy <> '' AND takedown_at <= ?", before).Order("takedown_at").Find(&publications).Error
+}
+
+// Restore undeletes a publication and clears its takedown, or cancels a scheduled takedown.
+// Licenses revoked or cancelled by the takedown are not restored.
+func (s publicationStore) Restore(p *Publication) error {
+	err := s.db.Unscoped().Model(p).Updates(map[string]interface{}{
+		"deleted_at":      nil,
+		"takedown_policy": "",
+		"takedown_reason": "",
+		"takedown_at":     nil,
+	}).Error
+	if err != nil {
+		return err
+	}
+	p.DeletedAt = gorm.DeletedAt{}
+	p.TakedownPolicy, p.TakedownReason, p.TakedownAt = "", "", nil
+	return nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package stor
+
+// RateLimit data model, the state of a rate limiting bucket shared by several server instances.
+// TAT is the theoretical arrival time of the next request, in nanoseconds since the epoch,
+// as defined by the generic cell rate algorithm.
+type RateLimit struct {
+	ID  string `gorm:"type:varchar(255);primaryKey"`
+	TAT int64  `gorm:"index"`
+}
+
+func (s rateLimitStore) Get(id string) (*RateLimit, error) {
+	var rl RateLimit
+	return &rl, s.db.Where("id = ?", id).First(&rl).Error
+}
+
+func (s rateLimitStore) Create(rl *RateLimit) error {
+	return s.db.Create(rl).Error
+}
+
+// CompareAndSwap sets the TAT of a bucket if it has not been modified since it was read.
+func (s rateLimitStore) CompareAndSwap(id string, old, new int64) (bool, error) {
+	res := s.db.Model(&RateLimit{}).Where("id = ? AND tat = ?", id, old).Update("tat", new)
+	return res.RowsAffected == 1, res.Error
+}
+
+// Purge deletes the buckets which are full again.
+func (s rateLimitStore) Purge(before int64) error {
+	return s.db.Where("tat < ?", before).Delete(&RateLimit{}).Error
+}
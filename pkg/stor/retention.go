@@ -0,0 +1,137 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package stor
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PseudonymPrefix starts the user and device identifiers replaced by the retention rules
+// or by the erasure of a user, which are therefore not processed twice.
+const PseudonymPrefix = "anon-"
+
+// finishedStatuses lists the statuses of licenses which cannot be used anymore
+var finishedStatuses = []string{STATUS_RETURNED, STATUS_EXPIRED, STATUS_REVOKED, STATUS_CANCELLED}
+
+// finishedBefore is the condition selecting the licenses finished before a date:
+// licenses returned, expired, revoked or cancelled before the date, or whose end is before the date.
+const finishedBefore = "((status IN ? AND COALESCE(status_updated, updated_at) < ?) OR (license_infos.end IS NOT NULL AND license_infos.end < ?))"
+
+// retiredLicenses returns a query selecting the identifiers of the licenses finished before a date.
+// Soft-deleted licenses are included, as they still hold personal data.
+func (s retentionStore) retiredLicenses(before time.Time) *gorm.DB {
+	return s.db.Unscoped().Model(&LicenseInfo{}).Select("uuid").Where(finishedBefore, finishedStatuses, before, before)
+}
+
+// RetiredUsers returns the users whose licenses all finished before a date, and the number of their licenses.
+// Users having a license still in use are not returned, so that a user is identified by a single value.
+func (s retentionStore) RetiredUsers(before time.Time) ([]string, int64, error) {
+	inUse := s.db.Unscoped().Model(&LicenseInfo{}).Select("user_id").
+		Where("NOT "+finishedBefore, finishedStatuses, before, before)
+	query := s.db.Unscoped().Model(&LicenseInfo{}).
+		Where("user_id NOT LIKE ?", PseudonymPrefix+"%").
+		Where("user_id NOT IN (?)", inUse)
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []string
+	return users, count, query.Distinct("user_id").Order("user_id").Pluck("user_id", &users).Error
+}
+
+// PseudonymizeUser replaces the identifier of a user in every license of the user,
+// and removes the user info captured for fresh licenses.
+func (s retentionStore) PseudonymizeUser(userID, pseudonym string) (int64, error) {
+	res := s.db.Unscoped().Model(&LicenseInfo{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"user_id": pseudonym, "fresh_data": nil})
+	return res.RowsAffected, res.Error
+}
+
+// UserLicenses returns every license of a user, deleted licenses included.
+func (s retentionStore) UserLicenses(userID string) (*[]LicenseInfo, error) {
+	licenses := []LicenseInfo{}
+	return &licenses, s.db.Unscoped().Where("user_id = ?", userID).Order("id DESC").Find(&licenses).Error
+}
+
+// RetiredDevices returns the devices having events on licenses finished before a date,
+// and the number of these events; devices already anonymized are not returned.
+func (s retentionStore) RetiredDevices(before time.Time) ([]string, int64, error) {
+	query := s.db.Model(&Event{}).
+		Where("device_id NOT LIKE ?", PseudonymPrefix+"%").
+		Where("license_id IN (?)", s.retiredLicenses(before))
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	var devices []string
+	return devices, count, query.Distinct("device_id").Order("device_id").Pluck("device_id", &devices).Error
+}
+
+// AnonymizeDevice replaces the identifier of a device and removes its name
+// in the events of the licenses finished before a date.
+func (s retentionStore) AnonymizeDevice(deviceID, pseudonym string, before time.Time) (int64, error) {
+	res := s.db.Model(&Event{}).
+		Where("device_id = ?", deviceID).
+		Where("license_id IN (?)", s.retiredLicenses(before)).
+		Updates(map[string]interface{}{"device_id": pseudonym, "device_name": ""})
+	return res.RowsAffected, res.Error
+}
+
+// CountRetiredEvents returns the number of events of the licenses finished before a date.
+func (s retentionStore) CountRetiredEvents(before time.Time) (int64, error) {
+	var count int64
+	return count, s.db.Model(&Event{}).Where("license_id IN (?)", s.retiredLicenses(before)).Count(&count).Error
+}
+
+// PurgeEvents deletes the events of the licenses finished before a date.
+func (s retentionStore) PurgeEvents(before time.Time) (int64, error) {
+	res := s.db.Where("license_id IN (?)", s.retiredLicenses(before)).Delete(&Event{})
+	return res.RowsAffected, res.Error
+}
+
+// unusedDeletedPublications returns a query selecting the publications soft-deleted before a date
+// and referenced by no license.
+func (s retentionStore) unusedDeletedPublications(before time.Time) *gorm.DB {
+	used := s.db.Unscoped().Model(&LicenseInfo{}).Select("publication_id")
+	return s.db.Unscoped().Model(&Publication{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Where("uuid NOT IN (?)", used)
+}
+
+// CountDeletedPublications returns the number of publications soft-deleted before a date
+// and referenced by no license.
+func (s retentionStore) CountDeletedPublications(before time.Time) (int64, error) {
+	var count int64
+	return count, s.unusedDeletedPublications(before).Count(&count).Error
+}
+
+// PurgeDeletedPublications permanently deletes the publications soft-deleted before a date
+// and referenced by no license.
+func (s retentionStore) PurgeDeletedPublications(before time.Time) (int64, error) {
+	res := s.unusedDeletedPublications(before).Delete(&Publication{})
+	return res.RowsAffected, res.Error
+}
+
+// LicenseDevices returns the devices having events on some licenses; devices already anonymized are not returned.
+func (s retentionStore) LicenseDevices(licenseIDs []string) ([]string, error) {
+	var devices []string
+	return devices, s.db.Model(&Event{}).
+		Where("device_id NOT LIKE ?", PseudonymPrefix+"%").
+		Where("license_id IN ?", licenseIDs).
+		Distinct("device_id").Order("device_id").Pluck("device_id", &devices).Error
+}
+
+// AnonymizeLicenseDevice replaces the identifier of a device and removes its name in the events of some licenses.
+func (s retentionStore) AnonymizeLicenseDevice(licenseIDs []string, deviceID, pseudonym string) (int64, error) {
+	res := s.db.Model(&Event{}).
+		Where("device_id = ?", deviceID).
+		Where("license_id IN ?", licenseIDs).
+		Updates(map[string]interface{}{"device_id": pseudonym, "device_name": ""})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,126 @@
+package stor
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRetention(t *testing.T) {
+
+	db := St.(*dbStore).db
+	now := time.Now()
+	old := now.AddDate(0, 0, -100)
+	before := now.AddDate(0, 0, -30)
+
+	// a publication referenced by licenses, and an unused one, both deleted long ago
+	used := &Publication{UUID: uuid.New().String(), Title: "Used", ContentType: "application/epub+zip"}
+	unused := &Publication{UUID: uuid.New().String(), Title: "Unused", ContentType: "application/epub+zip"}
+	for _, p := range []*Publication{used, unused} {
+		if err := St.Publication().Create(p); err != nil {
+			t.Fatalf("Failed to create a publication: %v", err)
+		}
+		if err := db.Unscoped().Model(p).Update("deleted_at", old).Error; err != nil {
+			t.Fatalf("Failed to delete a publication: %v", err)
+		}
+	}
+
+	// a user whose licenses are finished, a user with a license in use
+	newLicense := func(userID, status string, statusUpdated *time.Time) *LicenseInfo {
+		l := &LicenseInfo{UUID: uuid.New().String(), Provider: "http://edrlab.org", UserID: userID,
+			PublicationID: used.UUID, Status: status, StatusUpdated: statusUpdated}
+		if err := St.License().Create(l); err != nil {
+			t.Fatalf("Failed to create a license: %v", err)
+		}
+		return l
+	}
+	l1 := newLicense("Trinity", STATUS_RETURNED, &old)
+	l1.FreshData = &FreshLicenseData{UserName: "Trinity", TextHint: "hint", PassHash: "hash"}
+	if err := St.License().Update(l1); err != nil {
+		t.Fatalf("Failed to update a license: %v", err)
+	}
+	l2 := newLicense("Neo", STATUS_REVOKED, &old)
+	l3 := newLicense("Neo", STATUS_ACTIVE, nil)
+	licenses := []*LicenseInfo{l1, l2, l3}
+	defer func() {
+		db.Unscoped().Where("license_id IN ?", []string{l1.UUID, l2.UUID, l3.UUID}).Delete(&Event{})
+		for _, l := range licenses {
+			db.Unscoped().Delete(l)
+		}
+		db.Unscoped().Delete(used)
+		db.Unscoped().Delete(unused)
+	}()
+
+	e1 := &Event{Timestamp: old, Type: EVENT_REGISTER, DeviceID: "device-1", DeviceName: "Phone", LicenseID: l1.UUID}
+	e2 := &Event{Timestamp: now, Type: EVENT_REGISTER, DeviceID: "device-2", DeviceName: "Tablet", LicenseID: l3.UUID}
+	for _, e := range []*Event{e1, e2} {
+		if err := St.Event().Create(e); err != nil {
+			t.Fatalf("Failed to create an event: %v", err)
+		}
+	}
+
+	// users
+	users, _, err := St.Retention().RetiredUsers(before)
+	if err != nil {
+		t.Fatalf("Failed to get retired users: %v", err)
+	}
+	if !slices.Contains(users, "Trinity") || slices.Contains(users, "Neo") {
+		t.Fatalf("Unexpected retired users %v", users)
+	}
+	count, err := St.Retention().PseudonymizeUser("Trinity", PseudonymPrefix+"trinity")
+	if err != nil || count != 1 {
+		t.Fatalf("Failed to pseudonymize a user, %d licenses updated: %v", count, err)
+	}
+	lic, _ := St.License().Get(l1.UUID)
+	if lic.UserID != PseudonymPrefix+"trinity" {
+		t.Fatalf("Expected a pseudonymized user, got %s", lic.UserID)
+	}
+	if lic.FreshData != nil {
+		t.Fatalf("Expected the user info captured for fresh licenses to be removed")
+	}
+	users, _, _ = St.Retention().RetiredUsers(before)
+	if slices.Contains(users, PseudonymPrefix+"trinity") {
+		t.Fatalf("A pseudonymized user is retired again")
+	}
+
+	// events
+	devices, _, err := St.Retention().RetiredDevices(before)
+	if err != nil {
+		t.Fatalf("Failed to get retired devices: %v", err)
+	}
+	if !slices.Contains(devices, "device-1") || slices.Contains(devices, "device-2") {
+		t.Fatalf("Unexpected retired devices %v", devices)
+	}
+	count, err = St.Retention().AnonymizeDevice("device-1", PseudonymPrefix+"device", before)
+	if err != nil || count != 1 {
+		t.Fatalf("Failed to anonymize a device, %d events updated: %v", count, err)
+	}
+	event, _ := St.Event().Get(e1.ID)
+	if event.DeviceID != PseudonymPrefix+"device" || event.DeviceName != "" {
+		t.Fatalf("Expected an anonymized event, got %s %s", event.DeviceID, event.DeviceName)
+	}
+	count, err = St.Retention().PurgeEvents(before)
+	if err != nil || count < 1 {
+		t.Fatalf("Failed to purge events, %d deleted: %v", count, err)
+	}
+	if count, _ = St.Event().Count(l1.UUID); count != 0 {
+		t.Fatalf("Expected no event left on a finished license, got %d", count)
+	}
+	if count, _ = St.Event().Count(l3.UUID); count != 1 {
+		t.Fatalf("Expected the events of a license in use to be kept, got %d", count)
+	}
+
+	// publications
+	count, err = St.Retention().PurgeDeletedPublications(before)
+	if err != nil || count < 1 {
+		t.Fatalf("Failed to purge publications, %d deleted: %v", count, err)
+	}
+	if _, err = St.Publication().Get(unused.UUID); err == nil {
+		t.Fatalf("An unused deleted publication was not purged")
+	}
+	if _, err = St.Publication().Get(used.UUID); err != nil {
+		t.Fatalf("A publication referenced by licenses was purged")
+	}
+}
@@ -6,15 +6,14 @@
 package stor
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
-	"log"
+	log "github.com/sirupsen/logrus"
 
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 type (
@@ -29,6 +28,9 @@ type (
 	licenseStore     dbStore
 	eventStore       dbStore
 	dashboardStore   dbStore
+	rateLimitStore   dbStore
+	auditStore       dbStore
+	retentionStore   dbStore
 
 	// Store interface, giving access to specialized interfaces
 	Store interface {
@@ -36,6 +38,12 @@ type (
 		License() LicenseRepository
 		Event() EventRepository
 		Dashboard() DashboardRepository
+		RateLimit() RateLimitRepository
+		Audit() AuditRepository
+		Retention() RetentionRepository
+		Ping(ctx context.Context) error
+		CheckMigrations() error
+		WithContext(ctx context.Context) Store
 	}
 
 	// PublicationRepository interface, defining publication operations
@@ -48,7 +56,11 @@ type (
 		GetByAltID(altID string) (*Publication, error)
 		Create(p *Publication) error
 		Update(p *Publication) error
+		UpdateContent(p *Publication) (int64, error)
 		Delete(p *Publication) error
+		ListDeleted(pageNum, pageSize int) (*[]Publication, int64, error)
+		FindScheduledTakedowns(before time.Time) (*[]Publication, error)
+		Restore(p *Publication) error
 	}
 
 	// LicenseRepository interface, defining license operations
@@ -60,6 +72,7 @@ type (
 		FindByStatus(status string) (*[]LicenseInfo, error)
 		FindByDeviceCount(min int, max int) (*[]LicenseInfo, error)
 		FindByDate(dateStr string, pubInfo bool) (*[]LicenseInfo, error)
+		FindIDs(f LicenseFilter) ([]string, error)
 		Count() (int64, error)
 		Get(uuid string) (*LicenseInfo, error)
 		Create(p *LicenseInfo) error
@@ -70,6 +83,9 @@ type (
 	// EventRepository interface, defining event operations
 	EventRepository interface {
 		List(licenseID string) (*[]Event, error)
+		Search(f EventFilter, pageNum, pageSize int) (*[]Event, int64, error)
+		SearchAll(f EventFilter) (*[]Event, error)
+		Latest(licenseID string, n int) (*[]Event, error)
 		GetRegisterByDevice(licenseID string, deviceID string) (*Event, error)
 		Count(licenseID string) (int64, error)
 		Get(id uint) (*Event, error)
@@ -83,6 +99,35 @@ type (
 		GetDashboard(excessiveSharingThreshold int, limitToLast12Months bool) (*DashboardData, error)
 		GetOversharedLicenses(excessiveSharingThreshold int, limitToLast12Months bool) ([]OversharedLicenseData, error)
 	}
+
+	// RateLimitRepository interface, defining operations on shared rate limiting buckets
+	RateLimitRepository interface {
+		Get(id string) (*RateLimit, error)
+		Create(rl *RateLimit) error
+		CompareAndSwap(id string, old, new int64) (bool, error)
+		Purge(before int64) error
+	}
+
+	// AuditRepository interface, defining operations on the records of administrative operations
+	AuditRepository interface {
+		List(pageNum, pageSize int) (*[]Audit, error)
+		Create(a *Audit) error
+	}
+
+	// RetentionRepository interface, defining the removal of personal data from licenses and events
+	RetentionRepository interface {
+		RetiredUsers(before time.Time) ([]string, int64, error)
+		PseudonymizeUser(userID, pseudonym string) (int64, error)
+		UserLicenses(userID string) (*[]LicenseInfo, error)
+		RetiredDevices(before time.Time) ([]string, int64, error)
+		AnonymizeDevice(deviceID, pseudonym string, before time.Time) (int64, error)
+		CountRetiredEvents(before time.Time) (int64, error)
+		PurgeEvents(before time.Time) (int64, error)
+		CountDeletedPublications(before time.Time) (int64, error)
+		PurgeDeletedPublications(before time.Time) (int64, error)
+		LicenseDevices(licenseIDs []string) ([]string, error)
+		AnonymizeLicenseDevice(licenseIDs []string, deviceID, pseudonym string) (int64, error)
+	}
 )
 
 // implementation of the different repository interfaces
@@ -103,6 +148,43 @@ func (s *dbStore) Dashboard() DashboardRepository {
 	return (*dashboardStore)(s)
 }
 
+func (s *dbStore) RateLimit() RateLimitRepository {
+	return (*rateLimitStore)(s)
+}
+
+func (s *dbStore) Audit() AuditRepository {
+	return (*auditStore)(s)
+}
+
+func (s *dbStore) Retention() RetentionRepository {
+	return (*retentionStore)(s)
+}
+
+// WithContext returns a store whose requests are bound to a context:
+// they are cancelled with the context, and logged with the log entry it carries.
+func (s *dbStore) WithContext(ctx context.Context) Store {
+	return &dbStore{db: s.db.WithContext(ctx)}
+}
+
+// Ping verifies that a connection to the database is still alive.
+func (s *dbStore) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckMigrations verifies that the tables of every data model are present in the database.
+func (s *dbStore) CheckMigrations() error {
+	for _, model := range models {
+		if !s.db.Migrator().HasTable(model) {
+			return fmt.Errorf("missing table for %T", model)
+		}
+	}
+	return nil
+}
+
 // List of status values as strings
 const (
 	STATUS_READY     = "ready"
@@ -118,6 +200,9 @@ const (
 	EVENT_CANCEL     = "cancel"
 )
 
+// models lists the data models managed by the store, in migration order
+var models = []interface{}{&Publication{}, &LicenseInfo{}, &Event{}, &RateLimit{}, &Audit{}}
+
 // Publication info constants
 const (
 	IncludePubInfo = true
@@ -126,39 +211,29 @@ const (
 
 // Init initializes the database
 func Init(dsn string) (Store, error) {
-	var err error
 
-	dialect, cnx := dbFromURI(dsn)
-	if dialect == "error" {
-		return nil, fmt.Errorf("incorrect database source name: %q", dsn)
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, err
 	}
+	stor := &dbStore{db: db}
 
-	// add parameters specific to the dialect
-	cnx = addDialectSpecificParams(cnx, dialect)
+	return stor, nil
+}
 
-	// database logger
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold:             time.Second, // Slow SQL threshold
-			LogLevel:                  logger.Warn, // Log level (Silent, Error, Warn, Info)
-			IgnoreRecordNotFoundError: true,        // Ignore ErrRecordNotFound error for logger
-			Colorful:                  true,        // Enable color
-		},
-	)
+// Open connects to the database and migrates its schema.
+// It is used by tools which access the tables directly, e.g. the import of legacy data.
+func Open(dsn string) (*gorm.DB, error) {
 
-	db, err := gorm.Open(GormDialector(cnx), &gorm.Config{
-		Logger: newLogger,
-	})
+	db, dialect, err := connect(dsn)
 	if err != nil {
-		log.Printf("Failed connecting to the database: %v", err)
 		return nil, err
 	}
 
 	// configure database connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Printf("Failed getting generic database object: %v", err)
+		log.Errorf("Failed getting generic database object: %v", err)
 		return nil, err
 	}
 	sqlDB.SetMaxOpenConns(25)                 // Limit maximum concurrent connections
@@ -168,19 +243,44 @@ func Init(dsn string) (Store, error) {
 
 	err = performDialectSpecific(db, dialect)
 	if err != nil {
-		log.Printf("Failed performing dialect specific database init: %v", err)
+		log.Errorf("Failed performing dialect specific database init: %v", err)
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&Publication{}, &LicenseInfo{}, &Event{})
+	err = db.AutoMigrate(models...)
 	if err != nil {
-		log.Printf("Failed performing database automigrate: %v", err)
+		log.Errorf("Failed performing database automigrate: %v", err)
 		return nil, err
 	}
+	return db, nil
+}
 
-	stor := &dbStore{db: db}
+// Connect connects to a database without altering it, e.g. a legacy database which is only read.
+func Connect(dsn string) (*gorm.DB, error) {
+	db, _, err := connect(dsn)
+	return db, err
+}
 
-	return stor, nil
+// connect opens a database identified by a dsn, and returns the dialect of the database.
+func connect(dsn string) (*gorm.DB, string, error) {
+
+	dialect, cnx := dbFromURI(dsn)
+	if dialect == "error" {
+		return nil, "", fmt.Errorf("incorrect database source name: %q", dsn)
+	}
+
+	// add parameters specific to the dialect
+	cnx = addDialectSpecificParams(cnx, dialect)
+
+	// database logger, routed through the application logger
+	db, err := gorm.Open(GormDialector(cnx), &gorm.Config{
+		Logger: newGormLogger(),
+	})
+	if err != nil {
+		log.Errorf("Failed connecting to the database: %v", err)
+		return nil, "", err
+	}
+	return db, dialect, nil
 }
 
 // dbFromURI
@@ -204,7 +304,7 @@ func addDialectSpecificParams(cnx, dialect string) string {
 	case "mssql":
 		// nothing , so far
 	default:
-		log.Printf("Invalid dialect: %s", dialect)
+		log.Warnf("Invalid dialect: %s", dialect)
 	}
 	return cnx
 }
@@ -185,6 +185,50 @@ func TestPublications(t *testing.T) {
 		t.Fatalf("Expected publication to be deleted")
 	}
 
+	// list deleted publications
+	var total int64
+	publications, total, err = St.Publication().ListDeleted(1, 10)
+	if err != nil {
+		t.Fatalf("Failed to list deleted publications: %v", err)
+	}
+	if total != 1 || len(*publications) != 1 || (*publications)[0].UUID != publication.UUID {
+		t.Fatalf("Expected the deleted publication in the list, got %d items", total)
+	}
+
+	// restore the publication
+	err = St.Publication().Restore(publication)
+	if err != nil {
+		t.Fatalf("Failed to restore a publication: %v", err)
+	}
+	publication, _ = St.Publication().Get(publication.UUID)
+	if publication.DeletedAt.Valid {
+		t.Fatalf("Expected publication to be restored")
+	}
+
+	// schedule a takedown
+	at := time.Now().Add(time.Hour)
+	publication.TakedownPolicy = TAKEDOWN_BLOCK
+	publication.TakedownAt = &at
+	err = St.Publication().Update(publication)
+	if err != nil {
+		t.Fatalf("Failed to schedule a takedown: %v", err)
+	}
+	publications, err = St.Publication().FindScheduledTakedowns(time.Now())
+	if err != nil || len(*publications) != 0 {
+		t.Fatalf("Expected no takedown due yet, got %d, %v", len(*publications), err)
+	}
+	publications, err = St.Publication().FindScheduledTakedowns(at.Add(time.Minute))
+	if err != nil || len(*publications) != 1 {
+		t.Fatalf("Expected a takedown due, got %d, %v", len(*publications), err)
+	}
+	err = St.Publication().Delete(publication)
+	if err != nil {
+		t.Fatalf("Failed to delete a publication: %v", err)
+	}
+	if !publication.BlocksFreshLicenses() {
+		t.Fatalf("Expected fresh licenses to be blocked")
+	}
+
 	// check that the creation of a new publication with the same UUID is disallowed
 	publication = &Publications[1]
 	publication.UUID = uuid.New().String()
@@ -0,0 +1,59 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fsStorage stores files in a local directory.
+type fsStorage struct {
+	dir string
+}
+
+type fsObject struct {
+	*os.File
+	info os.FileInfo
+}
+
+func (o *fsObject) Size() int64 {
+	return o.info.Size()
+}
+
+func (o *fsObject) ModTime() time.Time {
+	return o.info.ModTime()
+}
+
+// NewFS returns a storage in a local directory.
+func NewFS(dir string) Storage {
+	return &fsStorage{dir: dir}
+}
+
+// Open opens a file of the directory; keys are file names, not paths.
+func (s *fsStorage) Open(ctx context.Context, key string) (Object, error) {
+
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(s.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+	return &fsObject{File: f, info: info}, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+)
+
+// s3ChunkSize is the size of the ranges fetched from s3, which limits the number of requests
+// when a file is read sequentially by small blocks.
+const s3ChunkSize = 1 << 20
+
+// s3Storage stores files in an s3 bucket.
+type s3Storage struct {
+	client *s3.S3
+	bucket string
+}
+
+// NewS3 returns a storage in an s3 bucket. Credentials are taken from the environment
+// if no access key is configured.
+func NewS3(cfg conf.S3Storage) (Storage, error) {
+
+	awsConfig := aws.NewConfig().WithRegion(cfg.Region).WithS3ForcePathStyle(cfg.PathStyle)
+	if cfg.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.AccessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""))
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Storage{client: s3.New(sess), bucket: cfg.Bucket}, nil
+}
+
+// Open gets the size and modification date of an object; its content is fetched on demand.
+func (s *s3Storage) Open(ctx context.Context, key string) (Object, error) {
+
+	head, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var reqErr awserr.RequestFailure
+		if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	o := &s3Object{ctx: ctx, storage: s, key: key, size: aws.Int64Value(head.ContentLength), modTime: aws.TimeValue(head.LastModified)}
+	o.SectionReader = io.NewSectionReader(o, 0, o.size)
+	return o, nil
+}
+
+// s3Object reads an object by ranges, keeping the last range fetched.
+type s3Object struct {
+	*io.SectionReader
+	ctx      context.Context
+	storage  *s3Storage
+	key      string
+	size     int64
+	modTime  time.Time
+	chunk    []byte
+	chunkOff int64
+}
+
+func (o *s3Object) Size() int64 {
+	return o.size
+}
+
+func (o *s3Object) ModTime() time.Time {
+	return o.modTime
+}
+
+func (o *s3Object) Close() error {
+	o.chunk = nil
+	return nil
+}
+
+// ReadAt implements io.ReaderAt; it is used by the embedded section reader.
+func (o *s3Object) ReadAt(p []byte, off int64) (int, error) {
+
+	if off >= o.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off < o.size {
+		if o.chunk == nil || off < o.chunkOff || off >= o.chunkOff+int64(len(o.chunk)) {
+			if err := o.fetch(off, int64(len(p)-n)); err != nil {
+				return n, err
+			}
+		}
+		c := copy(p[n:], o.chunk[off-o.chunkOff:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch gets the range of the object starting at off, of at least s3ChunkSize bytes.
+func (o *s3Object) fetch(off, length int64) error {
+
+	if length < s3ChunkSize {
+		length = s3ChunkSize
+	}
+	end := off + length - 1
+	if end >= o.size {
+		end = o.size - 1
+	}
+	resp, err := o.storage.client.GetObjectWithContext(o.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(o.storage.bucket),
+		Key:    aws.String(o.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	chunk := make([]byte, end-off+1)
+	if _, err := io.ReadFull(resp.Body, chunk); err != nil {
+		return err
+	}
+	o.chunk, o.chunkOff = chunk, off
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+// The storage package gives access to the encrypted publications served by the server,
+// stored in a local directory or in an s3 bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/edrlab/lcp-server/pkg/conf"
+)
+
+var ErrNotFound = errors.New("file not found in the storage")
+
+// Object is a stored file, opened for reading; it supports random access,
+// which allows range requests and the reading of zip packages.
+type Object interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	ModTime() time.Time
+}
+
+// Storage gives access to stored files.
+type Storage interface {
+	Open(ctx context.Context, key string) (Object, error)
+}
+
+// New returns the storage defined by the configuration, nil if publications are not served.
+func New(cfg conf.Storage) (Storage, error) {
+	switch cfg.Mode {
+	case "":
+		return nil, nil
+	case conf.StorageFS:
+		return NewFS(cfg.Directory), nil
+	case conf.StorageS3:
+		return NewS3(cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown storage %q", cfg.Mode)
+}
+
+// Key returns the storage key of a publication, i.e. the file name of its href.
+func Key(href string) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	key := path.Base(u.Path)
+	if key == "." || key == "/" || key == ".." {
+		return "", fmt.Errorf("no file name in %s", href)
+	}
+	return key, nil
+}
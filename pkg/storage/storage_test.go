@@ -0,0 +1,49 @@
+// Copyright 2026 European Digital Reading Lab. All rights reserved.
+// Use of this source code is governed by a BSD-style license
+// specified in the Github project LICENSE file.
+
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+
+	key, err := Key("https://example.com/files/book.epub?token=123")
+	if err != nil || key != "book.epub" {
+		t.Errorf("Unexpected key %q, %v", key, err)
+	}
+	if _, err := Key("https://example.com/"); err == nil {
+		t.Error("Expected an error for an href without file name")
+	}
+}
+
+func TestFSOpen(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "book.epub"), []byte("encrypted content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st := NewFS(dir)
+
+	obj, err := st.Open(context.Background(), "book.epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	data, _ := io.ReadAll(obj)
+	if string(data) != "encrypted content" || obj.Size() != int64(len(data)) {
+		t.Errorf("Unexpected content %q", data)
+	}
+
+	for _, key := range []string{"missing.epub", "../book.epub", "..", ""} {
+		if _, err := st.Open(context.Background(), key); err != ErrNotFound {
+			t.Errorf("Expected ErrNotFound for %q, got %v", key, err)
+		}
+	}
+}